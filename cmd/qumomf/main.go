@@ -18,15 +18,20 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/shmel1k/qumomf/internal/storage"
+	"github.com/shmel1k/qumomf/internal/storage/memory"
+	"github.com/shmel1k/qumomf/internal/storage/postgres"
 	"github.com/shmel1k/qumomf/internal/storage/sqlite"
+	"github.com/shmel1k/qumomf/internal/storage/tarantool"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sys/unix"
 	"gopkg.in/natefinch/lumberjack.v2"
 
+	"github.com/shmel1k/qumomf/internal/alertmanager"
 	"github.com/shmel1k/qumomf/internal/config"
 	"github.com/shmel1k/qumomf/internal/coordinator"
+	"github.com/shmel1k/qumomf/internal/notifier"
 	"github.com/shmel1k/qumomf/internal/qumhttp"
 )
 
@@ -54,7 +59,25 @@ func main() {
 		logger.Fatal().Err(err).Msg("failed to init persistent storage")
 	}
 
-	service := api.NewService(db)
+	notify := newNotifier(cfg, logger)
+	notify.Serve()
+
+	qCoordinator := coordinator.New(logger, db)
+	qCoordinator.SetNotifier(notify)
+
+	var alerts *alertmanager.Pusher
+	if amCfg := cfg.Qumomf.Alertmanager; len(amCfg.URLs) > 0 {
+		alerts = alertmanager.New(alertmanager.Config{
+			URLs:            amCfg.URLs,
+			RefreshInterval: amCfg.RefreshInterval,
+			Timeout:         amCfg.Timeout,
+			Headers:         amCfg.Headers,
+			Labels:          amCfg.Labels,
+		}, logger)
+		alerts.Serve()
+		qCoordinator.SetAlertPusher(alerts)
+	}
+	service := api.NewService(db, qCoordinator)
 	server := initHTTPServer(logger, service, cfg.Qumomf.Port)
 
 	logger.Info().Msgf("Starting qumomf %s, commit %s, built at %s", version, commit, buildDate)
@@ -72,7 +95,6 @@ func main() {
 		logger.Warn().Msg("No clusters are found in the configuration")
 	}
 
-	qCoordinator := coordinator.New(logger, db)
 	for clusterName, clusterCfg := range cfg.Clusters {
 		err = qCoordinator.RegisterCluster(clusterName, clusterCfg, cfg)
 		if err != nil {
@@ -88,21 +110,98 @@ func main() {
 
 	logger.Info().Msgf("Received system signal: %s. Shutting down qumomf", sig)
 	qCoordinator.Shutdown()
+	notify.Shutdown()
+	if alerts != nil {
+		alerts.Shutdown()
+	}
 
 	err = server.Shutdown(context.Background())
 	if err != nil {
 		logger.Err(err).Msg("Failed to shutting down the HTTP server gracefully")
 	}
+
+	err = db.Close()
+	if err != nil {
+		logger.Err(err).Msg("Failed to close the persistent storage")
+	}
 }
 
 func newStorage(cfg *config.Config) (storage.Storage, error) {
+	storageCfg := cfg.Qumomf.Storage
+	switch storageCfg.Type {
+	case config.StorageTypePostgres:
+		return postgres.New(postgres.Config{
+			DSN:               storageCfg.DSN,
+			ConnectTimeout:    storageCfg.ConnectTimeout,
+			QueryTimeout:      storageCfg.QueryTimeout,
+			SnapshotRetention: storageCfg.SnapshotRetention,
+		})
+	case config.StorageTypeTarantool:
+		return tarantool.New(tarantool.Config{
+			Addr:              storageCfg.Addr,
+			User:              storageCfg.User,
+			Password:          storageCfg.Password,
+			ConnectTimeout:    storageCfg.ConnectTimeout,
+			QueryTimeout:      storageCfg.QueryTimeout,
+			SnapshotRetention: storageCfg.SnapshotRetention,
+		})
+	case config.StorageTypeMemory:
+		return memory.New(memory.Config{
+			SnapshotRetention: storageCfg.SnapshotRetention,
+		}), nil
+	}
+
 	return sqlite.New(sqlite.Config{
-		FileName:       cfg.Qumomf.Storage.Filename,
-		ConnectTimeout: cfg.Qumomf.Storage.ConnectTimeout,
-		QueryTimeout:   cfg.Qumomf.Storage.QueryTimeout,
+		FileName:          storageCfg.Filename,
+		ConnectTimeout:    storageCfg.ConnectTimeout,
+		QueryTimeout:      storageCfg.QueryTimeout,
+		SnapshotRetention: storageCfg.SnapshotRetention,
 	})
 }
 
+func newNotifier(cfg *config.Config, logger zerolog.Logger) *notifier.Notifier {
+	notifyCfg := cfg.Qumomf.Notifications
+
+	sinks := make([]notifier.Sink, 0, len(notifyCfg.Sinks))
+	for _, sinkCfg := range notifyCfg.Sinks { //nolint:gocritic
+		switch sinkCfg.Type {
+		case config.SinkTypeWebhook:
+			sinks = append(sinks, notifier.NewWebhookSink(sinkCfg.Name, sinkCfg.URL, sinkCfg.Headers))
+		case config.SinkTypeSlack:
+			sinks = append(sinks, notifier.NewSlackSink(sinkCfg.Name, sinkCfg.URL, sinkCfg.Channel, sinkCfg.Username))
+		case config.SinkTypeSMTP:
+			sinks = append(sinks, notifier.NewSMTPSink(sinkCfg.Name, notifier.SMTPOptions{
+				Host:     sinkCfg.SMTP.Host,
+				Port:     sinkCfg.SMTP.Port,
+				Username: sinkCfg.SMTP.Username,
+				Password: sinkCfg.SMTP.Password,
+				From:     sinkCfg.SMTP.From,
+				To:       sinkCfg.SMTP.To,
+			}))
+		}
+	}
+
+	routes := make([]notifier.Route, 0, len(notifyCfg.Routes))
+	for _, routeCfg := range notifyCfg.Routes {
+		severities := make([]notifier.Severity, 0, len(routeCfg.Severities))
+		for _, severity := range routeCfg.Severities {
+			severities = append(severities, notifier.Severity(severity))
+		}
+		routes = append(routes, notifier.Route{
+			Clusters:   routeCfg.Clusters,
+			Severities: severities,
+			Sinks:      routeCfg.Sinks,
+		})
+	}
+
+	return notifier.New(notifier.Config{
+		Sinks:          sinks,
+		Routes:         routes,
+		RepeatInterval: notifyCfg.RepeatInterval,
+		Timeout:        notifyCfg.Timeout,
+	}, logger.With().Str("subsystem", "notifier").Logger())
+}
+
 func initLogger(cfg *config.Config) zerolog.Logger {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
@@ -0,0 +1,10 @@
+-- Dedicated instance keeping the state of qumomf.
+box.cfg{
+    listen = 3301,
+}
+
+box.once('qumomf_user', function()
+    box.schema.user.create('qumomf', {password = 'qumomf', if_not_exists = true})
+    -- qumomf creates its spaces on start and evaluates Lua code to access them.
+    box.schema.user.grant('qumomf', 'read,write,execute,create', 'universe', nil, {if_not_exists = true})
+end)
@@ -0,0 +1,358 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/shmel1k/qumomf/internal/notifier"
+	"github.com/shmel1k/qumomf/internal/vshard"
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
+)
+
+const (
+	// alertsPath is the path of Alertmanager v2 API receiving the alerts.
+	alertsPath = "/api/v2/alerts"
+
+	// AlertNameReplicaSetState is a name of the alerts
+	// about the problem states of the replica sets.
+	AlertNameReplicaSetState = "QumomfReplicaSetState"
+	// AlertNameVShard is a name of the alerts reported by vshard
+	// on the routers and storages.
+	AlertNameVShard = "QumomfVShardAlert"
+
+	// endsAtFactor defines how many refresh intervals the active alert lives
+	// in Alertmanager without being pushed again.
+	endsAtFactor = 4
+
+	// responseLimit is a max number of bytes read from the error response.
+	responseLimit = 1024
+)
+
+// criticalVShardAlerts are the vshard alerts meaning
+// that a part of the data is unavailable for writes.
+var criticalVShardAlerts = map[vshard.AlertType]bool{
+	"MISSING_MASTER":         true,
+	"UNREACHABLE_MASTER":     true,
+	"UNREACHABLE_REPLICASET": true,
+}
+
+type Config struct {
+	// URLs are the base URLs of Alertmanager instances, e.g. http://alertmanager:9093.
+	// Alerts are pushed to all of them.
+	URLs []string
+	// RefreshInterval is a period of pushing the alerts.
+	RefreshInterval time.Duration
+	// Timeout is a deadline of a single push.
+	Timeout time.Duration
+	// Headers are added to each request.
+	Headers map[string]string
+	// Labels are added to each alert.
+	Labels map[string]string
+}
+
+// Alert is an alert in the format of Alertmanager v2 API.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+type trackedAlert struct {
+	Alert
+	// cluster and source identify the observation which produced the alert.
+	cluster string
+	source  string
+	// resolved alerts are pushed once more and dropped.
+	resolved bool
+}
+
+// Pusher keeps the alerts of the problem states of the replica sets
+// and the vshard alerts and pushes them to Alertmanager periodically.
+type Pusher struct {
+	cfg    Config
+	client *http.Client
+
+	alerts map[string]*trackedAlert
+	mu     sync.Mutex
+
+	stop   chan struct{}
+	now    func() time.Time
+	logger zerolog.Logger
+}
+
+func New(cfg Config, logger zerolog.Logger) *Pusher {
+	return &Pusher{
+		cfg:    cfg,
+		client: &http.Client{},
+		alerts: make(map[string]*trackedAlert),
+		stop:   make(chan struct{}, 1),
+		now:    time.Now,
+		logger: logger,
+	}
+}
+
+// Serve starts pushing the alerts on the refresh interval.
+func (p *Pusher) Serve() {
+	go func() {
+		tick := time.NewTicker(p.cfg.RefreshInterval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-tick.C:
+				p.push()
+			}
+		}
+	}()
+}
+
+func (p *Pusher) Shutdown() {
+	p.stop <- struct{}{}
+}
+
+// ObserveAnalysis fires the alert if the replica set has a problem
+// and resolves the alert of the previous problem state.
+func (p *Pusher) ObserveAnalysis(cluster string, analysis orchestrator.ReplicationAnalysis) {
+	source := "analysis/" + string(analysis.Set.UUID)
+
+	var alerts []Alert
+	if analysis.State != orchestrator.NoProblem {
+		summary := fmt.Sprintf("Replica set %s of cluster %s has %s state", analysis.Set.UUID, cluster, analysis.State)
+		annotations := map[string]string{
+			"summary":    summary,
+			"master_uri": analysis.Set.MasterURI,
+		}
+		if len(analysis.DeadFollowers) > 0 {
+			annotations["dead_followers"] = strings.Join(analysis.DeadFollowers, ", ")
+		}
+		alerts = append(alerts, Alert{
+			Labels: map[string]string{
+				"alertname": AlertNameReplicaSetState,
+				"cluster":   cluster,
+				"shard":     string(analysis.Set.UUID),
+				"state":     string(analysis.State),
+				"severity":  string(notifier.StateSeverity(analysis.State)),
+			},
+			Annotations: annotations,
+		})
+	}
+
+	p.observe(cluster, source, alerts)
+}
+
+// ObserveSnapshot fires the vshard alerts of the routers and storages
+// and resolves the ones which have gone.
+func (p *Pusher) ObserveSnapshot(cluster string, snapshot vshard.Snapshot) {
+	var alerts []Alert
+	for i := range snapshot.Routers {
+		router := &snapshot.Routers[i]
+		for _, a := range router.Info.Alerts {
+			alerts = append(alerts, newVShardAlert(cluster, "", router.URI, "router", a))
+		}
+	}
+	for i := range snapshot.ReplicaSets {
+		set := &snapshot.ReplicaSets[i]
+		for j := range set.Instances {
+			inst := &set.Instances[j]
+			for _, a := range inst.StorageInfo.Alerts {
+				alerts = append(alerts, newVShardAlert(cluster, string(set.UUID), inst.URI, "storage", a))
+			}
+		}
+	}
+
+	p.observe(cluster, "vshard", alerts)
+}
+
+func newVShardAlert(cluster, shard, uri, role string, a vshard.Alert) Alert {
+	severity := notifier.SeverityWarning
+	if criticalVShardAlerts[a.Type] {
+		severity = notifier.SeverityCritical
+	}
+
+	labels := map[string]string{
+		"alertname": AlertNameVShard,
+		"cluster":   cluster,
+		"instance":  uri,
+		"role":      role,
+		"state":     string(a.Type),
+		"severity":  string(severity),
+	}
+	if shard != "" {
+		labels["shard"] = shard
+	}
+
+	return Alert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("vshard %s %s of cluster %s reports %s", role, uri, cluster, a.Type),
+			"description": a.Description,
+		},
+	}
+}
+
+// observe replaces the active alerts of the source with the given ones.
+// Active alerts of the source which are not in the list are resolved.
+func (p *Pusher) observe(cluster, source string, alerts []Alert) {
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	firing := make(map[string]bool, len(alerts))
+	for i := range alerts {
+		a := alerts[i]
+		for k, v := range p.cfg.Labels {
+			if _, ok := a.Labels[k]; !ok {
+				a.Labels[k] = v
+			}
+		}
+
+		fp := fingerprint(a.Labels)
+		firing[fp] = true
+		if tracked, ok := p.alerts[fp]; ok && !tracked.resolved {
+			tracked.Annotations = a.Annotations
+			continue
+		}
+
+		a.StartsAt = now
+		p.alerts[fp] = &trackedAlert{
+			Alert:   a,
+			cluster: cluster,
+			source:  source,
+		}
+	}
+
+	for fp, tracked := range p.alerts {
+		if tracked.cluster != cluster || tracked.source != source || tracked.resolved || firing[fp] {
+			continue
+		}
+		tracked.resolved = true
+		tracked.EndsAt = now
+	}
+}
+
+// Alerts returns the alerts to be pushed. Active alerts end
+// after a few refresh intervals unless they are pushed again.
+func (p *Pusher) Alerts() []Alert {
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(p.alerts))
+	for _, tracked := range p.alerts {
+		a := tracked.Alert
+		if !tracked.resolved {
+			a.EndsAt = now.Add(endsAtFactor * p.cfg.RefreshInterval)
+		}
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return fingerprint(alerts[i].Labels) < fingerprint(alerts[j].Labels)
+	})
+
+	return alerts
+}
+
+// push sends the alerts to all Alertmanager instances. Resolved alerts
+// are dropped after they have been received by at least one instance.
+func (p *Pusher) push() {
+	alerts := p.Alerts()
+	if len(alerts) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		p.logger.Err(err).Msg("Failed to marshal alerts")
+		return
+	}
+
+	delivered := false
+	for _, url := range p.cfg.URLs {
+		err = p.send(url, body)
+		if err != nil {
+			p.logger.Err(err).Str("url", url).Msg("Failed to push alerts to Alertmanager")
+			continue
+		}
+		delivered = true
+	}
+	if !delivered {
+		return
+	}
+
+	p.mu.Lock()
+	for _, a := range alerts {
+		fp := fingerprint(a.Labels)
+		if tracked, ok := p.alerts[fp]; ok && tracked.resolved && tracked.EndsAt.Equal(a.EndsAt) {
+			delete(p.alerts, fp)
+		}
+	}
+	p.mu.Unlock()
+}
+
+func (p *Pusher) send(url string, body []byte) error {
+	ctx := context.Background()
+	if p.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(url, "/")+alertsPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, responseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	return nil
+}
+
+// fingerprint identifies the alert by its labels.
+func fingerprint(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(labels[k])
+		sb.WriteByte(0)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,159 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
+)
+
+func newAnalysis(state orchestrator.ReplicaSetState) orchestrator.ReplicationAnalysis {
+	return orchestrator.ReplicationAnalysis{
+		Set: vshard.ReplicaSet{
+			UUID:      "set_1",
+			MasterURI: "localhost:3301",
+		},
+		State: state,
+	}
+}
+
+func TestPusher_ObserveAnalysis(t *testing.T) {
+	now := time.Unix(1600000000, 0).UTC()
+	p := New(Config{
+		RefreshInterval: time.Minute,
+		Labels:          map[string]string{"env": "test"},
+	}, zerolog.Nop())
+	p.now = func() time.Time {
+		return now
+	}
+
+	p.ObserveAnalysis("sandbox", newAnalysis(orchestrator.NoProblem))
+	assert.Empty(t, p.Alerts())
+
+	p.ObserveAnalysis("sandbox", newAnalysis(orchestrator.DeadFollowers))
+	alerts := p.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, map[string]string{
+		"alertname": AlertNameReplicaSetState,
+		"cluster":   "sandbox",
+		"shard":     "set_1",
+		"state":     "DeadFollowers",
+		"severity":  "warning",
+		"env":       "test",
+	}, alerts[0].Labels)
+	assert.Equal(t, now, alerts[0].StartsAt)
+	assert.Equal(t, now.Add(4*time.Minute), alerts[0].EndsAt)
+
+	started := now
+	now = now.Add(time.Minute)
+	p.ObserveAnalysis("sandbox", newAnalysis(orchestrator.DeadFollowers))
+	alerts = p.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, started, alerts[0].StartsAt)
+	assert.Equal(t, now.Add(4*time.Minute), alerts[0].EndsAt)
+
+	now = now.Add(time.Minute)
+	p.ObserveAnalysis("sandbox", newAnalysis(orchestrator.DeadMaster))
+	alerts = p.Alerts()
+	require.Len(t, alerts, 2)
+	for _, a := range alerts {
+		switch a.Labels["state"] {
+		case "DeadFollowers":
+			assert.Equal(t, now, a.EndsAt, "previous state must be resolved")
+		case "DeadMaster":
+			assert.Equal(t, "critical", a.Labels["severity"])
+			assert.Equal(t, now, a.StartsAt)
+		default:
+			assert.Failf(t, "unexpected alert", "%v", a.Labels)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	p.ObserveAnalysis("sandbox", newAnalysis(orchestrator.NoProblem))
+	for _, a := range p.Alerts() {
+		assert.False(t, a.EndsAt.After(now), "all alerts must be resolved")
+	}
+}
+
+func TestPusher_ObserveSnapshot(t *testing.T) {
+	p := New(Config{RefreshInterval: time.Minute}, zerolog.Nop())
+
+	snapshot := vshard.Snapshot{
+		Routers: []vshard.Router{{
+			URI: "localhost:3300",
+			Info: vshard.RouterInfo{
+				Alerts: []vshard.Alert{{Type: "UNREACHABLE_REPLICASET", Description: "Replicaset set_1 is unreachable"}},
+			},
+		}},
+		ReplicaSets: []vshard.ReplicaSet{{
+			UUID: "set_1",
+			Instances: []vshard.Instance{{
+				URI: "localhost:3302",
+				StorageInfo: vshard.StorageInfo{
+					Alerts: []vshard.Alert{{Type: vshard.AlertUnreachableReplica, Description: "Replica is unreachable"}},
+				},
+			}},
+		}},
+	}
+	p.ObserveSnapshot("sandbox", snapshot)
+
+	alerts := p.Alerts()
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "router", alerts[0].Labels["role"])
+	assert.Equal(t, "critical", alerts[0].Labels["severity"])
+	assert.Equal(t, "Replicaset set_1 is unreachable", alerts[0].Annotations["description"])
+	assert.Equal(t, "storage", alerts[1].Labels["role"])
+	assert.Equal(t, "set_1", alerts[1].Labels["shard"])
+	assert.Equal(t, "warning", alerts[1].Labels["severity"])
+
+	snapshot.Routers[0].Info.Alerts = nil
+	p.ObserveSnapshot("sandbox", snapshot)
+	p.ObserveSnapshot("production", vshard.Snapshot{})
+
+	alerts = p.Alerts()
+	require.Len(t, alerts, 2)
+	assert.False(t, alerts[0].EndsAt.After(time.Now()), "router alert must be resolved")
+	assert.True(t, alerts[1].EndsAt.After(time.Now()), "storage alert must be active")
+}
+
+func TestPusher_Push(t *testing.T) {
+	var got []Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, alertsPath, r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failed.Close()
+
+	p := New(Config{
+		URLs:            []string{failed.URL, srv.URL + "/"},
+		RefreshInterval: time.Minute,
+		Headers:         map[string]string{"Authorization": "Bearer token"},
+	}, zerolog.Nop())
+
+	p.ObserveAnalysis("sandbox", newAnalysis(orchestrator.DeadMaster))
+	p.push()
+	require.Len(t, got, 1)
+	assert.Equal(t, "DeadMaster", got[0].Labels["state"])
+
+	p.ObserveAnalysis("sandbox", newAnalysis(orchestrator.NoProblem))
+	p.push()
+	require.Len(t, got, 1)
+	assert.False(t, got[0].EndsAt.After(time.Now()))
+
+	// Resolved alerts are dropped after the delivery.
+	assert.Empty(t, p.Alerts())
+}
@@ -3,17 +3,25 @@ package api
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/shmel1k/qumomf/internal/coordinator"
 	"github.com/shmel1k/qumomf/internal/storage"
-	"github.com/shmel1k/qumomf/internal/storage/sqlite"
+	"github.com/shmel1k/qumomf/internal/util"
 	"github.com/shmel1k/qumomf/internal/vshard"
 	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
 )
 
 var (
-	ErrClusterNotFound    = errors.New("cluster not found")
-	ErrReplicaSetNotFound = errors.New("replica set not found")
-	ErrInstanceNotFound   = errors.New("instance not found")
+	ErrClusterNotFound       = errors.New("cluster not found")
+	ErrReplicaSetNotFound    = errors.New("replica set not found")
+	ErrInstanceNotFound      = errors.New("instance not found")
+	ErrSwitchoversInProgress = errors.New("planned switchovers of the cluster are already in progress")
+	ErrClusterReadOnly       = errors.New("cluster is in readonly mode")
+	ErrHookJobNotFound       = errors.New("hook job not found")
+	ErrHookJobNotDead        = errors.New("only dead hook jobs might be re-run")
+	ErrSnapshotNotFound      = errors.New("no snapshot at the given time")
+	ErrReportNotFound        = errors.New("report not found")
 )
 
 type Service interface {
@@ -24,16 +32,33 @@ type Service interface {
 	Recoveries(context.Context, string, vshard.ReplicaSetUUID) ([]orchestrator.Recovery, error)
 	Alerts(context.Context) (AlertsResponse, error)
 	ClusterAlerts(context.Context, string) (AlertsResponse, error)
+	SetClusterReadOnly(context.Context, string, ReadOnlyRequest) (storage.ReadOnlyOverride, error)
+	ClusterReadOnlyOverrides(context.Context, string) ([]storage.ReadOnlyOverride, error)
+	ClusterRebalancePlan(context.Context, string) (orchestrator.RebalancePlan, error)
+	RebalanceCluster(context.Context, string) (orchestrator.RebalanceReport, error)
+	RebalanceReport(context.Context, string) (orchestrator.RebalanceReport, error)
+	DrainHost(context.Context, string, string, DrainRequest) (orchestrator.DrainReport, error)
+	DrainReport(context.Context, string, string) (orchestrator.DrainReport, error)
+	UndrainHost(context.Context, string, string, DrainRequest) (storage.HostDrain, error)
+	HostDrains(context.Context, string) ([]storage.HostDrain, error)
+	ClusterHooks(context.Context, string) (map[orchestrator.HookType][]HookInfo, error)
+	HookJobs(context.Context, string, orchestrator.HookJobStatus) ([]HookJobInfo, error)
+	RetryHookJob(context.Context, string, int64) (HookJobInfo, error)
+	SnapshotHistory(context.Context, string, int64, int64) ([]int64, error)
+	ClusterSnapshotAt(context.Context, string, int64) (vshard.Snapshot, error)
+	SnapshotDiff(context.Context, string, int64, int64) (vshard.SnapshotDiff, error)
 }
 
-func NewService(db storage.Storage) Service {
+func NewService(db storage.Storage, coord *coordinator.Coordinator) Service {
 	return &service{
-		db: db,
+		db:    db,
+		coord: coord,
 	}
 }
 
 type service struct {
-	db storage.Storage
+	db    storage.Storage
+	coord *coordinator.Coordinator
 }
 
 func (s *service) ClustersList(ctx context.Context) ([]ClusterInfo, error) {
@@ -58,17 +83,61 @@ func (s *service) ClustersList(ctx context.Context) ([]ClusterInfo, error) {
 
 func (s *service) ClusterSnapshot(ctx context.Context, clusterName string) (vshard.Snapshot, error) {
 	snap, err := s.db.GetClusterSnapshot(ctx, clusterName)
-	if err == sqlite.ErrEmptyResult {
+	if err == storage.ErrEmptyResult {
 		return vshard.Snapshot{}, ErrClusterNotFound
 	}
 
 	return snap, err
 }
 
+// SnapshotHistory returns the creation times of the historical
+// snapshots of the cluster in the given range.
+func (s *service) SnapshotHistory(ctx context.Context, clusterName string, from, to int64) ([]int64, error) {
+	return s.db.GetSnapshotTimestamps(ctx, clusterName, from, to)
+}
+
+// ClusterSnapshotAt returns the snapshot of the cluster as of the given time.
+// It returns ErrSnapshotNotFound if the cluster is known but the time
+// is before the history of the cluster.
+func (s *service) ClusterSnapshotAt(ctx context.Context, clusterName string, at int64) (vshard.Snapshot, error) {
+	snap, err := s.db.GetSnapshotAt(ctx, clusterName, at)
+	if err != storage.ErrEmptyResult {
+		return snap, err
+	}
+
+	_, err = s.ClusterSnapshot(ctx, clusterName)
+	if err != nil {
+		return vshard.Snapshot{}, err
+	}
+
+	return vshard.Snapshot{}, ErrSnapshotNotFound
+}
+
+// SnapshotDiff compares the snapshots of the cluster as of the given times.
+// Zero upper bound means the latest snapshot.
+func (s *service) SnapshotDiff(ctx context.Context, clusterName string, from, to int64) (vshard.SnapshotDiff, error) {
+	prev, err := s.ClusterSnapshotAt(ctx, clusterName, from)
+	if err != nil {
+		return vshard.SnapshotDiff{}, err
+	}
+
+	var next vshard.Snapshot
+	if to == 0 {
+		next, err = s.ClusterSnapshot(ctx, clusterName)
+	} else {
+		next, err = s.ClusterSnapshotAt(ctx, clusterName, to)
+	}
+	if err != nil {
+		return vshard.SnapshotDiff{}, err
+	}
+
+	return vshard.DiffSnapshots(&prev, &next), nil
+}
+
 func (s *service) ReplicaSet(ctx context.Context, clusterName string, replicaSetUUID vshard.ReplicaSetUUID) (vshard.ReplicaSet, error) {
 	snap, err := s.db.GetClusterSnapshot(ctx, clusterName)
 	if err != nil {
-		if err == sqlite.ErrEmptyResult {
+		if err == storage.ErrEmptyResult {
 			return vshard.ReplicaSet{}, ErrClusterNotFound
 		}
 		return vshard.ReplicaSet{}, err
@@ -109,14 +178,49 @@ func (s *service) Recoveries(ctx context.Context, clusterName string, replicaSet
 
 	resp := make([]orchestrator.Recovery, 0, len(recoveries))
 	for i := range recoveries {
-		if recoveries[i].SetUUID == replicaSetUUID {
-			resp = append(resp, recoveries[i])
+		if recoveries[i].SetUUID != replicaSetUUID {
+			continue
 		}
+
+		err = s.resolveHookJobs(ctx, &recoveries[i])
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, recoveries[i])
 	}
 
 	return resp, nil
 }
 
+// resolveHookJobs fills the async hooks of the recovery with the state of their queued jobs.
+// The recovery keeps only the start of the async hook, so the final result is taken from the job.
+func (s *service) resolveHookJobs(ctx context.Context, recovery *orchestrator.Recovery) error {
+	for i := range recovery.Hooks {
+		hook := &recovery.Hooks[i]
+		if hook.JobID == 0 {
+			continue
+		}
+
+		job, err := s.db.GetHookJob(ctx, hook.JobID)
+		if err == orchestrator.ErrHookJobNotFound || (err == nil && job.ClusterName != recovery.ClusterName) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if (job.Status == orchestrator.HookJobDone || job.Status == orchestrator.HookJobDead) && job.LastResult != nil {
+			startTimestamp := hook.StartTimestamp
+			*hook = *job.LastResult
+			hook.StartTimestamp = startTimestamp
+			hook.JobID = job.ID
+		}
+		hook.JobStatus = job.Status
+	}
+
+	return nil
+}
+
 func (s *service) Alerts(ctx context.Context) (AlertsResponse, error) {
 	clusters, err := s.db.GetClusters(ctx)
 	if err != nil {
@@ -148,6 +252,173 @@ func (s *service) ClusterAlerts(ctx context.Context, clusterName string) (Alerts
 	}, nil
 }
 
+func (s *service) SetClusterReadOnly(ctx context.Context, clusterName string, req ReadOnlyRequest) (storage.ReadOnlyOverride, error) {
+	override := storage.ReadOnlyOverride{
+		ClusterName: clusterName,
+		ReadOnly:    *req.ReadOnly,
+		Author:      req.Author,
+		Reason:      req.Reason,
+		CreatedAt:   util.Timestamp(),
+	}
+	if req.RevertAfter > 0 {
+		override.RevertAt = time.Now().Add(time.Duration(req.RevertAfter) * time.Second).Unix()
+	}
+
+	err := s.coord.SetReadOnly(ctx, override)
+	if err == coordinator.ErrClusterNotFound {
+		return storage.ReadOnlyOverride{}, ErrClusterNotFound
+	}
+
+	return override, err
+}
+
+func (s *service) ClusterReadOnlyOverrides(ctx context.Context, clusterName string) ([]storage.ReadOnlyOverride, error) {
+	return s.db.GetReadOnlyOverrides(ctx, clusterName)
+}
+
+func (s *service) ClusterRebalancePlan(_ context.Context, clusterName string) (orchestrator.RebalancePlan, error) {
+	plan, err := s.coord.RebalancePlan(clusterName)
+	if err == coordinator.ErrClusterNotFound {
+		return orchestrator.RebalancePlan{}, ErrClusterNotFound
+	}
+
+	return plan, err
+}
+
+func (s *service) RebalanceCluster(_ context.Context, clusterName string) (orchestrator.RebalanceReport, error) {
+	report, err := s.coord.Rebalance(clusterName)
+	switch err {
+	case coordinator.ErrClusterNotFound:
+		return orchestrator.RebalanceReport{}, ErrClusterNotFound
+	case coordinator.ErrSwitchoversInProgress:
+		return orchestrator.RebalanceReport{}, ErrSwitchoversInProgress
+	case coordinator.ErrClusterReadOnly:
+		return orchestrator.RebalanceReport{}, ErrClusterReadOnly
+	}
+
+	return report, err
+}
+
+func (s *service) RebalanceReport(_ context.Context, clusterName string) (orchestrator.RebalanceReport, error) {
+	report, err := s.coord.RebalanceReport(clusterName)
+	switch err {
+	case coordinator.ErrClusterNotFound:
+		return orchestrator.RebalanceReport{}, ErrClusterNotFound
+	case coordinator.ErrReportNotFound:
+		return orchestrator.RebalanceReport{}, ErrReportNotFound
+	}
+
+	return report, err
+}
+
+func (s *service) DrainHost(ctx context.Context, clusterName, host string, req DrainRequest) (orchestrator.DrainReport, error) {
+	drain := storage.HostDrain{
+		ClusterName: clusterName,
+		Host:        host,
+		Drained:     true,
+		Author:      req.Author,
+		Reason:      req.Reason,
+		CreatedAt:   util.Timestamp(),
+	}
+
+	report, err := s.coord.DrainHost(ctx, drain)
+	switch err {
+	case coordinator.ErrClusterNotFound:
+		return orchestrator.DrainReport{}, ErrClusterNotFound
+	case coordinator.ErrSwitchoversInProgress:
+		return orchestrator.DrainReport{}, ErrSwitchoversInProgress
+	case coordinator.ErrClusterReadOnly:
+		return orchestrator.DrainReport{}, ErrClusterReadOnly
+	}
+
+	return report, err
+}
+
+func (s *service) DrainReport(_ context.Context, clusterName, host string) (orchestrator.DrainReport, error) {
+	report, err := s.coord.DrainReport(clusterName, host)
+	switch err {
+	case coordinator.ErrClusterNotFound:
+		return orchestrator.DrainReport{}, ErrClusterNotFound
+	case coordinator.ErrReportNotFound:
+		return orchestrator.DrainReport{}, ErrReportNotFound
+	}
+
+	return report, err
+}
+
+func (s *service) UndrainHost(ctx context.Context, clusterName, host string, req DrainRequest) (storage.HostDrain, error) {
+	drain := storage.HostDrain{
+		ClusterName: clusterName,
+		Host:        host,
+		Drained:     false,
+		Author:      req.Author,
+		Reason:      req.Reason,
+		CreatedAt:   util.Timestamp(),
+	}
+
+	err := s.coord.UndrainHost(ctx, drain)
+	if err == coordinator.ErrClusterNotFound {
+		return storage.HostDrain{}, ErrClusterNotFound
+	}
+
+	return drain, err
+}
+
+func (s *service) HostDrains(ctx context.Context, clusterName string) ([]storage.HostDrain, error) {
+	return s.db.GetHostDrains(ctx, clusterName)
+}
+
+func (s *service) ClusterHooks(_ context.Context, clusterName string) (map[orchestrator.HookType][]HookInfo, error) {
+	hooks, err := s.coord.ClusterHooks(clusterName)
+	if err != nil {
+		if err == coordinator.ErrClusterNotFound {
+			return nil, ErrClusterNotFound
+		}
+		return nil, err
+	}
+
+	resp := make(map[orchestrator.HookType][]HookInfo, len(hooks))
+	for t, list := range hooks {
+		infos := make([]HookInfo, 0, len(list))
+		for i := range list {
+			infos = append(infos, newHookInfo(&list[i]))
+		}
+		resp[t] = infos
+	}
+
+	return resp, nil
+}
+
+func (s *service) HookJobs(ctx context.Context, clusterName string, status orchestrator.HookJobStatus) ([]HookJobInfo, error) {
+	jobs, err := s.db.GetHookJobs(ctx, clusterName, status)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]HookJobInfo, 0, len(jobs))
+	for i := range jobs {
+		resp = append(resp, newHookJobInfo(&jobs[i]))
+	}
+
+	return resp, nil
+}
+
+func (s *service) RetryHookJob(ctx context.Context, clusterName string, id int64) (HookJobInfo, error) {
+	job, err := s.coord.RetryHookJob(ctx, clusterName, id)
+	switch err {
+	case nil:
+		return newHookJobInfo(&job), nil
+	case coordinator.ErrClusterNotFound:
+		return HookJobInfo{}, ErrClusterNotFound
+	case orchestrator.ErrHookJobNotFound:
+		return HookJobInfo{}, ErrHookJobNotFound
+	case orchestrator.ErrHookJobNotDead:
+		return HookJobInfo{}, ErrHookJobNotDead
+	}
+
+	return HookJobInfo{}, err
+}
+
 func routersAlerts(routers []vshard.Router) []RoutersAlerts {
 	result := make([]RoutersAlerts, 0)
 	for i := range routers {
@@ -1,6 +1,9 @@
 package api
 
-import "github.com/shmel1k/qumomf/internal/vshard"
+import (
+	"github.com/shmel1k/qumomf/internal/vshard"
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
+)
 
 type ClusterInfo struct {
 	Name         string             `json:"name"`
@@ -26,3 +29,116 @@ type RoutersAlerts struct {
 	URI    string         `json:"uri"`
 	Alerts []vshard.Alert `json:"alerts"`
 }
+
+// ReadOnlyRequest changes the readonly mode of the cluster in runtime.
+type ReadOnlyRequest struct {
+	ReadOnly *bool `json:"readonly"`
+	// RevertAfter is a period in seconds after which the readonly mode
+	// from the configuration is restored. Zero value means the change never expires.
+	RevertAfter int64  `json:"revert_after"`
+	Author      string `json:"author"`
+	Reason      string `json:"reason"`
+}
+
+// DrainRequest drains or undrains the host of the cluster.
+type DrainRequest struct {
+	Author string `json:"author"`
+	Reason string `json:"reason"`
+}
+
+// redacted replaces the values which might contain secrets.
+const redacted = "***"
+
+// HookInfo describes the hook executed for the cluster.
+// Values of the headers and environment variables are redacted.
+type HookInfo struct {
+	Name    string            `json:"name,omitempty"`
+	Command string            `json:"command,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Signed indicates whether the webhook requests are signed.
+	Signed       bool                    `json:"signed,omitempty"`
+	Timeout      string                  `json:"timeout,omitempty"`
+	Async        bool                    `json:"async"`
+	Retries      int                     `json:"retries"`
+	RetryBackoff string                  `json:"retry_backoff,omitempty"`
+	Env          map[string]string       `json:"env,omitempty"`
+	FailureTypes []string                `json:"failure_types,omitempty"`
+	Clusters     []string                `json:"clusters,omitempty"`
+	ReplicaSets  []vshard.ReplicaSetUUID `json:"replica_sets,omitempty"`
+}
+
+func newHookInfo(hook *orchestrator.Hook) HookInfo {
+	info := HookInfo{
+		Name:         hook.Name,
+		Command:      hook.Command,
+		Async:        hook.Async,
+		Retries:      hook.Retries,
+		Env:          redact(hook.Env),
+		FailureTypes: hook.Filter.FailureTypes,
+		Clusters:     hook.Filter.Clusters,
+		ReplicaSets:  hook.Filter.ReplicaSets,
+	}
+	if hook.Timeout > 0 {
+		info.Timeout = hook.Timeout.String()
+	}
+	if hook.Retries > 0 {
+		info.RetryBackoff = hook.RetryBackoff.String()
+	}
+	if hook.Webhook != nil {
+		info.URL = hook.Webhook.URL
+		info.Headers = redact(hook.Webhook.Headers)
+		info.Signed = hook.Webhook.Secret != ""
+	}
+
+	return info
+}
+
+// HookJobInfo describes the queued async hook.
+type HookJobInfo struct {
+	ID          int64                      `json:"id"`
+	ClusterName string                     `json:"cluster_name"`
+	Type        orchestrator.HookType      `json:"type"`
+	Name        string                     `json:"name,omitempty"`
+	Command     string                     `json:"command,omitempty"`
+	URL         string                     `json:"url,omitempty"`
+	Status      orchestrator.HookJobStatus `json:"status"`
+	Attempts    int                        `json:"attempts"`
+	MaxAttempts int                        `json:"max_attempts"`
+	// NextAttemptAt is a unix time in milliseconds of the next attempt.
+	NextAttemptAt int64                       `json:"next_attempt_at"`
+	LastResult    *orchestrator.HookExecution `json:"last_result,omitempty"`
+	CreatedAt     int64                       `json:"created_at"`
+	UpdatedAt     int64                       `json:"updated_at"`
+}
+
+func newHookJobInfo(job *orchestrator.HookJob) HookJobInfo {
+	return HookJobInfo{
+		ID:            job.ID,
+		ClusterName:   job.ClusterName,
+		Type:          job.Type,
+		Name:          job.Name,
+		Command:       job.Command,
+		URL:           job.URL,
+		Status:        job.Status,
+		Attempts:      job.Attempts,
+		MaxAttempts:   job.MaxAttempts,
+		NextAttemptAt: job.NextAttemptAt,
+		LastResult:    job.LastResult,
+		CreatedAt:     job.CreatedAt,
+		UpdatedAt:     job.UpdatedAt,
+	}
+}
+
+func redact(values map[string]string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	dst := make(map[string]string, len(values))
+	for k := range values {
+		dst[k] = redacted
+	}
+
+	return dst
+}
@@ -39,6 +39,9 @@ func TestSetup_ValidPath(t *testing.T) {
 	assert.Equal(t, 5*time.Second, cfg.Qumomf.ClusterRecoveryTime)
 	assert.Equal(t, 30*time.Minute, cfg.Qumomf.ShardRecoveryBlockTime)
 	assert.Equal(t, 10*time.Minute, cfg.Qumomf.InstanceRecoveryBlockTime)
+	assert.Equal(t, 2, cfg.Qumomf.MaxConcurrentRecoveries)
+	assert.Equal(t, 30*time.Second, cfg.Qumomf.RecoveryTimeout)
+	assert.Equal(t, 2, cfg.Qumomf.MaxCandidateAttempts)
 	assert.Equal(t, int64(500), cfg.Qumomf.ReasonableFollowerLSNLag)
 	assert.Equal(t, 1*time.Minute, cfg.Qumomf.ReasonableFollowerIdle)
 
@@ -46,14 +49,78 @@ func TestSetup_ValidPath(t *testing.T) {
 	assert.Equal(t, "bash", hooks.Shell)
 	assert.Equal(t, 5*time.Second, hooks.Timeout)
 	assert.Equal(t, 10*time.Minute, hooks.TimeoutAsync)
-	assert.Equal(t, []string{"echo 'Will recover from {failureType} on {failureCluster}' >> /tmp/qumomf_recovery.log"}, hooks.PreFailover)
-	assert.Equal(t, []string{"echo 'Recovered from {failureType} on {failureCluster}. Set: {failureReplicaSetUUID}; Failed: {failedURI}; Successor: {successorURI}' >> /tmp/qumomf_recovery.log"}, hooks.PostSuccessfulFailover)
-	assert.Equal(t, []string{"echo 'Failed to recover from {failureType} on {failureCluster}. Set: {failureReplicaSetUUID}; Failed: {failedURI}' >> /tmp/qumomf_recovery.log"}, hooks.PostUnsuccessfulFailover)
+	assert.Equal(t, []HookConfig{
+		{
+			Command:      "echo 'Will recover from {failureType} on {failureCluster}' >> /tmp/qumomf_recovery.log",
+			RetryBackoff: time.Second,
+		},
+		{
+			Name:         "page-oncall",
+			Command:      "page --cluster {failureCluster}",
+			Timeout:      10 * time.Second,
+			Async:        true,
+			Retries:      2,
+			RetryBackoff: time.Second,
+			Env:          map[string]string{"PAGER_TEAM": "storage"},
+			Filters: HookFilters{
+				FailureTypes: []string{"DeadMaster"},
+				Clusters:     []string{"qumomf_sandbox_1"},
+				ReplicaSets:  []string{"7432f072-c00b-4498-b1a6-6d9547a8a150"},
+			},
+		},
+		{
+			URL:          "https://incidents.example.com/qumomf",
+			Headers:      map[string]string{"Authorization": "Bearer token"},
+			Timeout:      2 * time.Second,
+			Retries:      3,
+			RetryBackoff: 500 * time.Millisecond,
+			Secret:       "secret",
+		},
+	}, hooks.PreFailover)
+	assert.Equal(t, []HookConfig{
+		{
+			Command:      "echo 'Recovered from {failureType} on {failureCluster}. Set: {failureReplicaSetUUID}; Failed: {failedURI}; Successor: {successorURI}' >> /tmp/qumomf_recovery.log",
+			RetryBackoff: time.Second,
+		},
+		{
+			URL:          "https://incidents.example.com/qumomf",
+			RetryBackoff: time.Second,
+			Async:        true,
+		},
+	}, hooks.PostSuccessfulFailover)
+	assert.Equal(t, []HookConfig{
+		{
+			Command:      "echo 'Failed to recover from {failureType} on {failureCluster}. Set: {failureReplicaSetUUID}; Failed: {failedURI}' >> /tmp/qumomf_recovery.log",
+			RetryBackoff: time.Second,
+		},
+	}, hooks.PostUnsuccessfulFailover)
+
+	notifications := cfg.Qumomf.Notifications
+	assert.Equal(t, time.Hour, notifications.RepeatInterval)
+	assert.Equal(t, defaultNotificationTimeout, notifications.Timeout)
+	assert.Equal(t, []NotificationSinkConfig{
+		{Name: "ops", Type: SinkTypeWebhook, URL: "https://alerts.example.com/qumomf"},
+		{
+			Name: "mail",
+			Type: SinkTypeSMTP,
+			SMTP: SMTPConfig{
+				Host: "smtp.example.com",
+				Port: 587,
+				From: "qumomf@example.com",
+				To:   []string{"oncall@example.com"},
+			},
+		},
+	}, notifications.Sinks)
+	assert.Equal(t, []NotificationRouteConfig{
+		{Severities: []string{"critical"}, Sinks: []string{"ops", "mail"}},
+	}, notifications.Routes)
 
 	storage := cfg.Qumomf.Storage
+	assert.Equal(t, StorageTypeSQLite, storage.Type)
 	assert.Equal(t, "sqlite.db", storage.Filename)
 	assert.Equal(t, time.Second, storage.QueryTimeout)
 	assert.Equal(t, time.Second, storage.ConnectTimeout)
+	assert.Equal(t, 72*time.Hour, storage.SnapshotRetention)
 
 	assert.Equal(t, 500*time.Millisecond, *cfg.Connection.ConnectTimeout)
 	assert.Equal(t, 1*time.Second, *cfg.Connection.RequestTimeout)
@@ -73,8 +140,11 @@ func TestSetup_ValidPath(t *testing.T) {
 				ConnectTimeout: newDuration(500 * time.Millisecond),
 				RequestTimeout: newDuration(1 * time.Second),
 			},
-			ReadOnly:     newBool(false),
-			ElectionMode: newString("smart"),
+			ReadOnly:                newBool(false),
+			ElectionMode:            newString("smart"),
+			MaxConcurrentRecoveries: newInt(2),
+			ElectorWeights:          &defaultElectorWeights,
+			MasterAntiAffinity:      newBool(false),
 			OverrideURIRules: map[string]string{
 				"qumomf_1_m.ddk:3301": "127.0.0.1:9303",
 			},
@@ -96,13 +166,47 @@ func TestSetup_ValidPath(t *testing.T) {
 				ConnectTimeout: newDuration(10 * time.Second),
 				RequestTimeout: newDuration(10 * time.Second),
 			},
-			ReadOnly:     newBool(true),
-			ElectionMode: newString("idle"),
+			ReadOnly:                newBool(true),
+			ElectionMode:            newString("idle"),
+			MaxConcurrentRecoveries: newInt(5),
 			Priorities: map[string]int{
 				"bd64dd00-161e-4c99-8b3c-d3c4635e18d2": 10,
 				"cc4cfb9c-11d8-4810-84d2-66cfbebb0f6e": 5,
 				"a3ef657e-eb9a-4730-b420-7ea78d52797d": -1,
 			},
+			Zones: map[string]string{
+				"bd64dd00-161e-4c99-8b3c-d3c4635e18d2": "dc1",
+				"cc4cfb9c-11d8-4810-84d2-66cfbebb0f6e": "dc2",
+			},
+			ZonePolicy: ZonePolicy{
+				PreferMasterZone: true,
+				PreferredZones:   []string{"dc1", "dc2"},
+				ForbiddenZones:   []string{"dc3"},
+			},
+			Hosts: map[string]string{
+				"bd64dd00-161e-4c99-8b3c-d3c4635e18d2": "host1",
+			},
+			MasterAntiAffinity: newBool(true),
+			Hooks: &ClusterHooksConfig{
+				Merge: HooksMergeReplace,
+				HooksConfig: HooksConfig{
+					PreFailover: []HookConfig{
+						{
+							Command:      "echo 'sandbox2' >> /tmp/qumomf_recovery.log",
+							RetryBackoff: time.Second,
+						},
+					},
+				},
+			},
+			ElectorWeights: &ElectorWeights{
+				LSNLag:           4,
+				Idle:             5,
+				UpstreamStatus:   4,
+				Fingerprint:      8,
+				Priority:         2,
+				Zone:             1,
+				MasterColocation: 0,
+			},
 			Routers: []RouterConfig{
 				{
 					Name: "sandbox2-router1",
@@ -123,3 +227,66 @@ func TestSetup_InvalidElectorOption(t *testing.T) {
 	require.NotNil(t, err)
 	assert.Nil(t, cfg)
 }
+
+func TestConfig_EffectiveHooks(t *testing.T) {
+	hook := func(command string) HookConfig {
+		return HookConfig{Command: command}
+	}
+
+	cfg := &Config{}
+	cfg.Qumomf.Hooks.PreFailover = []HookConfig{hook("global_pre"), {URL: "http://example.com"}}
+	cfg.Qumomf.Hooks.PostSuccessfulFailover = []HookConfig{hook("global_post")}
+	cfg.Qumomf.Hooks.ProblemDetected = []HookConfig{{URL: "http://example.com/detected"}}
+
+	tests := []struct {
+		name     string
+		hooks    *ClusterHooksConfig
+		expected HooksConfig
+	}{
+		{
+			name: "NoClusterHooks",
+			expected: HooksConfig{
+				PreFailover:            []HookConfig{hook("global_pre"), {URL: "http://example.com"}},
+				PostSuccessfulFailover: []HookConfig{hook("global_post")},
+				ProblemDetected:        []HookConfig{{URL: "http://example.com/detected"}},
+			},
+		},
+		{
+			name: "Extend",
+			hooks: &ClusterHooksConfig{
+				HooksConfig: HooksConfig{
+					PreFailover:              []HookConfig{hook("cluster_pre")},
+					PostUnsuccessfulFailover: []HookConfig{hook("cluster_post")},
+					PostSwitchover:           []HookConfig{hook("cluster_switchover")},
+				},
+			},
+			expected: HooksConfig{
+				PreFailover:              []HookConfig{hook("global_pre"), {URL: "http://example.com"}, hook("cluster_pre")},
+				PostSuccessfulFailover:   []HookConfig{hook("global_post")},
+				PostUnsuccessfulFailover: []HookConfig{hook("cluster_post")},
+				ProblemDetected:          []HookConfig{{URL: "http://example.com/detected"}},
+				PostSwitchover:           []HookConfig{hook("cluster_switchover")},
+			},
+		},
+		{
+			name: "Replace",
+			hooks: &ClusterHooksConfig{
+				Merge: HooksMergeReplace,
+				HooksConfig: HooksConfig{
+					PreFailover: []HookConfig{hook("cluster_pre")},
+				},
+			},
+			expected: HooksConfig{
+				PreFailover: []HookConfig{hook("cluster_pre")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := cfg.EffectiveHooks(ClusterConfig{Hooks: tt.hooks})
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
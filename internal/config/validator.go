@@ -1,15 +1,206 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+)
 
 func validateElector(v *string) error {
 	if v == nil {
 		return fmt.Errorf("option 'elector' must not be empty")
 	}
 
-	if *v != "idle" && *v != "smart" {
+	if *v != "idle" && *v != "smart" && *v != "priority" && *v != "weighted" {
 		return fmt.Errorf("option 'elector' has a wrong value:: %s", *v)
 	}
 
 	return nil
 }
+
+func validateZonePolicy(p ZonePolicy) error {
+	forbidden := make(map[string]struct{}, len(p.ForbiddenZones))
+	for _, zone := range p.ForbiddenZones {
+		forbidden[zone] = struct{}{}
+	}
+
+	for _, zone := range p.PreferredZones {
+		if _, ok := forbidden[zone]; ok {
+			return fmt.Errorf("option 'zone_policy' has zone %s both preferred and forbidden", zone)
+		}
+	}
+
+	return nil
+}
+
+func validateElectorWeights(w *ElectorWeights) error {
+	if w == nil {
+		return nil
+	}
+
+	weights := map[string]float64{
+		"lsn_lag":           w.LSNLag,
+		"idle":              w.Idle,
+		"upstream_status":   w.UpstreamStatus,
+		"fingerprint":       w.Fingerprint,
+		"priority":          w.Priority,
+		"zone":              w.Zone,
+		"master_colocation": w.MasterColocation,
+	}
+	for name, v := range weights {
+		if v < 0 {
+			return fmt.Errorf("option 'elector_weights.%s' must not be negative: %v", name, v)
+		}
+	}
+
+	return nil
+}
+
+func validateHooks(hooks []HookConfig) error {
+	for _, hook := range hooks { //nolint:gocritic
+		if (hook.Command == "") == (hook.URL == "") {
+			return fmt.Errorf("hook %q must have either 'command' or 'url'", hook.Name)
+		}
+
+		if hook.Template && hook.Command == "" {
+			return fmt.Errorf("option 'template' of hook %q is allowed only with 'command'", hook.Name)
+		}
+
+		if hook.URL != "" {
+			u, err := url.Parse(hook.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("option 'url' of hook %q has a wrong value: %s", hook.Name, hook.URL)
+			}
+		}
+
+		if hook.Retries < 0 {
+			return fmt.Errorf("option 'retries' of hook %q must not be negative: %d", hook.Name, hook.Retries)
+		}
+
+		if hook.Timeout < 0 || hook.RetryBackoff < 0 {
+			return fmt.Errorf("options 'timeout' and 'retry_backoff' of hook %q must not be negative", hook.Name)
+		}
+	}
+
+	return nil
+}
+
+func validateHooksConfig(c HooksConfig) error {
+	for _, hooks := range c.All() {
+		err := validateHooks(hooks)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateClusterHooks(c *ClusterHooksConfig) error {
+	if c == nil {
+		return nil
+	}
+
+	if c.Merge != "" && c.Merge != HooksMergeExtend && c.Merge != HooksMergeReplace {
+		return fmt.Errorf("option 'hooks.merge' has a wrong value: %s", c.Merge)
+	}
+
+	return validateHooksConfig(c.HooksConfig)
+}
+
+func validateStorage(c *StorageConfig) error {
+	switch c.Type {
+	case StorageTypeSQLite:
+		if c.Filename == "" {
+			return fmt.Errorf("option 'storage.filename' is required by sqlite storage")
+		}
+	case StorageTypePostgres:
+		if c.DSN == "" {
+			return fmt.Errorf("option 'storage.dsn' is required by postgres storage")
+		}
+	case StorageTypeTarantool:
+		if c.Addr == "" {
+			return fmt.Errorf("option 'storage.addr' is required by tarantool storage")
+		}
+	case StorageTypeMemory:
+	default:
+		return fmt.Errorf("option 'storage.type' has a wrong value: %s", c.Type)
+	}
+
+	if c.SnapshotRetention < 0 {
+		return fmt.Errorf("option 'storage.snapshot_retention' must not be negative")
+	}
+
+	return nil
+}
+
+func validateNotifications(c *NotificationsConfig) error {
+	if c.RepeatInterval < 0 || c.Timeout < 0 {
+		return fmt.Errorf("options 'notifications.repeat_interval' and 'notifications.timeout' must not be negative")
+	}
+
+	sinks := make(map[string]struct{}, len(c.Sinks))
+	for _, sink := range c.Sinks { //nolint:gocritic
+		if sink.Name == "" {
+			return fmt.Errorf("notification sink must have a name")
+		}
+		if _, ok := sinks[sink.Name]; ok {
+			return fmt.Errorf("notification sink %q is defined more than once", sink.Name)
+		}
+		sinks[sink.Name] = struct{}{}
+
+		switch sink.Type {
+		case SinkTypeWebhook, SinkTypeSlack:
+			u, err := url.Parse(sink.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("option 'url' of notification sink %q has a wrong value: %s", sink.Name, sink.URL)
+			}
+		case SinkTypeSMTP:
+			if sink.SMTP.Host == "" || sink.SMTP.Port <= 0 || sink.SMTP.From == "" || len(sink.SMTP.To) == 0 {
+				return fmt.Errorf("notification sink %q must have 'smtp.host', 'smtp.port', 'smtp.from' and 'smtp.to'", sink.Name)
+			}
+		default:
+			return fmt.Errorf("option 'type' of notification sink %q has a wrong value: %s", sink.Name, sink.Type)
+		}
+	}
+
+	for _, route := range c.Routes {
+		if len(route.Sinks) == 0 {
+			return fmt.Errorf("notification route must have at least one sink")
+		}
+		for _, name := range route.Sinks {
+			if _, ok := sinks[name]; !ok {
+				return fmt.Errorf("notification route refers to unknown sink %q", name)
+			}
+		}
+		for _, severity := range route.Severities {
+			if severity != "critical" && severity != "warning" && severity != "info" {
+				return fmt.Errorf("notification route has a wrong severity: %s", severity)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateAlertmanager(c *AlertmanagerConfig) error {
+	if len(c.URLs) == 0 {
+		return nil
+	}
+
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("option 'alertmanager.refresh_interval' must be positive")
+	}
+
+	if c.Timeout < 0 {
+		return fmt.Errorf("option 'alertmanager.timeout' must not be negative")
+	}
+
+	for _, v := range c.URLs {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("option 'alertmanager.urls' has a wrong value: %s", v)
+		}
+	}
+
+	return nil
+}
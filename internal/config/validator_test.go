@@ -0,0 +1,279 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateElector(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       *string
+		wantErr bool
+	}{
+		{name: "Empty", v: nil, wantErr: true},
+		{name: "Idle", v: newString("idle")},
+		{name: "Smart", v: newString("smart")},
+		{name: "Priority", v: newString("priority")},
+		{name: "Weighted", v: newString("weighted")},
+		{name: "Unknown", v: newString("unknown"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateElector(tt.v)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateZonePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       ZonePolicy
+		wantErr bool
+	}{
+		{name: "Empty", p: ZonePolicy{}},
+		{
+			name: "Valid",
+			p: ZonePolicy{
+				PreferMasterZone: true,
+				PreferredZones:   []string{"dc1", "dc2"},
+				ForbiddenZones:   []string{"dc3"},
+			},
+		},
+		{
+			name: "PreferredAndForbidden",
+			p: ZonePolicy{
+				PreferredZones: []string{"dc1", "dc2"},
+				ForbiddenZones: []string{"dc2"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateZonePolicy(tt.p)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateElectorWeights(t *testing.T) {
+	weights := defaultElectorWeights
+	assert.Nil(t, validateElectorWeights(&weights))
+	assert.Nil(t, validateElectorWeights(nil))
+
+	weights.Idle = -1
+	assert.NotNil(t, validateElectorWeights(&weights))
+}
+
+func Test_validateHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    HookConfig
+		wantErr bool
+	}{
+		{name: "Command", hook: HookConfig{Command: "echo 1"}},
+		{name: "Webhook", hook: HookConfig{URL: "https://example.com/hook", Retries: 3}},
+		{name: "Empty", hook: HookConfig{}, wantErr: true},
+		{name: "CommandAndURL", hook: HookConfig{Command: "echo 1", URL: "https://example.com/hook"}, wantErr: true},
+		{name: "WrongScheme", hook: HookConfig{URL: "ftp://example.com/hook"}, wantErr: true},
+		{name: "NegativeRetries", hook: HookConfig{URL: "http://example.com", Retries: -1}, wantErr: true},
+		{name: "NegativeTimeout", hook: HookConfig{Command: "echo 1", Timeout: -1}, wantErr: true},
+		{name: "Template", hook: HookConfig{Command: "echo {{.ClusterName}}", Template: true}},
+		{name: "WebhookTemplate", hook: HookConfig{URL: "https://example.com/hook", Template: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHooks([]HookConfig{tt.hook})
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateClusterHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   *ClusterHooksConfig
+		wantErr bool
+	}{
+		{name: "Empty", hooks: nil},
+		{name: "Extend", hooks: &ClusterHooksConfig{Merge: HooksMergeExtend}},
+		{name: "Replace", hooks: &ClusterHooksConfig{Merge: HooksMergeReplace}},
+		{name: "UnknownMerge", hooks: &ClusterHooksConfig{Merge: "override"}, wantErr: true},
+		{
+			name: "InvalidHook",
+			hooks: &ClusterHooksConfig{
+				HooksConfig: HooksConfig{
+					PreFailover: []HookConfig{{}},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateClusterHooks(tt.hooks)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateNotifications(t *testing.T) {
+	webhook := NotificationSinkConfig{Name: "ops", Type: SinkTypeWebhook, URL: "https://example.com/notify"}
+	smtp := NotificationSinkConfig{
+		Name: "mail",
+		Type: SinkTypeSMTP,
+		SMTP: SMTPConfig{Host: "localhost", Port: 25, From: "qumomf@example.com", To: []string{"ops@example.com"}},
+	}
+
+	tests := []struct {
+		name    string
+		cfg     NotificationsConfig
+		wantErr bool
+	}{
+		{name: "Empty", cfg: NotificationsConfig{}},
+		{
+			name: "Valid",
+			cfg: NotificationsConfig{
+				Sinks:  []NotificationSinkConfig{webhook, smtp},
+				Routes: []NotificationRouteConfig{{Severities: []string{"critical"}, Sinks: []string{"ops", "mail"}}},
+			},
+		},
+		{name: "NegativeRepeat", cfg: NotificationsConfig{RepeatInterval: -1}, wantErr: true},
+		{name: "DuplicateSink", cfg: NotificationsConfig{Sinks: []NotificationSinkConfig{webhook, webhook}}, wantErr: true},
+		{
+			name:    "UnknownType",
+			cfg:     NotificationsConfig{Sinks: []NotificationSinkConfig{{Name: "ops", Type: "pager"}}},
+			wantErr: true,
+		},
+		{
+			name:    "WrongURL",
+			cfg:     NotificationsConfig{Sinks: []NotificationSinkConfig{{Name: "ops", Type: SinkTypeSlack, URL: "example.com"}}},
+			wantErr: true,
+		},
+		{
+			name:    "IncompleteSMTP",
+			cfg:     NotificationsConfig{Sinks: []NotificationSinkConfig{{Name: "mail", Type: SinkTypeSMTP}}},
+			wantErr: true,
+		},
+		{
+			name: "UnknownRouteSink",
+			cfg: NotificationsConfig{
+				Sinks:  []NotificationSinkConfig{webhook},
+				Routes: []NotificationRouteConfig{{Sinks: []string{"mail"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "WrongSeverity",
+			cfg: NotificationsConfig{
+				Sinks:  []NotificationSinkConfig{webhook},
+				Routes: []NotificationRouteConfig{{Severities: []string{"fatal"}, Sinks: []string{"ops"}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNotifications(&tt.cfg)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     StorageConfig
+		wantErr bool
+	}{
+		{name: "SQLite", cfg: StorageConfig{Type: StorageTypeSQLite, Filename: "qumomf.db"}},
+		{name: "SQLiteNoFilename", cfg: StorageConfig{Type: StorageTypeSQLite}, wantErr: true},
+		{name: "Postgres", cfg: StorageConfig{Type: StorageTypePostgres, DSN: "postgres://localhost:5432/qumomf"}},
+		{name: "PostgresNoDSN", cfg: StorageConfig{Type: StorageTypePostgres}, wantErr: true},
+		{name: "Tarantool", cfg: StorageConfig{Type: StorageTypeTarantool, Addr: "localhost:3301"}},
+		{name: "TarantoolNoAddr", cfg: StorageConfig{Type: StorageTypeTarantool}, wantErr: true},
+		{name: "Memory", cfg: StorageConfig{Type: StorageTypeMemory}},
+		{name: "UnknownType", cfg: StorageConfig{Type: "mysql"}, wantErr: true},
+		{
+			name:    "NegativeRetention",
+			cfg:     StorageConfig{Type: StorageTypeSQLite, Filename: "qumomf.db", SnapshotRetention: -1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStorage(&tt.cfg)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateAlertmanager(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AlertmanagerConfig
+		wantErr bool
+	}{
+		{name: "Disabled", cfg: AlertmanagerConfig{}},
+		{name: "Valid", cfg: AlertmanagerConfig{URLs: []string{"http://alertmanager:9093"}, RefreshInterval: time.Second}},
+		{name: "ZeroRefresh", cfg: AlertmanagerConfig{URLs: []string{"http://alertmanager:9093"}}, wantErr: true},
+		{
+			name:    "NegativeTimeout",
+			cfg:     AlertmanagerConfig{URLs: []string{"http://alertmanager:9093"}, RefreshInterval: time.Second, Timeout: -1},
+			wantErr: true,
+		},
+		{name: "WrongURL", cfg: AlertmanagerConfig{URLs: []string{"alertmanager:9093"}, RefreshInterval: time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlertmanager(&tt.cfg)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
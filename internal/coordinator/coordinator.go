@@ -3,10 +3,15 @@ package coordinator
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
 
+	"github.com/shmel1k/qumomf/internal/alertmanager"
 	"github.com/shmel1k/qumomf/internal/config"
+	"github.com/shmel1k/qumomf/internal/notifier"
 	"github.com/shmel1k/qumomf/internal/quorum"
 	"github.com/shmel1k/qumomf/internal/storage"
+	"github.com/shmel1k/qumomf/internal/util"
 	"github.com/shmel1k/qumomf/internal/vshard"
 	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
 
@@ -14,9 +19,17 @@ import (
 )
 
 var (
-	ErrClusterAlreadyExist = errors.New("cluster with such name already registered")
+	ErrClusterAlreadyExist   = errors.New("cluster with such name already registered")
+	ErrClusterNotFound       = errors.New("cluster with such name not registered")
+	ErrSwitchoversInProgress = errors.New("planned switchovers of the cluster are already in progress")
+	ErrClusterReadOnly       = errors.New("cluster is in readonly mode")
+	ErrReportNotFound        = errors.New("no planned switchovers have been started")
 )
 
+// revertAuthor is an author of the audit entries
+// restoring the configured readonly mode of the cluster.
+const revertAuthor = "qumomf"
+
 type shutdownTask func()
 
 type Coordinator struct {
@@ -26,22 +39,84 @@ type Coordinator struct {
 	// which Qumomf observes.
 	clusters map[string]*vshard.Cluster
 
+	// failovers contains the failovers of the registered clusters.
+	failovers map[string]orchestrator.Failover
+
+	// hookers contains the hookers of the registered clusters.
+	hookers map[string]*orchestrator.Hooker
+
+	// hookQueues contains the async hook queues of the registered clusters.
+	hookQueues map[string]*orchestrator.HookQueue
+
+	// switching contains the clusters which masters are being
+	// moved by the planned switchovers at the moment.
+	switching map[string]bool
+
+	// rebalances contains the reports of the last rebalance of the clusters.
+	rebalances map[string]orchestrator.RebalanceReport
+
+	// drains contains the reports of the last drain of the cluster hosts.
+	drains map[string]map[string]orchestrator.DrainReport
+
+	// switchoversWG tracks the planned switchovers running
+	// in background, so shutdown waits for them.
+	switchoversWG sync.WaitGroup
+
+	// readOnly contains the readonly mode of the registered
+	// clusters defined in the configuration.
+	readOnly map[string]bool
+
+	// reverts contains the timers restoring the configured
+	// readonly mode when runtime overrides expire.
+	reverts map[string]*time.Timer
+
+	mutex sync.Mutex
+
 	// shutdownQueue contains all shutdown tasks to be
 	// executed when coordinator is going to exit.
 	shutdownQueue []shutdownTask
 
 	db storage.Storage
+
+	// notifier is notified about the analyses of all clusters.
+	notifier *notifier.Notifier
+
+	// alerts pushes the problems of all clusters to Alertmanager.
+	alerts *alertmanager.Pusher
 }
 
 func New(logger zerolog.Logger, db storage.Storage) *Coordinator {
 	return &Coordinator{
-		logger:   logger,
-		clusters: make(map[string]*vshard.Cluster),
-		db:       db,
+		logger:     logger,
+		clusters:   make(map[string]*vshard.Cluster),
+		failovers:  make(map[string]orchestrator.Failover),
+		hookers:    make(map[string]*orchestrator.Hooker),
+		hookQueues: make(map[string]*orchestrator.HookQueue),
+		switching:  make(map[string]bool),
+		rebalances: make(map[string]orchestrator.RebalanceReport),
+		drains:     make(map[string]map[string]orchestrator.DrainReport),
+		readOnly:   make(map[string]bool),
+		reverts:    make(map[string]*time.Timer),
+		db:         db,
 	}
 }
 
+// SetNotifier sets the notifier of the replica set state changes.
+// It must be called before the clusters are registered.
+func (c *Coordinator) SetNotifier(n *notifier.Notifier) {
+	c.notifier = n
+}
+
+// SetAlertPusher sets the pusher of the alerts to Alertmanager.
+// It must be called before the clusters are registered.
+func (c *Coordinator) SetAlertPusher(p *alertmanager.Pusher) {
+	c.alerts = p
+}
+
 func (c *Coordinator) RegisterCluster(name string, cfg config.ClusterConfig, globalCfg *config.Config) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if _, exist := c.clusters[name]; exist {
 		return ErrClusterAlreadyExist
 	}
@@ -52,7 +127,10 @@ func (c *Coordinator) RegisterCluster(name string, cfg config.ClusterConfig, glo
 	cluster.SetLogger(clusterLogger)
 	cluster.SetOnClusterDiscovered(c.onClusterDiscovered)
 	c.clusters[name] = cluster
+	c.readOnly[name] = *cfg.ReadOnly
 	c.addShutdownTask(cluster.Shutdown)
+	c.restoreReadOnlyOverride(cluster)
+	c.restoreHostDrains(cluster)
 
 	mon := orchestrator.NewMonitor(cluster, orchestrator.Config{
 		RecoveryPollTime:  globalCfg.Qumomf.ClusterRecoveryTime,
@@ -60,18 +138,47 @@ func (c *Coordinator) RegisterCluster(name string, cfg config.ClusterConfig, glo
 	}, clusterLogger)
 	c.addShutdownTask(mon.Shutdown)
 
-	hooker := initHooker(globalCfg, clusterLogger)
+	hooker := initHooker(globalCfg, cfg, clusterLogger)
+	c.hookers[name] = hooker
+	hookQueue := orchestrator.NewHookQueue(name, c.db, hooker, clusterLogger)
+	hooker.SetQueue(hookQueue)
+	c.hookQueues[name] = hookQueue
+	hookQueue.Serve()
+	c.addShutdownTask(hookQueue.Shutdown)
 	elector := quorum.New(quorum.Mode(*cfg.ElectionMode), quorum.Options{
 		ReasonableFollowerLSNLag: globalCfg.Qumomf.ReasonableFollowerLSNLag,
 		ReasonableFollowerIdle:   globalCfg.Qumomf.ReasonableFollowerIdle.Seconds(),
+		ZonePolicy: quorum.ZonePolicy{
+			PreferMasterZone: cfg.ZonePolicy.PreferMasterZone,
+			PreferredZones:   cfg.ZonePolicy.PreferredZones,
+			ForbiddenZones:   cfg.ZonePolicy.ForbiddenZones,
+		},
+		Weights: quorum.Weights{
+			LSNLag:           cfg.ElectorWeights.LSNLag,
+			Idle:             cfg.ElectorWeights.Idle,
+			UpstreamStatus:   cfg.ElectorWeights.UpstreamStatus,
+			Fingerprint:      cfg.ElectorWeights.Fingerprint,
+			Priority:         cfg.ElectorWeights.Priority,
+			Zone:             cfg.ElectorWeights.Zone,
+			MasterColocation: cfg.ElectorWeights.MasterColocation,
+		},
+		MasterAntiAffinity: *cfg.MasterAntiAffinity,
+		ReplicaSets:        cluster.ReplicaSets,
 	})
 	failover := orchestrator.NewDefaultFailover(cluster, orchestrator.FailoverConfig{
 		Hooker:                      hooker,
 		Elector:                     elector,
 		ReplicaSetRecoveryBlockTime: globalCfg.Qumomf.ShardRecoveryBlockTime,
 		InstanceRecoveryBlockTime:   globalCfg.Qumomf.InstanceRecoveryBlockTime,
+		RecoveryTimeout:             globalCfg.Qumomf.RecoveryTimeout,
+		MaxCandidateAttempts:        globalCfg.Qumomf.MaxCandidateAttempts,
+		MaxConcurrentRecoveries:     *cfg.MaxConcurrentRecoveries,
 	}, clusterLogger)
 	failover.SetOnClusterRecovered(c.onClusterRecovered)
+	failover.SetOnAnalysis(func(analysis orchestrator.ReplicationAnalysis) {
+		c.onAnalysis(name, analysis)
+	})
+	c.failovers[name] = failover
 
 	c.addShutdownTask(failover.Shutdown)
 
@@ -82,12 +189,25 @@ func (c *Coordinator) RegisterCluster(name string, cfg config.ClusterConfig, glo
 }
 
 func (c *Coordinator) onClusterDiscovered(clusterName string, snapshot vshard.Snapshot) {
+	if c.alerts != nil {
+		c.alerts.ObserveSnapshot(clusterName, snapshot)
+	}
+
 	err := c.db.SaveSnapshot(context.Background(), clusterName, snapshot)
 	if err != nil {
 		c.logger.Err(err).Str("cluster_name", clusterName).Msg("failed to save cluster snapshot")
 	}
 }
 
+func (c *Coordinator) onAnalysis(clusterName string, analysis orchestrator.ReplicationAnalysis) {
+	if c.notifier != nil {
+		c.notifier.Observe(clusterName, analysis)
+	}
+	if c.alerts != nil {
+		c.alerts.ObserveAnalysis(clusterName, analysis)
+	}
+}
+
 func (c *Coordinator) onClusterRecovered(recovery orchestrator.Recovery) {
 	err := c.db.SaveRecovery(context.Background(), recovery)
 	if err != nil {
@@ -95,7 +215,395 @@ func (c *Coordinator) onClusterRecovered(recovery orchestrator.Recovery) {
 	}
 }
 
+// SetReadOnly overrides the readonly mode of the registered cluster in runtime.
+// The override is persisted and restored after qumomf restart.
+// If the override has the revert time, the readonly mode
+// from the configuration is restored after it.
+func (c *Coordinator) SetReadOnly(ctx context.Context, override storage.ReadOnlyOverride) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cluster, ok := c.clusters[override.ClusterName]
+	if !ok {
+		return ErrClusterNotFound
+	}
+
+	err := c.db.SaveReadOnlyOverride(ctx, override)
+	if err != nil {
+		return err
+	}
+
+	c.applyReadOnlyOverride(cluster, override)
+
+	return nil
+}
+
+// ClusterHooks returns the effective hooks of the registered cluster.
+func (c *Coordinator) ClusterHooks(name string) (map[orchestrator.HookType][]orchestrator.Hook, error) {
+	c.mutex.Lock()
+	hooker, ok := c.hookers[name]
+	c.mutex.Unlock()
+	if !ok {
+		return nil, ErrClusterNotFound
+	}
+
+	return hooker.Hooks(), nil
+}
+
+// RetryHookJob re-runs the dead async hook job of the registered cluster.
+func (c *Coordinator) RetryHookJob(ctx context.Context, name string, id int64) (orchestrator.HookJob, error) {
+	c.mutex.Lock()
+	queue, ok := c.hookQueues[name]
+	c.mutex.Unlock()
+	if !ok {
+		return orchestrator.HookJob{}, ErrClusterNotFound
+	}
+
+	return queue.Retry(ctx, id)
+}
+
+// RebalancePlan returns the switchovers needed to distribute
+// masters of the registered cluster evenly across hosts and zones.
+func (c *Coordinator) RebalancePlan(name string) (orchestrator.RebalancePlan, error) {
+	c.mutex.Lock()
+	cluster, ok := c.clusters[name]
+	c.mutex.Unlock()
+	if !ok {
+		return orchestrator.RebalancePlan{}, ErrClusterNotFound
+	}
+
+	return orchestrator.PlanRebalance(cluster.ReplicaSets()), nil
+}
+
+// Rebalance plans the switchovers and starts executing them one at a time in background.
+// The execution is stopped on the first failed switchover.
+// It returns the initial report, the progress is available via RebalanceReport.
+func (c *Coordinator) Rebalance(name string) (orchestrator.RebalanceReport, error) {
+	cluster, failover, err := c.startSwitchovers(name)
+	if err != nil {
+		return orchestrator.RebalanceReport{}, err
+	}
+
+	report := orchestrator.RebalanceReport{
+		Plan:       orchestrator.PlanRebalance(cluster.ReplicaSets()),
+		Results:    make([]orchestrator.SwitchoverResult, 0),
+		InProgress: true,
+	}
+	c.mutex.Lock()
+	c.rebalances[name] = report
+	c.mutex.Unlock()
+
+	logger := c.logger.With().Str("cluster", name).Logger()
+	logger.Info().Int("switchovers", len(report.Plan.Switchovers)).Msg("Starting masters rebalancing")
+
+	c.runSwitchovers(name, func() {
+		_, completed := orchestrator.ExecuteSwitchovers(
+			context.Background(), cluster, failover, report.Plan.Switchovers,
+			func(result orchestrator.SwitchoverResult) {
+				c.updateRebalanceReport(name, func(r *orchestrator.RebalanceReport) {
+					r.Results = append(r.Results, result)
+				})
+			}, logger,
+		)
+		c.updateRebalanceReport(name, func(r *orchestrator.RebalanceReport) {
+			r.InProgress = false
+			r.Completed = completed
+		})
+	})
+
+	return report, nil
+}
+
+// RebalanceReport returns the report of the last rebalance of the registered cluster.
+func (c *Coordinator) RebalanceReport(name string) (orchestrator.RebalanceReport, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.clusters[name]; !ok {
+		return orchestrator.RebalanceReport{}, ErrClusterNotFound
+	}
+	report, ok := c.rebalances[name]
+	if !ok {
+		return orchestrator.RebalanceReport{}, ErrReportNotFound
+	}
+	report.Results = copyResults(report.Results)
+
+	return report, nil
+}
+
+func (c *Coordinator) updateRebalanceReport(name string, update func(*orchestrator.RebalanceReport)) {
+	c.mutex.Lock()
+	report := c.rebalances[name]
+	update(&report)
+	c.rebalances[name] = report
+	c.mutex.Unlock()
+}
+
+// DrainHost excludes the instances on the host from the election
+// and starts moving all masters away from the host one at a time in background.
+// The host stays drained until UndrainHost is called.
+// It returns the initial report, the progress is available via DrainReport.
+func (c *Coordinator) DrainHost(ctx context.Context, drain storage.HostDrain) (orchestrator.DrainReport, error) {
+	cluster, failover, err := c.startSwitchovers(drain.ClusterName)
+	if err != nil {
+		return orchestrator.DrainReport{}, err
+	}
+
+	err = c.db.SaveHostDrain(ctx, drain)
+	if err != nil {
+		c.finishSwitchovers(drain.ClusterName)
+		return orchestrator.DrainReport{}, err
+	}
+	cluster.SetHostDrained(drain.Host, true)
+
+	logger := c.logger.With().Str("cluster", drain.ClusterName).Str("host", drain.Host).Logger()
+	logger.Warn().
+		Str("author", drain.Author).
+		Str("reason", drain.Reason).
+		Msg("Host has been drained")
+
+	report := orchestrator.DrainReport{
+		Host:       drain.Host,
+		Results:    make([]orchestrator.SwitchoverResult, 0),
+		InProgress: true,
+	}
+	report.Switchovers, report.Unmovable = orchestrator.PlanDrain(cluster.ReplicaSets(), drain.Host)
+	c.mutex.Lock()
+	if c.drains[drain.ClusterName] == nil {
+		c.drains[drain.ClusterName] = make(map[string]orchestrator.DrainReport)
+	}
+	c.drains[drain.ClusterName][drain.Host] = report
+	c.mutex.Unlock()
+
+	c.runSwitchovers(drain.ClusterName, func() {
+		_, completed := orchestrator.ExecuteSwitchovers(
+			context.Background(), cluster, failover, report.Switchovers,
+			func(result orchestrator.SwitchoverResult) {
+				c.updateDrainReport(drain.ClusterName, drain.Host, func(r *orchestrator.DrainReport) {
+					r.Results = append(r.Results, result)
+				})
+			}, logger,
+		)
+		c.updateDrainReport(drain.ClusterName, drain.Host, func(r *orchestrator.DrainReport) {
+			r.InProgress = false
+			r.Completed = completed && len(r.Unmovable) == 0
+		})
+	})
+
+	return report, nil
+}
+
+// DrainReport returns the report of the last drain of the host of the registered cluster.
+func (c *Coordinator) DrainReport(name, host string) (orchestrator.DrainReport, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.clusters[name]; !ok {
+		return orchestrator.DrainReport{}, ErrClusterNotFound
+	}
+	report, ok := c.drains[name][host]
+	if !ok {
+		return orchestrator.DrainReport{}, ErrReportNotFound
+	}
+	report.Results = copyResults(report.Results)
+
+	return report, nil
+}
+
+func (c *Coordinator) updateDrainReport(name, host string, update func(*orchestrator.DrainReport)) {
+	c.mutex.Lock()
+	report := c.drains[name][host]
+	update(&report)
+	c.drains[name][host] = report
+	c.mutex.Unlock()
+}
+
+// runSwitchovers runs the planned switchovers of the cluster in background.
+// Switchovers must not be interrupted when the client goes away,
+// the cluster is released when they are done.
+func (c *Coordinator) runSwitchovers(name string, run func()) {
+	c.switchoversWG.Add(1)
+	go func() {
+		defer c.switchoversWG.Done()
+		defer c.finishSwitchovers(name)
+
+		run()
+	}()
+}
+
+// copyResults returns a copy of the results, so the report might be
+// read while the running switchovers append new results.
+func copyResults(results []orchestrator.SwitchoverResult) []orchestrator.SwitchoverResult {
+	dst := make([]orchestrator.SwitchoverResult, len(results))
+	copy(dst, results)
+
+	return dst
+}
+
+// UndrainHost returns the instances on the drained host to the election.
+// Masters are not moved back to the host.
+func (c *Coordinator) UndrainHost(ctx context.Context, drain storage.HostDrain) error {
+	c.mutex.Lock()
+	cluster, ok := c.clusters[drain.ClusterName]
+	c.mutex.Unlock()
+	if !ok {
+		return ErrClusterNotFound
+	}
+
+	err := c.db.SaveHostDrain(ctx, drain)
+	if err != nil {
+		return err
+	}
+	cluster.SetHostDrained(drain.Host, false)
+
+	c.logger.Warn().
+		Str("cluster_name", drain.ClusterName).
+		Str("host", drain.Host).
+		Str("author", drain.Author).
+		Str("reason", drain.Reason).
+		Msg("Host has been undrained")
+
+	return nil
+}
+
+// startSwitchovers guards the cluster against concurrent planned switchovers.
+// Switchovers are refused if the cluster is in readonly mode.
+// finishSwitchovers must be called when the switchovers are done.
+func (c *Coordinator) startSwitchovers(name string) (*vshard.Cluster, orchestrator.Failover, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cluster, ok := c.clusters[name]
+	if !ok {
+		return nil, nil, ErrClusterNotFound
+	}
+	if cluster.ReadOnly() {
+		return nil, nil, ErrClusterReadOnly
+	}
+	if c.switching[name] {
+		return nil, nil, ErrSwitchoversInProgress
+	}
+	c.switching[name] = true
+
+	return cluster, c.failovers[name], nil
+}
+
+func (c *Coordinator) finishSwitchovers(name string) {
+	c.mutex.Lock()
+	delete(c.switching, name)
+	c.mutex.Unlock()
+}
+
+// restoreHostDrains marks the hosts which remained drained before qumomf restart.
+func (c *Coordinator) restoreHostDrains(cluster *vshard.Cluster) {
+	drains, err := c.db.GetHostDrains(context.Background(), cluster.Name)
+	if err != nil {
+		c.logger.Err(err).Str("cluster_name", cluster.Name).Msg("failed to read cluster host drains")
+		return
+	}
+
+	drained := make(map[string]bool)
+	for _, drain := range drains {
+		drained[drain.Host] = drain.Drained
+	}
+	for host, ok := range drained {
+		if ok {
+			cluster.SetHostDrained(host, true)
+		}
+	}
+}
+
+// restoreReadOnlyOverride applies the last persisted readonly override of the cluster.
+func (c *Coordinator) restoreReadOnlyOverride(cluster *vshard.Cluster) {
+	overrides, err := c.db.GetReadOnlyOverrides(context.Background(), cluster.Name)
+	if err != nil {
+		c.logger.Err(err).Str("cluster_name", cluster.Name).Msg("failed to read cluster readonly overrides")
+		return
+	}
+	if len(overrides) == 0 {
+		return
+	}
+
+	last := overrides[len(overrides)-1]
+	if last.Reverted {
+		return
+	}
+	if last.RevertAt != 0 && last.RevertAt <= util.Timestamp() {
+		c.revertReadOnly(cluster)
+		return
+	}
+
+	c.applyReadOnlyOverride(cluster, last)
+}
+
+func (c *Coordinator) applyReadOnlyOverride(cluster *vshard.Cluster, override storage.ReadOnlyOverride) {
+	if t, ok := c.reverts[cluster.Name]; ok {
+		t.Stop()
+		delete(c.reverts, cluster.Name)
+	}
+
+	cluster.SetReadOnly(override.ReadOnly)
+	c.logger.Warn().
+		Str("cluster_name", cluster.Name).
+		Bool("readonly", override.ReadOnly).
+		Str("author", override.Author).
+		Str("reason", override.Reason).
+		Int64("revert_at", override.RevertAt).
+		Msg("Readonly mode of the cluster has been overridden")
+
+	if override.RevertAt == 0 {
+		return
+	}
+
+	revertAfter := time.Until(time.Unix(override.RevertAt, 0))
+	var t *time.Timer
+	t = time.AfterFunc(revertAfter, func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		// The timer might fire while a newer override is being applied,
+		// in this case the stale callback must not revert it.
+		if c.reverts[cluster.Name] != t {
+			return
+		}
+		c.revertReadOnly(cluster)
+	})
+	c.reverts[cluster.Name] = t
+}
+
+// revertReadOnly restores the readonly mode of the cluster defined in the configuration.
+func (c *Coordinator) revertReadOnly(cluster *vshard.Cluster) {
+	delete(c.reverts, cluster.Name)
+
+	override := storage.ReadOnlyOverride{
+		ClusterName: cluster.Name,
+		ReadOnly:    c.readOnly[cluster.Name],
+		Author:      revertAuthor,
+		Reason:      "runtime override expired",
+		CreatedAt:   util.Timestamp(),
+		Reverted:    true,
+	}
+	err := c.db.SaveReadOnlyOverride(context.Background(), override)
+	if err != nil {
+		c.logger.Err(err).Str("cluster_name", cluster.Name).Msg("failed to save cluster readonly override")
+	}
+
+	cluster.SetReadOnly(override.ReadOnly)
+	c.logger.Warn().
+		Str("cluster_name", cluster.Name).
+		Bool("readonly", override.ReadOnly).
+		Msg("Readonly override of the cluster has expired, the configured mode is restored")
+}
+
 func (c *Coordinator) Shutdown() {
+	c.mutex.Lock()
+	for _, t := range c.reverts {
+		t.Stop()
+	}
+	c.mutex.Unlock()
+
+	c.switchoversWG.Wait()
+
 	for i := len(c.shutdownQueue) - 1; i >= 0; i-- {
 		task := c.shutdownQueue[i]
 		task()
@@ -106,15 +614,56 @@ func (c *Coordinator) addShutdownTask(task shutdownTask) {
 	c.shutdownQueue = append(c.shutdownQueue, task)
 }
 
-func initHooker(cfg *config.Config, logger zerolog.Logger) *orchestrator.Hooker {
+func initHooker(cfg *config.Config, clusterCfg config.ClusterConfig, logger zerolog.Logger) *orchestrator.Hooker {
 	hooksCfg := cfg.Qumomf.Hooks
 	hooker := orchestrator.NewHooker(hooksCfg.Shell, logger)
 	hooker.SetTimeout(hooksCfg.Timeout)
 	hooker.SetTimeoutAsync(hooksCfg.TimeoutAsync)
 
-	hooker.AddHook(orchestrator.HookPreFailover, hooksCfg.PreFailover...)
-	hooker.AddHook(orchestrator.HookPostSuccessfulFailover, hooksCfg.PostSuccessfulFailover...)
-	hooker.AddHook(orchestrator.HookPostUnsuccessfulFailover, hooksCfg.PostUnsuccessfulFailover...)
+	hooks := cfg.EffectiveHooks(clusterCfg)
+	hooker.AddHooks(orchestrator.HookPreFailover, newHooks(hooks.PreFailover)...)
+	hooker.AddHooks(orchestrator.HookPostSuccessfulFailover, newHooks(hooks.PostSuccessfulFailover)...)
+	hooker.AddHooks(orchestrator.HookPostUnsuccessfulFailover, newHooks(hooks.PostUnsuccessfulFailover)...)
+	hooker.AddHooks(orchestrator.HookProblemDetected, newHooks(hooks.ProblemDetected)...)
+	hooker.AddHooks(orchestrator.HookProblemResolved, newHooks(hooks.ProblemResolved)...)
+	hooker.AddHooks(orchestrator.HookRecoveryBlocked, newHooks(hooks.RecoveryBlocked)...)
+	hooker.AddHooks(orchestrator.HookReadOnlySkipped, newHooks(hooks.ReadOnlySkipped)...)
+	hooker.AddHooks(orchestrator.HookPreSwitchover, newHooks(hooks.PreSwitchover)...)
+	hooker.AddHooks(orchestrator.HookPostSwitchover, newHooks(hooks.PostSwitchover)...)
 
 	return hooker
 }
+
+func newHooks(cfg []config.HookConfig) []orchestrator.Hook {
+	hooks := make([]orchestrator.Hook, 0, len(cfg))
+	for _, c := range cfg { //nolint:gocritic
+		hook := orchestrator.Hook{
+			Name:         c.Name,
+			Command:      c.Command,
+			Template:     c.Template,
+			Timeout:      c.Timeout,
+			Async:        c.Async,
+			Retries:      c.Retries,
+			RetryBackoff: c.RetryBackoff,
+			Env:          c.Env,
+			Filter: orchestrator.HookFilter{
+				FailureTypes: c.Filters.FailureTypes,
+				Clusters:     c.Filters.Clusters,
+			},
+		}
+		for _, uuid := range c.Filters.ReplicaSets {
+			hook.Filter.ReplicaSets = append(hook.Filter.ReplicaSets, vshard.ReplicaSetUUID(uuid))
+		}
+		if c.URL != "" {
+			hook.Webhook = &orchestrator.Webhook{
+				URL:     c.URL,
+				Headers: c.Headers,
+				Secret:  c.Secret,
+			}
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks
+}
@@ -0,0 +1,287 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
+)
+
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityWarning  Severity = "warning"
+	SeverityInfo     Severity = "info"
+)
+
+// notificationQueueSize is a max number of notifications waiting for delivery.
+const notificationQueueSize = 128
+
+// StateSeverity returns the severity of the replica set state.
+func StateSeverity(state orchestrator.ReplicaSetState) Severity {
+	switch state {
+	case orchestrator.NoProblem:
+		return SeverityInfo
+	case orchestrator.DeadFollowers,
+		orchestrator.MasterMasterReplication,
+		orchestrator.InconsistentVShardConfiguration:
+		return SeverityWarning
+	default:
+		return SeverityCritical
+	}
+}
+
+// Notification describes the change of the replica set state.
+type Notification struct {
+	Cluster    string                       `json:"cluster"`
+	ReplicaSet string                       `json:"replica_set"`
+	MasterURI  string                       `json:"master_uri"`
+	State      orchestrator.ReplicaSetState `json:"state"`
+	// PrevState is the state the replica set had before.
+	// It is empty for the first observed state.
+	PrevState orchestrator.ReplicaSetState `json:"prev_state,omitempty"`
+	// Severity of the resolved notification is the one of the resolved problem.
+	Severity Severity `json:"severity"`
+	// Resolved is true if the replica set has returned to NoProblem state.
+	Resolved bool `json:"resolved"`
+	// Repeated is true if the notification reminds about the unresolved problem.
+	Repeated      bool     `json:"repeated"`
+	Summary       string   `json:"summary"`
+	DeadFollowers []string `json:"dead_followers,omitempty"`
+	Timestamp     int64    `json:"timestamp"`
+}
+
+// Sink delivers the notifications to the external system.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// Route defines the sinks the matching notifications are sent to.
+// Empty lists of clusters and severities match any value.
+type Route struct {
+	Clusters   []string
+	Severities []Severity
+	Sinks      []string
+}
+
+func (r *Route) match(n *Notification) bool {
+	return matchString(r.Clusters, n.Cluster) && matchSeverity(r.Severities, n.Severity)
+}
+
+type Config struct {
+	Sinks []Sink
+	// Routes are checked all together and the notification is sent
+	// to the sinks of each matching route. Without routes the
+	// notifications are sent to all sinks.
+	Routes []Route
+	// RepeatInterval is a period of repeating notifications about
+	// unresolved problems. Zero value disables repeating.
+	RepeatInterval time.Duration
+	// Timeout is a deadline of a single delivery.
+	Timeout time.Duration
+}
+
+type setState struct {
+	state    orchestrator.ReplicaSetState
+	notified time.Time
+}
+
+// Notifier observes the analyses of the replica sets and notifies
+// the sinks about the state changes. Repeated states are deduplicated.
+type Notifier struct {
+	cfg   Config
+	sinks map[string]Sink
+
+	states map[string]setState
+	mu     sync.Mutex
+
+	queue  chan Notification
+	stop   chan struct{}
+	now    func() time.Time
+	logger zerolog.Logger
+}
+
+func New(cfg Config, logger zerolog.Logger) *Notifier {
+	sinks := make(map[string]Sink, len(cfg.Sinks))
+	for _, sink := range cfg.Sinks {
+		sinks[sink.Name()] = sink
+	}
+
+	return &Notifier{
+		cfg:    cfg,
+		sinks:  sinks,
+		states: make(map[string]setState),
+		queue:  make(chan Notification, notificationQueueSize),
+		stop:   make(chan struct{}, 1),
+		now:    time.Now,
+		logger: logger,
+	}
+}
+
+// Serve starts delivering the notifications.
+func (n *Notifier) Serve() {
+	go func() {
+		for {
+			select {
+			case <-n.stop:
+				return
+			case notification := <-n.queue:
+				n.deliver(notification)
+			}
+		}
+	}()
+}
+
+func (n *Notifier) Shutdown() {
+	n.stop <- struct{}{}
+}
+
+// Observe checks whether the state of the replica set has changed
+// and enqueues the notification if so. The first observed NoProblem
+// state is recorded silently.
+func (n *Notifier) Observe(cluster string, analysis orchestrator.ReplicationAnalysis) {
+	notification, ok := n.transit(cluster, &analysis)
+	if !ok {
+		return
+	}
+
+	select {
+	case n.queue <- notification:
+	default:
+		n.logger.Warn().
+			Str("cluster", cluster).
+			Str("replica_set", notification.ReplicaSet).
+			Msg("Notification queue is full: drop the notification")
+	}
+}
+
+func (n *Notifier) transit(cluster string, analysis *orchestrator.ReplicationAnalysis) (Notification, bool) {
+	key := cluster + "/" + string(analysis.Set.UUID)
+	now := n.now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	prev, known := n.states[key]
+	repeated := false
+	switch {
+	case !known && analysis.State == orchestrator.NoProblem:
+		n.states[key] = setState{state: analysis.State}
+		return Notification{}, false
+	case known && prev.state == analysis.State:
+		if analysis.State == orchestrator.NoProblem || n.cfg.RepeatInterval <= 0 ||
+			now.Sub(prev.notified) < n.cfg.RepeatInterval {
+			return Notification{}, false
+		}
+		repeated = true
+	}
+	n.states[key] = setState{state: analysis.State, notified: now}
+
+	notification := Notification{
+		Cluster:       cluster,
+		ReplicaSet:    string(analysis.Set.UUID),
+		MasterURI:     analysis.Set.MasterURI,
+		State:         analysis.State,
+		Severity:      StateSeverity(analysis.State),
+		Repeated:      repeated,
+		DeadFollowers: analysis.DeadFollowers,
+		Timestamp:     now.Unix(),
+	}
+	if known && !repeated {
+		notification.PrevState = prev.state
+	}
+	if analysis.State == orchestrator.NoProblem {
+		notification.Resolved = true
+		notification.Severity = StateSeverity(prev.state)
+		notification.Summary = fmt.Sprintf("Replica set %s of cluster %s has recovered from %s",
+			analysis.Set.UUID, cluster, prev.state)
+	} else {
+		notification.Summary = fmt.Sprintf("Replica set %s of cluster %s has %s state",
+			analysis.Set.UUID, cluster, analysis.State)
+	}
+
+	return notification, true
+}
+
+func (n *Notifier) deliver(notification Notification) {
+	for _, sink := range n.route(&notification) {
+		logger := n.logger.With().
+			Str("sink", sink.Name()).
+			Str("cluster", notification.Cluster).
+			Str("replica_set", notification.ReplicaSet).
+			Logger()
+
+		ctx := context.Background()
+		var cancel context.CancelFunc
+		if n.cfg.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, n.cfg.Timeout)
+		}
+		err := sink.Send(ctx, notification)
+		if cancel != nil {
+			cancel()
+		}
+		if err != nil {
+			logger.Err(err).Msg("Failed to send notification")
+			continue
+		}
+		logger.Debug().Msgf("Notification about %s state has been sent", notification.State)
+	}
+}
+
+// route returns the sinks of all routes matching the notification.
+func (n *Notifier) route(notification *Notification) []Sink {
+	if len(n.cfg.Routes) == 0 {
+		return n.cfg.Sinks
+	}
+
+	var sinks []Sink
+	seen := make(map[string]bool)
+	for i := range n.cfg.Routes {
+		route := &n.cfg.Routes[i]
+		if !route.match(notification) {
+			continue
+		}
+		for _, name := range route.Sinks {
+			sink, ok := n.sinks[name]
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			sinks = append(sinks, sink)
+		}
+	}
+
+	return sinks
+}
+
+func matchString(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchSeverity(list []Severity, v Severity) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
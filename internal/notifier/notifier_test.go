@@ -0,0 +1,259 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
+)
+
+type chanSink struct {
+	name string
+	ch   chan Notification
+}
+
+func newChanSink(name string) *chanSink {
+	return &chanSink{name: name, ch: make(chan Notification, 16)}
+}
+
+func (s *chanSink) Name() string {
+	return s.name
+}
+
+func (s *chanSink) Send(_ context.Context, n Notification) error {
+	s.ch <- n
+	return nil
+}
+
+func newAnalysis(set vshard.ReplicaSetUUID, state orchestrator.ReplicaSetState) orchestrator.ReplicationAnalysis {
+	return orchestrator.ReplicationAnalysis{
+		Set: vshard.ReplicaSet{
+			UUID:      set,
+			MasterURI: "localhost:3301",
+		},
+		State: state,
+	}
+}
+
+func TestNotifier_Transit(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	n := New(Config{RepeatInterval: time.Minute}, zerolog.Nop())
+	n.now = func() time.Time {
+		return now
+	}
+
+	tests := []struct {
+		name       string
+		state      orchestrator.ReplicaSetState
+		after      time.Duration
+		expectedOk bool
+		expected   Notification
+	}{
+		{
+			name:  "FirstHealthy",
+			state: orchestrator.NoProblem,
+		},
+		{
+			name:       "Detected",
+			state:      orchestrator.DeadFollowers,
+			expectedOk: true,
+			expected: Notification{
+				State:     orchestrator.DeadFollowers,
+				PrevState: orchestrator.NoProblem,
+				Severity:  SeverityWarning,
+			},
+		},
+		{
+			name:  "Duplicate",
+			state: orchestrator.DeadFollowers,
+			after: 30 * time.Second,
+		},
+		{
+			name:       "Repeated",
+			state:      orchestrator.DeadFollowers,
+			after:      30 * time.Second,
+			expectedOk: true,
+			expected: Notification{
+				State:    orchestrator.DeadFollowers,
+				Severity: SeverityWarning,
+				Repeated: true,
+			},
+		},
+		{
+			name:       "Escalated",
+			state:      orchestrator.DeadMaster,
+			expectedOk: true,
+			expected: Notification{
+				State:     orchestrator.DeadMaster,
+				PrevState: orchestrator.DeadFollowers,
+				Severity:  SeverityCritical,
+			},
+		},
+		{
+			name:       "Resolved",
+			state:      orchestrator.NoProblem,
+			expectedOk: true,
+			expected: Notification{
+				State:     orchestrator.NoProblem,
+				PrevState: orchestrator.DeadMaster,
+				Severity:  SeverityCritical,
+				Resolved:  true,
+			},
+		},
+		{
+			name:  "StillHealthy",
+			state: orchestrator.NoProblem,
+			after: time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		now = now.Add(tt.after)
+		analysis := newAnalysis("set_1", tt.state)
+		got, ok := n.transit("sandbox", &analysis)
+		require.Equal(t, tt.expectedOk, ok, tt.name)
+		if !tt.expectedOk {
+			continue
+		}
+		assert.Equal(t, "sandbox", got.Cluster, tt.name)
+		assert.Equal(t, "set_1", got.ReplicaSet, tt.name)
+		assert.Equal(t, tt.expected.State, got.State, tt.name)
+		assert.Equal(t, tt.expected.PrevState, got.PrevState, tt.name)
+		assert.Equal(t, tt.expected.Severity, got.Severity, tt.name)
+		assert.Equal(t, tt.expected.Resolved, got.Resolved, tt.name)
+		assert.Equal(t, tt.expected.Repeated, got.Repeated, tt.name)
+		assert.Equal(t, now.Unix(), got.Timestamp, tt.name)
+		assert.NotEmpty(t, got.Summary, tt.name)
+	}
+}
+
+func TestNotifier_Route(t *testing.T) {
+	ops := newChanSink("ops")
+	dev := newChanSink("dev")
+	n := New(Config{
+		Sinks: []Sink{ops, dev},
+		Routes: []Route{
+			{Severities: []Severity{SeverityCritical}, Sinks: []string{"ops"}},
+			{Clusters: []string{"sandbox"}, Sinks: []string{"dev", "ops"}},
+		},
+	}, zerolog.Nop())
+	n.Serve()
+	defer n.Shutdown()
+
+	n.Observe("production", newAnalysis("set_1", orchestrator.DeadMaster))
+	n.Observe("production", newAnalysis("set_2", orchestrator.DeadFollowers))
+	n.Observe("sandbox", newAnalysis("set_1", orchestrator.DeadFollowers))
+
+	got := <-ops.ch
+	assert.Equal(t, "production", got.Cluster)
+	assert.Equal(t, orchestrator.DeadMaster, got.State)
+
+	got = <-dev.ch
+	assert.Equal(t, "sandbox", got.Cluster)
+	got = <-ops.ch
+	assert.Equal(t, "sandbox", got.Cluster)
+
+	select {
+	case got = <-ops.ch:
+		assert.Failf(t, "unexpected notification", "%v", got)
+	case got = <-dev.ch:
+		assert.Failf(t, "unexpected notification", "%v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got Notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink("ops", srv.URL, map[string]string{"Authorization": "Bearer token"})
+	n := Notification{Cluster: "sandbox", State: orchestrator.DeadMaster, Severity: SeverityCritical}
+	require.NoError(t, sink.Send(context.Background(), n))
+	assert.Equal(t, n, got)
+
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failed.Close()
+
+	sink = NewWebhookSink("ops", failed.URL, nil)
+	assert.Error(t, sink.Send(context.Background(), n))
+}
+
+func TestSlackSink(t *testing.T) {
+	var got slackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	sink := NewSlackSink("chat", srv.URL, "#alerts", "qumomf")
+	err := sink.Send(context.Background(), Notification{
+		Cluster:  "sandbox",
+		State:    orchestrator.NoProblem,
+		Severity: SeverityWarning,
+		Resolved: true,
+		Summary:  "recovered",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "#alerts", got.Channel)
+	assert.Equal(t, "qumomf", got.Username)
+	assert.True(t, strings.Contains(got.Text, "RESOLVED"))
+	require.Len(t, got.Attachments, 1)
+	assert.Equal(t, "good", got.Attachments[0].Color)
+	assert.Equal(t, "recovered", got.Attachments[0].Text)
+}
+
+func TestSMTPSink(t *testing.T) {
+	sink := NewSMTPSink("mail", SMTPOptions{
+		Host: "localhost",
+		Port: 25,
+		From: "qumomf@example.com",
+		To:   []string{"ops@example.com", "dev@example.com"},
+	})
+
+	var (
+		gotAddr string
+		gotAuth smtp.Auth
+		gotTo   []string
+		gotMsg  string
+	)
+	sink.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotTo, gotMsg = addr, a, to, string(msg)
+		return nil
+	}
+
+	err := sink.Send(context.Background(), Notification{
+		Cluster:       "sandbox",
+		ReplicaSet:    "set_1",
+		State:         orchestrator.DeadFollowers,
+		Severity:      SeverityWarning,
+		Summary:       "dead followers",
+		DeadFollowers: []string{"localhost:3302"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "localhost:25", gotAddr)
+	assert.Nil(t, gotAuth)
+	assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, gotTo)
+	assert.Contains(t, gotMsg, "To: ops@example.com, dev@example.com\r\n")
+	assert.Contains(t, gotMsg, "Subject: [qumomf] [PROBLEM] sandbox: dead followers\r\n")
+	assert.Contains(t, gotMsg, "Dead followers: localhost:3302\r\n")
+}
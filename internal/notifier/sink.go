@@ -0,0 +1,228 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// responseLimit is a max number of bytes read from the error response.
+const responseLimit = 1024
+
+// WebhookSink posts the notification as a JSON document.
+type WebhookSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhookSink(name, url string, headers map[string]string) *WebhookSink {
+	return &WebhookSink{
+		name:    name,
+		url:     url,
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return s.name
+}
+
+func (s *WebhookSink) Send(ctx context.Context, n Notification) error {
+	return postJSON(ctx, s.client, s.url, s.headers, n)
+}
+
+// SlackSink posts the notification in the format
+// of Slack-compatible incoming webhooks.
+type SlackSink struct {
+	name     string
+	url      string
+	channel  string
+	username string
+	client   *http.Client
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Title  string       `json:"title"`
+	Text   string       `json:"text"`
+	Fields []slackField `json:"fields"`
+	Ts     int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func NewSlackSink(name, url, channel, username string) *SlackSink {
+	return &SlackSink{
+		name:     name,
+		url:      url,
+		channel:  channel,
+		username: username,
+		client:   &http.Client{},
+	}
+}
+
+func (s *SlackSink) Name() string {
+	return s.name
+}
+
+func (s *SlackSink) Send(ctx context.Context, n Notification) error {
+	color := "danger"
+	switch {
+	case n.Resolved:
+		color = "good"
+	case n.Severity == SeverityWarning:
+		color = "warning"
+	}
+
+	fields := []slackField{
+		{Title: "Cluster", Value: n.Cluster, Short: true},
+		{Title: "Severity", Value: string(n.Severity), Short: true},
+		{Title: "Replica set", Value: n.ReplicaSet, Short: false},
+		{Title: "Master", Value: n.MasterURI, Short: true},
+		{Title: "State", Value: string(n.State), Short: true},
+	}
+	if len(n.DeadFollowers) > 0 {
+		fields = append(fields, slackField{Title: "Dead followers", Value: strings.Join(n.DeadFollowers, ", ")})
+	}
+
+	msg := slackMessage{
+		Text:     subject(&n),
+		Channel:  s.channel,
+		Username: s.username,
+		Attachments: []slackAttachment{{
+			Color:  color,
+			Title:  string(n.State),
+			Text:   n.Summary,
+			Fields: fields,
+			Ts:     n.Timestamp,
+		}},
+	}
+
+	return postJSON(ctx, s.client, s.url, nil, msg)
+}
+
+type SMTPOptions struct {
+	Host string
+	Port int
+	// Username and Password are used for PLAIN authentication if set.
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTPSink sends the notification as a plain text email.
+type SMTPSink struct {
+	name string
+	opts SMTPOptions
+	// send is replaced in tests.
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewSMTPSink(name string, opts SMTPOptions) *SMTPSink {
+	return &SMTPSink{
+		name: name,
+		opts: opts,
+		send: smtp.SendMail,
+	}
+}
+
+func (s *SMTPSink) Name() string {
+	return s.name
+}
+
+// Send delivers the email. The SMTP client does not support
+// the context, so the deadline is checked only before sending.
+func (s *SMTPSink) Send(ctx context.Context, n Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.opts.Username != "" {
+		auth = smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.opts.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.opts.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject(&n))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n", n.Summary)
+	fmt.Fprintf(&msg, "Cluster: %s\r\n", n.Cluster)
+	fmt.Fprintf(&msg, "Replica set: %s\r\n", n.ReplicaSet)
+	fmt.Fprintf(&msg, "Master: %s\r\n", n.MasterURI)
+	fmt.Fprintf(&msg, "State: %s\r\n", n.State)
+	if n.PrevState != "" {
+		fmt.Fprintf(&msg, "Previous state: %s\r\n", n.PrevState)
+	}
+	fmt.Fprintf(&msg, "Severity: %s\r\n", n.Severity)
+	if len(n.DeadFollowers) > 0 {
+		fmt.Fprintf(&msg, "Dead followers: %s\r\n", strings.Join(n.DeadFollowers, ", "))
+	}
+
+	addr := s.opts.Host + ":" + strconv.Itoa(s.opts.Port)
+	return s.send(addr, auth, s.opts.From, s.opts.To, msg.Bytes())
+}
+
+func subject(n *Notification) string {
+	status := "PROBLEM"
+	if n.Resolved {
+		status = "RESOLVED"
+	}
+
+	return fmt.Sprintf("[qumomf] [%s] %s: %s", status, n.Cluster, n.Summary)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, responseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	return nil
+}
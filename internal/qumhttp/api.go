@@ -8,12 +8,19 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/shmel1k/qumomf/internal/api"
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
 )
 
 const (
 	paramClusterName  = "cluster_name"
 	paramShardUUID    = "shard_uuid"
 	paramInstanceUUID = "instance_uuid"
+	paramHost         = "host"
+	paramJobID        = "job_id"
+	paramStatus       = "status"
+	paramTimestamp    = "timestamp"
+	paramFrom         = "from"
+	paramTo           = "to"
 )
 
 const (
@@ -29,6 +36,21 @@ type APIHandler interface {
 	ShardRecoveries(http.ResponseWriter, *http.Request)
 	Alerts(http.ResponseWriter, *http.Request)
 	ClusterAlerts(http.ResponseWriter, *http.Request)
+	SetClusterReadOnly(http.ResponseWriter, *http.Request)
+	ClusterReadOnlyOverrides(http.ResponseWriter, *http.Request)
+	ClusterRebalancePlan(http.ResponseWriter, *http.Request)
+	RebalanceCluster(http.ResponseWriter, *http.Request)
+	RebalanceReport(http.ResponseWriter, *http.Request)
+	DrainHost(http.ResponseWriter, *http.Request)
+	DrainReport(http.ResponseWriter, *http.Request)
+	UndrainHost(http.ResponseWriter, *http.Request)
+	HostDrains(http.ResponseWriter, *http.Request)
+	ClusterHooks(http.ResponseWriter, *http.Request)
+	HookJobs(http.ResponseWriter, *http.Request)
+	RetryHookJob(http.ResponseWriter, *http.Request)
+	SnapshotHistory(http.ResponseWriter, *http.Request)
+	ClusterSnapshotAt(http.ResponseWriter, *http.Request)
+	SnapshotDiff(http.ResponseWriter, *http.Request)
 }
 
 type apiHandler struct {
@@ -204,8 +226,432 @@ func (a *apiHandler) ClusterAlerts(w http.ResponseWriter, r *http.Request) {
 	a.writeResponse(w, newOKResponse(data))
 }
 
+func (a *apiHandler) SetClusterReadOnly(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	var req api.ReadOnlyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.ReadOnly == nil || req.Author == "" || req.RevertAfter < 0 {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	override, err := a.apiSrv.SetClusterReadOnly(r.Context(), reqParams.clusterName, req)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to set cluster readonly mode", err))
+		return
+	}
+
+	data, err := json.Marshal(override)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) ClusterReadOnlyOverrides(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	overrides, err := a.apiSrv.ClusterReadOnlyOverrides(r.Context(), reqParams.clusterName)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse("failed get cluster readonly overrides", err))
+		return
+	}
+
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) ClusterRebalancePlan(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	plan, err := a.apiSrv.ClusterRebalancePlan(r.Context(), reqParams.clusterName)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to plan cluster rebalancing", err))
+		return
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) RebalanceCluster(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	report, err := a.apiSrv.RebalanceCluster(r.Context(), reqParams.clusterName)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		if err == api.ErrSwitchoversInProgress || err == api.ErrClusterReadOnly {
+			a.writeResponse(w, newConflictResponse(err.Error()))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to rebalance cluster", err))
+		return
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newAcceptedResponse(data))
+}
+
+func (a *apiHandler) RebalanceReport(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	report, err := a.apiSrv.RebalanceReport(r.Context(), reqParams.clusterName)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to get cluster rebalance report", err))
+		return
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) DrainHost(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" || reqParams.host == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	var req api.DrainRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Author == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	report, err := a.apiSrv.DrainHost(r.Context(), reqParams.clusterName, reqParams.host, req)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		if err == api.ErrSwitchoversInProgress || err == api.ErrClusterReadOnly {
+			a.writeResponse(w, newConflictResponse(err.Error()))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to drain host", err))
+		return
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newAcceptedResponse(data))
+}
+
+func (a *apiHandler) DrainReport(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" || reqParams.host == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	report, err := a.apiSrv.DrainReport(r.Context(), reqParams.clusterName, reqParams.host)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to get host drain report", err))
+		return
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) UndrainHost(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" || reqParams.host == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	var req api.DrainRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Author == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	drain, err := a.apiSrv.UndrainHost(r.Context(), reqParams.clusterName, reqParams.host, req)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to undrain host", err))
+		return
+	}
+
+	data, err := json.Marshal(drain)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) HostDrains(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	drains, err := a.apiSrv.HostDrains(r.Context(), reqParams.clusterName)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse("failed to get cluster host drains", err))
+		return
+	}
+
+	data, err := json.Marshal(drains)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) ClusterHooks(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	hooks, err := a.apiSrv.ClusterHooks(r.Context(), reqParams.clusterName)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to get cluster hooks", err))
+		return
+	}
+
+	data, err := json.Marshal(hooks)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) HookJobs(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	status := orchestrator.HookJobStatus(r.URL.Query().Get(paramStatus))
+	if reqParams.clusterName == "" || !isValidHookJobStatus(status) {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	jobs, err := a.apiSrv.HookJobs(r.Context(), reqParams.clusterName, status)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse("failed to get cluster hook jobs", err))
+		return
+	}
+
+	data, err := json.Marshal(jobs)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) RetryHookJob(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	if reqParams.clusterName == "" || reqParams.jobID <= 0 {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	job, err := a.apiSrv.RetryHookJob(r.Context(), reqParams.clusterName, reqParams.jobID)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		if err == api.ErrHookJobNotDead {
+			a.writeResponse(w, newConflictResponse(err.Error()))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to retry hook job", err))
+		return
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) SnapshotHistory(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	from, okFrom := parseTime(r.URL.Query().Get(paramFrom))
+	to, okTo := parseTime(r.URL.Query().Get(paramTo))
+	if reqParams.clusterName == "" || !okFrom || !okTo {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	timestamps, err := a.apiSrv.SnapshotHistory(r.Context(), reqParams.clusterName, from, to)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse("failed to get cluster snapshot history", err))
+		return
+	}
+
+	data, err := json.Marshal(timestamps)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) ClusterSnapshotAt(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	reqParams := parseParams(vars)
+	at, ok := parseTime(vars[paramTimestamp])
+	if reqParams.clusterName == "" || !ok || at == 0 {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	snap, err := a.apiSrv.ClusterSnapshotAt(r.Context(), reqParams.clusterName, at)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed get cluster snapshot", err))
+		return
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func (a *apiHandler) SnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	reqParams := parseParams(mux.Vars(r))
+	from, okFrom := parseTime(r.URL.Query().Get(paramFrom))
+	to, okTo := parseTime(r.URL.Query().Get(paramTo))
+	if reqParams.clusterName == "" || !okFrom || !okTo || from == 0 {
+		a.writeResponse(w, newBadRequestResponse(msgInvalidParams))
+		return
+	}
+
+	diff, err := a.apiSrv.SnapshotDiff(r.Context(), reqParams.clusterName, from, to)
+	if err != nil {
+		if isNotFoundTypeErr(err) {
+			a.writeResponse(w, newBadRequestResponse(parseNotFoundTypeErr(err)))
+			return
+		}
+		a.writeResponse(w, newInternalErrResponse("failed to compare cluster snapshots", err))
+		return
+	}
+
+	data, err := json.Marshal(diff)
+	if err != nil {
+		a.writeResponse(w, newInternalErrResponse(msgMarshallingError, err))
+		return
+	}
+
+	a.writeResponse(w, newOKResponse(data))
+}
+
+func isValidHookJobStatus(status orchestrator.HookJobStatus) bool {
+	switch status {
+	case "", orchestrator.HookJobPending, orchestrator.HookJobRunning, orchestrator.HookJobDone, orchestrator.HookJobDead:
+		return true
+	}
+
+	return false
+}
+
 func isNotFoundTypeErr(err error) bool {
-	return err == api.ErrClusterNotFound || err == api.ErrReplicaSetNotFound || err == api.ErrInstanceNotFound
+	return err == api.ErrClusterNotFound || err == api.ErrReplicaSetNotFound || err == api.ErrInstanceNotFound ||
+		err == api.ErrHookJobNotFound || err == api.ErrSnapshotNotFound || err == api.ErrReportNotFound
 }
 
 func parseNotFoundTypeErr(err error) string {
@@ -216,6 +662,12 @@ func parseNotFoundTypeErr(err error) string {
 		return "shard snapshot not found"
 	case api.ErrInstanceNotFound:
 		return "instance snapshot not found"
+	case api.ErrHookJobNotFound:
+		return "hook job not found"
+	case api.ErrSnapshotNotFound:
+		return "no snapshot at the given time"
+	case api.ErrReportNotFound:
+		return "report not found"
 	}
 
 	return "cluster not found"
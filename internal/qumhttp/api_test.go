@@ -6,12 +6,17 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/shmel1k/qumomf/internal/api"
-	"github.com/shmel1k/qumomf/internal/storage/sqlite"
+	"github.com/shmel1k/qumomf/internal/config"
+	"github.com/shmel1k/qumomf/internal/coordinator"
+	"github.com/shmel1k/qumomf/internal/quorum"
+	"github.com/shmel1k/qumomf/internal/storage"
+	"github.com/shmel1k/qumomf/internal/storage/memory"
+	"github.com/shmel1k/qumomf/internal/util"
 	"github.com/shmel1k/qumomf/internal/vshard"
 	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
 
@@ -23,9 +28,9 @@ import (
 )
 
 var (
-	tDBFileName                                 = "test.db"
 	tClusterName                                = "test_cluster"
 	tNotFoundCluster                            = "not_found_cluster"
+	tWritableCluster                            = "writable_cluster"
 	tShardUUID            vshard.ReplicaSetUUID = "7c652540-2d9c-4eb1-8473-a41ec7ab3554"
 	tNotFoundShardUUID    vshard.ReplicaSetUUID = "2e353da3-0170-497b-b502-c94a1c1ed251"
 	tInstanceUUID         vshard.InstanceUUID   = "11a6a15d-1ddd-4d10-af53-d489774b6ad6"
@@ -93,6 +98,28 @@ var (
 		Successor:    vshard.InstanceIdent{},
 		IsSuccessful: true,
 		EndTimestamp: time.Now().Unix(),
+		Hooks: []orchestrator.HookExecution{
+			{
+				Type:     orchestrator.HookPostSuccessfulFailover,
+				Name:     "notify",
+				Command:  "notify",
+				Attempts: 2,
+				ExitCode: 1,
+				Stderr:   "failed",
+				Error:    "exit status 1",
+			},
+		},
+		Election: &quorum.Decision{
+			Mode:   quorum.ModeSmart,
+			Winner: tInstanceUUID,
+			Candidates: []quorum.Candidate{
+				{
+					UUID:     tInstanceUUID,
+					Eligible: true,
+					Rank:     1,
+				},
+			},
+		},
 	}
 )
 
@@ -110,25 +137,32 @@ type apiSuite struct {
 	handler APIHandler
 
 	router *mux.Router
+	coord  *coordinator.Coordinator
+	db     storage.Storage
 }
 
 func (a *apiSuite) SetupSuite() {
 	t := a.Suite.T()
 
-	db, err := sqlite.New(sqlite.Config{
-		FileName:       tDBFileName,
-		ConnectTimeout: time.Second,
-		QueryTimeout:   time.Second,
-	})
-	require.NoError(t, err)
+	db := memory.New(memory.Config{})
 
-	err = db.SaveSnapshot(dummyContext, tClusterName, tSnapshot)
+	err := db.SaveSnapshot(dummyContext, tClusterName, tSnapshot)
 	require.NoError(t, err)
 
 	err = db.SaveRecovery(dummyContext, tRecovery)
 	require.NoError(t, err)
 
-	a.handler = NewHandler(dummyLogger, api.NewService(db))
+	a.db = db
+	a.coord = coordinator.New(dummyLogger, db)
+	err = a.coord.RegisterCluster(tClusterName, tClusterConfig(), tConfig())
+	require.NoError(t, err)
+
+	writableCfg := tClusterConfig()
+	writableCfg.ReadOnly = util.NewBool(false)
+	err = a.coord.RegisterCluster(tWritableCluster, writableCfg, tConfig())
+	require.NoError(t, err)
+
+	a.handler = NewHandler(dummyLogger, api.NewService(db, a.coord))
 
 	router := mux.NewRouter()
 	RegisterAPIHandlers(router, a.handler)
@@ -137,8 +171,7 @@ func (a *apiSuite) SetupSuite() {
 }
 
 func (a *apiSuite) TearDownSuite() {
-	err := os.Remove(tDBFileName)
-	require.NoError(a.T(), err)
+	a.coord.Shutdown()
 }
 
 func TestAPI(t *testing.T) {
@@ -393,9 +426,570 @@ func (a *apiSuite) TestClusterAlerts() {
 	}
 }
 
+func (a *apiSuite) TestSetClusterReadOnly() {
+	t := a.T()
+
+	for _, tt := range []struct {
+		name         string
+		clusterName  string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "Not_found_cluster",
+			clusterName:  tNotFoundCluster,
+			body:         `{"readonly": true, "author": "admin", "reason": "maintenance"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid_body",
+			clusterName:  tClusterName,
+			body:         `{"readonly": "yes"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "No_readonly",
+			clusterName:  tClusterName,
+			body:         `{"author": "admin", "reason": "maintenance"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "No_author",
+			clusterName:  tClusterName,
+			body:         `{"readonly": true, "reason": "maintenance"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Success_case",
+			clusterName:  tClusterName,
+			body:         `{"readonly": false, "revert_after": 60, "author": "admin", "reason": "maintenance"}`,
+			expectedCode: http.StatusOK,
+		},
+	} {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v0/clusters/%s/readonly", tc.clusterName), strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			a.router.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/readonly", tClusterName), nil)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var overrides []storage.ReadOnlyOverride
+	err := json.Unmarshal(w.Body.Bytes(), &overrides)
+	require.NoError(t, err)
+	require.Len(t, overrides, 1)
+
+	override := overrides[0]
+	assert.Equal(t, tClusterName, override.ClusterName)
+	assert.False(t, override.ReadOnly)
+	assert.Equal(t, "admin", override.Author)
+	assert.Equal(t, "maintenance", override.Reason)
+	assert.InDelta(t, util.Timestamp()+60, override.RevertAt, 1)
+	assert.False(t, override.Reverted)
+}
+
+func (a *apiSuite) TestRebalanceCluster() {
+	t := a.T()
+
+	for _, tt := range []struct {
+		name         string
+		method       string
+		clusterName  string
+		expectedCode int
+	}{
+		{
+			name:         "Plan_not_found_cluster",
+			method:       http.MethodGet,
+			clusterName:  tNotFoundCluster,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Execute_not_found_cluster",
+			method:       http.MethodPost,
+			clusterName:  tNotFoundCluster,
+			expectedCode: http.StatusBadRequest,
+		},
+	} {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, fmt.Sprintf("/api/v0/clusters/%s/rebalance", tc.clusterName), nil)
+			w := httptest.NewRecorder()
+			a.router.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, "cluster snapshot not found", w.Body.String())
+		})
+	}
+
+	// Planned switchovers are refused in readonly mode.
+	r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v0/clusters/%s/rebalance", tClusterName), nil)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, api.ErrClusterReadOnly.Error(), w.Body.String())
+
+	// No rebalance has been started yet.
+	r = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/rebalance/report", tWritableCluster), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "report not found", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v0/clusters/%s/rebalance", tWritableCluster), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var report orchestrator.RebalanceReport
+	err := json.Unmarshal(w.Body.Bytes(), &report)
+	require.NoError(t, err)
+	assert.Empty(t, report.Plan.Switchovers)
+	assert.Empty(t, report.Results)
+	assert.True(t, report.InProgress)
+	assert.False(t, report.Completed)
+
+	// The switchovers are executed in background.
+	require.Eventually(t, func() bool {
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/rebalance/report", tWritableCluster), nil)
+		w := httptest.NewRecorder()
+		a.router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		err := json.Unmarshal(w.Body.Bytes(), &report)
+		require.NoError(t, err)
+
+		return !report.InProgress
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, report.Results)
+	assert.True(t, report.Completed)
+}
+
+func (a *apiSuite) TestDrainHost() {
+	t := a.T()
+
+	for _, tt := range []struct {
+		name         string
+		method       string
+		clusterName  string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "Drain_not_found_cluster",
+			method:       http.MethodPut,
+			clusterName:  tNotFoundCluster,
+			body:         `{"author": "admin", "reason": "maintenance"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Drain_no_author",
+			method:       http.MethodPut,
+			clusterName:  tClusterName,
+			body:         `{"reason": "maintenance"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Undrain_not_found_cluster",
+			method:       http.MethodDelete,
+			clusterName:  tNotFoundCluster,
+			body:         `{"author": "admin"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Drain_readonly_cluster",
+			method:       http.MethodPut,
+			clusterName:  tClusterName,
+			body:         `{"author": "admin", "reason": "maintenance"}`,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Drain_success_case",
+			method:       http.MethodPut,
+			clusterName:  tWritableCluster,
+			body:         `{"author": "admin", "reason": "maintenance"}`,
+			expectedCode: http.StatusAccepted,
+		},
+	} {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, fmt.Sprintf("/api/v0/clusters/%s/hosts/host-1/drain", tc.clusterName), strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			a.router.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+		})
+	}
+
+	// The switchovers are executed in background.
+	var report orchestrator.DrainReport
+	require.Eventually(t, func() bool {
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/hosts/host-1/drain", tWritableCluster), nil)
+		w := httptest.NewRecorder()
+		a.router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		err := json.Unmarshal(w.Body.Bytes(), &report)
+		require.NoError(t, err)
+
+		return !report.InProgress
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "host-1", report.Host)
+	assert.Empty(t, report.Results)
+	assert.True(t, report.Completed)
+
+	// The host has never been drained.
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/hosts/host-2/drain", tWritableCluster), nil)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "report not found", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v0/clusters/%s/hosts/host-1/drain", tWritableCluster), strings.NewReader(`{"author": "admin"}`))
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The rejected drain is not saved.
+	r = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/drains", tClusterName), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var drains []storage.HostDrain
+	err := json.Unmarshal(w.Body.Bytes(), &drains)
+	require.NoError(t, err)
+	assert.Empty(t, drains)
+
+	r = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/drains", tWritableCluster), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	err = json.Unmarshal(w.Body.Bytes(), &drains)
+	require.NoError(t, err)
+	require.Len(t, drains, 2)
+	assert.Equal(t, "host-1", drains[0].Host)
+	assert.True(t, drains[0].Drained)
+	assert.Equal(t, "maintenance", drains[0].Reason)
+	assert.False(t, drains[1].Drained)
+}
+
+func (a *apiSuite) TestClusterHooks() {
+	t := a.T()
+
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/hooks", tNotFoundCluster), nil)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "cluster snapshot not found", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/hooks", tClusterName), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var hooks map[orchestrator.HookType][]api.HookInfo
+	err := json.Unmarshal(w.Body.Bytes(), &hooks)
+	require.NoError(t, err)
+
+	assert.Equal(t, []api.HookInfo{
+		{Command: "echo 'pre failover'"},
+	}, hooks[orchestrator.HookPreFailover])
+	assert.Equal(t, []api.HookInfo{
+		{
+			Name:    "notify",
+			URL:     "http://example.com/hook",
+			Headers: map[string]string{"Authorization": "***"},
+			Signed:  true,
+			Timeout: "1s",
+		},
+	}, hooks[orchestrator.HookPostSuccessfulFailover])
+	assert.Empty(t, hooks[orchestrator.HookPostUnsuccessfulFailover])
+}
+
+func (a *apiSuite) TestHookJobs() {
+	t := a.T()
+
+	job := orchestrator.HookJob{
+		ClusterName: tClusterName,
+		Type:        orchestrator.HookPostSuccessfulFailover,
+		URL:         "http://localhost:1/hook",
+		Payload:     []byte(`{}`),
+		MaxAttempts: 1,
+		Status:      orchestrator.HookJobDead,
+		Attempts:    1,
+	}
+	id, err := a.db.SaveHookJob(dummyContext, job)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/hook_jobs?status=dead", tClusterName), nil)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var jobs []api.HookJobInfo
+	err = json.Unmarshal(w.Body.Bytes(), &jobs)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, id, jobs[0].ID)
+	assert.Equal(t, orchestrator.HookJobDead, jobs[0].Status)
+	assert.Equal(t, "http://localhost:1/hook", jobs[0].URL)
+
+	r = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/hook_jobs?status=unknown", tClusterName), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v0/clusters/%s/hook_jobs/%d/retry", tClusterName, id+1), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "hook job not found", w.Body.String())
+
+	r = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v0/clusters/%s/hook_jobs/%d/retry", tClusterName, id), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var retried api.HookJobInfo
+	err = json.Unmarshal(w.Body.Bytes(), &retried)
+	require.NoError(t, err)
+	assert.Equal(t, orchestrator.HookJobPending, retried.Status)
+	assert.Zero(t, retried.Attempts)
+
+	job.Status = orchestrator.HookJobDone
+	id, err = a.db.SaveHookJob(dummyContext, job)
+	require.NoError(t, err)
+
+	r = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v0/clusters/%s/hook_jobs/%d/retry", tClusterName, id), nil)
+	w = httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func (a *apiSuite) TestGetRecoveries_HookJobs() {
+	t := a.T()
+
+	// Jobs of the unregistered cluster are not run by the queue.
+	clusterName := "hook_jobs_cluster"
+	result := orchestrator.HookExecution{
+		Type:       orchestrator.HookPostSuccessfulFailover,
+		URL:        "http://localhost:1/hook",
+		Async:      true,
+		Attempts:   3,
+		StatusCode: http.StatusServiceUnavailable,
+		Error:      "unexpected status code 503",
+		Duration:   10,
+	}
+	doneID, err := a.db.SaveHookJob(dummyContext, orchestrator.HookJob{
+		ClusterName: clusterName,
+		Type:        orchestrator.HookPostSuccessfulFailover,
+		URL:         "http://localhost:1/hook",
+		Status:      orchestrator.HookJobDead,
+		Attempts:    3,
+		LastResult:  &result,
+	})
+	require.NoError(t, err)
+	pendingID, err := a.db.SaveHookJob(dummyContext, orchestrator.HookJob{
+		ClusterName: clusterName,
+		Type:        orchestrator.HookPostSuccessfulFailover,
+		URL:         "http://localhost:1/hook",
+		Status:      orchestrator.HookJobPending,
+	})
+	require.NoError(t, err)
+
+	setUUID := vshard.ReplicaSetUUID("0b5b6a9e-7f6d-4c1a-9f0e-3d2b1a4c5e6f")
+	recovery := orchestrator.Recovery{
+		Type:         "test_recovery",
+		ClusterName:  clusterName,
+		SetUUID:      setUUID,
+		IsSuccessful: true,
+		EndTimestamp: time.Now().Unix(),
+		Hooks: []orchestrator.HookExecution{
+			{Type: orchestrator.HookPostSuccessfulFailover, Async: true, StartTimestamp: 100, JobID: doneID},
+			{Type: orchestrator.HookPostSuccessfulFailover, Async: true, StartTimestamp: 100, JobID: pendingID},
+		},
+	}
+	err = a.db.SaveRecovery(dummyContext, recovery)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/recoveries/%s/%s", clusterName, setUUID), nil)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var recoveries []orchestrator.Recovery
+	err = json.Unmarshal(w.Body.Bytes(), &recoveries)
+	require.NoError(t, err)
+	require.Len(t, recoveries, 1)
+	require.Len(t, recoveries[0].Hooks, 2)
+
+	expected := result
+	expected.StartTimestamp = 100
+	expected.JobID = doneID
+	expected.JobStatus = orchestrator.HookJobDead
+	assert.Equal(t, expected, recoveries[0].Hooks[0])
+
+	assert.Equal(t, orchestrator.HookJobPending, recoveries[0].Hooks[1].JobStatus)
+	assert.Empty(t, recoveries[0].Hooks[1].Error)
+	assert.Zero(t, recoveries[0].Hooks[1].Attempts)
+}
+
+func (a *apiSuite) TestSnapshotHistory() {
+	t := a.T()
+
+	clusterName := "history_cluster"
+	first := tSnapshot.Copy()
+	first.Created = 1600000000
+	second := tSnapshot.Copy()
+	second.Created = 1600000600
+	second.ReplicaSets[0].Instances[0].Readonly = true
+	for _, snap := range []vshard.Snapshot{first, second} {
+		err := a.db.SaveSnapshot(dummyContext, clusterName, snap)
+		require.NoError(t, err)
+	}
+
+	for _, tt := range []struct {
+		name             string
+		path             string
+		expectedCode     int
+		expectedResponse string
+	}{
+		{
+			name:             "History",
+			path:             "/snapshots",
+			expectedCode:     http.StatusOK,
+			expectedResponse: "[1600000000,1600000600]",
+		},
+		{
+			name:             "HistoryRange",
+			path:             "/snapshots?from=2020-09-13T12:30:00Z",
+			expectedCode:     http.StatusOK,
+			expectedResponse: "[1600000600]",
+		},
+		{
+			name:             "HistoryInvalidRange",
+			path:             "/snapshots?to=yesterday",
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: msgInvalidParams,
+		},
+		{
+			name:             "SnapshotAt",
+			path:             "/snapshots/1600000300",
+			expectedCode:     http.StatusOK,
+			expectedResponse: a.jsonMarshal(first),
+		},
+		{
+			name:             "SnapshotAtRFC3339",
+			path:             "/snapshots/2020-09-13T12:40:00Z",
+			expectedCode:     http.StatusOK,
+			expectedResponse: a.jsonMarshal(second),
+		},
+		{
+			name:             "SnapshotBeforeHistory",
+			path:             "/snapshots/1500000000",
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: "no snapshot at the given time",
+		},
+		{
+			name:             "Diff",
+			path:             "/snapshots/diff?from=1600000000",
+			expectedCode:     http.StatusOK,
+			expectedResponse: a.jsonMarshal(vshard.DiffSnapshots(&first, &second)),
+		},
+		{
+			name:             "DiffRange",
+			path:             "/snapshots/diff?from=1600000000&to=1600000300",
+			expectedCode:     http.StatusOK,
+			expectedResponse: `{"from":1600000000,"to":1600000000,"replica_sets":[]}`,
+		},
+		{
+			name:             "DiffBeforeHistory",
+			path:             "/snapshots/diff?from=1500000000",
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: "no snapshot at the given time",
+		},
+		{
+			name:             "DiffWithoutFrom",
+			path:             "/snapshots/diff",
+			expectedCode:     http.StatusBadRequest,
+			expectedResponse: msgInvalidParams,
+		},
+	} {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s%s", clusterName, tc.path), nil)
+			w := httptest.NewRecorder()
+
+			a.router.ServeHTTP(w, r)
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedResponse, w.Body.String())
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v0/clusters/%s/snapshots/1600000300", tNotFoundCluster), nil)
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "cluster snapshot not found", w.Body.String())
+}
+
 func (a *apiSuite) jsonMarshal(v interface{}) string {
 	data, err := json.Marshal(v)
 	require.NoError(a.T(), err)
 
 	return string(data)
 }
+
+func tConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Qumomf.ClusterDiscoveryTime = time.Hour
+	cfg.Qumomf.ClusterRecoveryTime = time.Hour
+	cfg.Qumomf.Hooks.PreFailover = []config.HookConfig{
+		{Command: "echo 'pre failover'"},
+	}
+
+	return cfg
+}
+
+func tClusterConfig() config.ClusterConfig {
+	return config.ClusterConfig{
+		Connection: &config.ConnectConfig{
+			User:           util.NewString("qumomf"),
+			Password:       util.NewString("qumomf"),
+			ConnectTimeout: util.NewDuration(time.Second),
+			RequestTimeout: util.NewDuration(time.Second),
+		},
+		ReadOnly:                util.NewBool(true),
+		ElectionMode:            util.NewString("smart"),
+		MaxConcurrentRecoveries: util.NewInt(1),
+		ElectorWeights:          &config.ElectorWeights{},
+		MasterAntiAffinity:      util.NewBool(false),
+		Hooks: &config.ClusterHooksConfig{
+			HooksConfig: config.HooksConfig{
+				PostSuccessfulFailover: []config.HookConfig{
+					{
+						Name:    "notify",
+						URL:     "http://example.com/hook",
+						Headers: map[string]string{"Authorization": "Bearer token"},
+						Secret:  "secret",
+						Timeout: time.Second,
+					},
+				},
+			},
+		},
+		Routers: []config.RouterConfig{
+			{
+				Name: "router",
+				Addr: tRouterURI,
+			},
+		},
+	}
+}
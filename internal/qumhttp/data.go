@@ -2,6 +2,8 @@ package qumhttp
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/shmel1k/qumomf/internal/vshard"
 )
@@ -19,6 +21,13 @@ func newOKResponse(data []byte) response {
 	}
 }
 
+func newAcceptedResponse(data []byte) response {
+	return response{
+		statusCode: http.StatusAccepted,
+		data:       data,
+	}
+}
+
 func newBadRequestResponse(msg string) response {
 	return response{
 		statusCode: http.StatusBadRequest,
@@ -26,6 +35,13 @@ func newBadRequestResponse(msg string) response {
 	}
 }
 
+func newConflictResponse(msg string) response {
+	return response{
+		statusCode: http.StatusConflict,
+		data:       []byte(msg),
+	}
+}
+
 func newInternalErrResponse(msg string, err error) response {
 	return response{
 		statusCode: http.StatusInternalServerError,
@@ -38,12 +54,39 @@ type params struct {
 	clusterName  string
 	shardUUID    vshard.ReplicaSetUUID
 	instanceUUID vshard.InstanceUUID
+	host         string
+	// jobID is zero if the parameter is not set or invalid.
+	jobID int64
 }
 
 func parseParams(vars map[string]string) params {
+	jobID, _ := strconv.ParseInt(vars[paramJobID], 10, 64)
+
 	return params{
 		clusterName:  vars[paramClusterName],
 		shardUUID:    vshard.ReplicaSetUUID(vars[paramShardUUID]),
 		instanceUUID: vshard.InstanceUUID(vars[paramInstanceUUID]),
+		host:         vars[paramHost],
+		jobID:        jobID,
 	}
 }
+
+// parseTime parses the time given either as a unix time in seconds or in RFC 3339 format.
+// Empty value is parsed as zero.
+func parseTime(v string) (int64, bool) {
+	if v == "" {
+		return 0, true
+	}
+
+	ts, err := strconv.ParseInt(v, 10, 64)
+	if err == nil {
+		return ts, ts >= 0
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, false
+	}
+
+	return t.Unix(), true
+}
@@ -23,4 +23,19 @@ func RegisterAPIHandlers(r *mux.Router, h APIHandler) {
 
 	r.HandleFunc("/api/v0/alerts", h.Alerts).Methods(http.MethodGet)
 	r.HandleFunc("/api/v0/alerts/{cluster_name}", h.ClusterAlerts).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/readonly", h.SetClusterReadOnly).Methods(http.MethodPut)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/readonly", h.ClusterReadOnlyOverrides).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/rebalance", h.ClusterRebalancePlan).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/rebalance", h.RebalanceCluster).Methods(http.MethodPost)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/rebalance/report", h.RebalanceReport).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/hosts/{host}/drain", h.DrainHost).Methods(http.MethodPut)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/hosts/{host}/drain", h.DrainReport).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/hosts/{host}/drain", h.UndrainHost).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/drains", h.HostDrains).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/hooks", h.ClusterHooks).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/hook_jobs", h.HookJobs).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/hook_jobs/{job_id}/retry", h.RetryHookJob).Methods(http.MethodPost)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/snapshots", h.SnapshotHistory).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/snapshots/diff", h.SnapshotDiff).Methods(http.MethodGet)
+	r.HandleFunc("/api/v0/clusters/{cluster_name}/snapshots/{timestamp}", h.ClusterSnapshotAt).Methods(http.MethodGet)
 }
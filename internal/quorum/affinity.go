@@ -0,0 +1,35 @@
+package quorum
+
+import "github.com/shmel1k/qumomf/internal/vshard"
+
+// otherMasterHosts returns the known hosts of the masters of other replica sets.
+func otherMasterHosts(set vshard.ReplicaSet, opts Options) map[string]struct{} {
+	hosts := make(map[string]struct{})
+	if opts.ReplicaSets == nil {
+		return hosts
+	}
+
+	for _, other := range opts.ReplicaSets() { //nolint:gocritic
+		if other.UUID == set.UUID {
+			continue
+		}
+
+		master, err := other.Master()
+		if err != nil || master.Host() == "" {
+			continue
+		}
+		hosts[master.Host()] = struct{}{}
+	}
+
+	return hosts
+}
+
+// colocationRank returns 1 if the anti-affinity rule is enabled
+// and the candidate host already runs a master of another replica set.
+func colocationRank(d *Decision, uuid vshard.InstanceUUID, opts Options) int {
+	if opts.MasterAntiAffinity && d.colocated[uuid] {
+		return 1
+	}
+
+	return 0
+}
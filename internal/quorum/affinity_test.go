@@ -0,0 +1,128 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+func TestMasterAntiAffinity(t *testing.T) {
+	best := mockFollower("2", 0, 0, 0.01)
+	best.URI = "host1:3302"
+
+	second := mockFollower("3", 0, 0, 0.02)
+	second.URI = "10.0.0.1:3302"
+	second.HostLabel = "host2"
+
+	set := vshard.ReplicaSet{
+		UUID:       "set1",
+		MasterUUID: "1",
+		Instances:  []vshard.Instance{mockDeadMaster("1"), best, second},
+	}
+
+	another := vshard.ReplicaSet{
+		UUID:       "set2",
+		MasterUUID: "4",
+		Instances: []vshard.Instance{
+			{
+				UUID: "4",
+				URI:  "host1:3301",
+				StorageInfo: vshard.StorageInfo{
+					Replication: vshard.Replication{
+						Status: vshard.StatusMaster,
+					},
+				},
+			},
+		},
+	}
+
+	replicaSets := func() []vshard.ReplicaSet {
+		return []vshard.ReplicaSet{set, another}
+	}
+
+	var testData = []struct {
+		name         string
+		antiAffinity bool
+		expectedUUID vshard.InstanceUUID
+	}{
+		{name: "Disabled", antiAffinity: false, expectedUUID: "2"},
+		{name: "Enabled", antiAffinity: true, expectedUUID: "3"},
+	}
+
+	for _, v := range testData {
+		vt := v
+		t.Run(v.name, func(t *testing.T) {
+			opts := Options{
+				MasterAntiAffinity: vt.antiAffinity,
+				ReplicaSets:        replicaSets,
+			}
+
+			for _, e := range []Elector{NewSmartElector(opts), NewIdleElector(opts), NewPriorityElector(opts)} {
+				decision, err := e.ChooseMaster(set)
+				require.Nil(t, err)
+				assert.Equal(t, vt.expectedUUID, decision.Winner, e.Mode())
+
+				c, ok := decision.Candidate("2")
+				require.True(t, ok)
+				assert.Equal(t, "host1", c.Host)
+				assert.True(t, c.MasterColocation)
+
+				c, ok = decision.Candidate("3")
+				require.True(t, ok)
+				assert.Equal(t, "host2", c.Host)
+				assert.False(t, c.MasterColocation)
+			}
+		})
+	}
+}
+
+func TestMasterAntiAffinity_UnknownHost(t *testing.T) {
+	best := mockFollower("2", 0, 0, 0.01)
+	best.URI = ":3302"
+
+	second := mockFollower("3", 0, 0, 0.02)
+	second.URI = "host2:3302"
+
+	set := vshard.ReplicaSet{
+		UUID:       "set1",
+		MasterUUID: "1",
+		Instances:  []vshard.Instance{mockDeadMaster("1"), best, second},
+	}
+
+	another := vshard.ReplicaSet{
+		UUID:       "set2",
+		MasterUUID: "4",
+		Instances: []vshard.Instance{
+			{
+				UUID: "4",
+				URI:  ":3301",
+				StorageInfo: vshard.StorageInfo{
+					Replication: vshard.Replication{
+						Status: vshard.StatusMaster,
+					},
+				},
+			},
+		},
+	}
+
+	opts := Options{
+		MasterAntiAffinity: true,
+		ReplicaSets: func() []vshard.ReplicaSet {
+			return []vshard.ReplicaSet{set, another}
+		},
+	}
+
+	for _, e := range []Elector{NewSmartElector(opts), NewIdleElector(opts), NewPriorityElector(opts)} {
+		decision, err := e.ChooseMaster(set)
+		require.Nil(t, err)
+		assert.Equal(t, vshard.InstanceUUID("2"), decision.Winner, e.Mode())
+
+		c, ok := decision.Candidate("2")
+		require.True(t, ok)
+		assert.Empty(t, c.Host)
+		assert.False(t, c.MasterColocation)
+	}
+}
@@ -0,0 +1,173 @@
+package quorum
+
+import (
+	"sort"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+// ExclusionReason is a name of the filter which
+// excluded the follower from the election.
+type ExclusionReason string
+
+const (
+	ExcludedNotAlive         ExclusionReason = "not_alive"
+	ExcludedNegativePriority ExclusionReason = "negative_priority"
+	ExcludedDrainedHost      ExclusionReason = "drained_host"
+	ExcludedForbiddenZone    ExclusionReason = "forbidden_zone"
+	ExcludedLSNLag           ExclusionReason = "lsn_lag"
+	ExcludedIdle             ExclusionReason = "idle"
+)
+
+// Candidate describes a follower participated in the election.
+type Candidate struct {
+	UUID     vshard.InstanceUUID `json:"uuid"`
+	URI      string              `json:"uri"`
+	Eligible bool                `json:"eligible"`
+	// ExcludedBy is a filter which excluded the follower from the election.
+	// Empty for the eligible followers.
+	ExcludedBy ExclusionReason `json:"excluded_by,omitempty"`
+	// Rank is a 1-based position of the eligible follower
+	// in the ordering of the elector. The winner has rank 1.
+	Rank int `json:"rank,omitempty"`
+
+	// Ordering keys used by the electors.
+	FingerprintMatch bool                  `json:"fingerprint_match"`
+	UpstreamStatus   vshard.UpstreamStatus `json:"upstream_status"`
+	Zone             string                `json:"zone"`
+	ZoneRank         int                   `json:"zone_rank"`
+	LSNBehindMaster  int64                 `json:"lsn_behind_master"`
+	Idle             float64               `json:"idle"`
+	Priority         int                   `json:"priority"`
+	Host             string                `json:"host"`
+	// MasterColocation indicates whether the candidate host
+	// already runs a master of another replica set.
+	MasterColocation bool `json:"master_colocation"`
+	// Score is a candidate score calculated by the weighted elector.
+	Score float64 `json:"score,omitempty"`
+}
+
+// Decision is a result of the master election.
+type Decision struct {
+	Mode Mode `json:"mode"`
+	// Winner is a follower chosen to be a new master.
+	// Empty if no candidate found.
+	Winner vshard.InstanceUUID `json:"winner"`
+	// Candidates contains all the followers of the replica set:
+	// eligible ones ordered by rank, then the excluded ones.
+	Candidates []Candidate `json:"candidates"`
+
+	// colocated contains the candidates located
+	// on the hosts with masters of other replica sets.
+	colocated map[vshard.InstanceUUID]bool
+}
+
+// newDecision describes all the followers of the replica set
+// and returns the decision with the followers eligible to be a master.
+func newDecision(mode Mode, set vshard.ReplicaSet, opts Options) (*Decision, []vshard.Instance) {
+	master, _ := set.Master()
+
+	alive := make(map[vshard.InstanceUUID]struct{})
+	for _, inst := range set.AliveFollowers() { //nolint:gocritic
+		alive[inst.UUID] = struct{}{}
+	}
+
+	masterHosts := otherMasterHosts(set, opts)
+
+	followers := set.Followers()
+	d := &Decision{
+		Mode:       mode,
+		Candidates: make([]Candidate, 0, len(followers)),
+		colocated:  make(map[vshard.InstanceUUID]bool),
+	}
+	eligible := make([]vshard.Instance, 0, len(followers))
+
+	for i := range followers {
+		inst := &followers[i]
+
+		var reason ExclusionReason
+		if _, ok := alive[inst.UUID]; !ok {
+			reason = ExcludedNotAlive
+		} else {
+			reason = exclusionReason(inst, opts)
+		}
+
+		_, colocated := masterHosts[inst.Host()]
+		d.colocated[inst.UUID] = colocated
+
+		d.Candidates = append(d.Candidates, Candidate{
+			UUID:             inst.UUID,
+			URI:              inst.URI,
+			Eligible:         reason == "",
+			ExcludedBy:       reason,
+			FingerprintMatch: inst.VShardFingerprint == master.VShardFingerprint,
+			UpstreamStatus:   upstreamStatus(inst),
+			Zone:             inst.Zone,
+			ZoneRank:         opts.ZonePolicy.rank(inst.Zone, master.Zone),
+			LSNBehindMaster:  inst.LSNBehindMaster,
+			Idle:             inst.Idle(),
+			Priority:         inst.Priority,
+			Host:             inst.Host(),
+			MasterColocation: colocated,
+		})
+
+		if reason == "" {
+			eligible = append(eligible, *inst)
+		}
+	}
+
+	return d, eligible
+}
+
+// choose ranks the candidates in the given order and picks the first one as the winner.
+func (d *Decision) choose(ranked []vshard.Instance) {
+	ranks := make(map[vshard.InstanceUUID]int, len(ranked))
+	for i := range ranked {
+		ranks[ranked[i].UUID] = i + 1
+	}
+
+	for i := range d.Candidates {
+		c := &d.Candidates[i]
+		c.Rank = ranks[c.UUID]
+	}
+
+	sort.SliceStable(d.Candidates, func(i, j int) bool {
+		left, right := d.Candidates[i].Rank, d.Candidates[j].Rank
+		if left == 0 || right == 0 {
+			return left != 0 && right == 0
+		}
+		return left < right
+	})
+
+	if len(ranked) > 0 {
+		d.Winner = ranked[0].UUID
+	}
+}
+
+func (d *Decision) setScore(uuid vshard.InstanceUUID, score float64) {
+	for i := range d.Candidates {
+		if d.Candidates[i].UUID == uuid {
+			d.Candidates[i].Score = score
+			return
+		}
+	}
+}
+
+// Candidate returns the election details of the follower.
+func (d *Decision) Candidate(uuid vshard.InstanceUUID) (Candidate, bool) {
+	for _, c := range d.Candidates { //nolint:gocritic
+		if c.UUID == uuid {
+			return c, true
+		}
+	}
+
+	return Candidate{}, false
+}
+
+func upstreamStatus(inst *vshard.Instance) vshard.UpstreamStatus {
+	if inst.Upstream == nil {
+		return ""
+	}
+
+	return inst.Upstream.Status
+}
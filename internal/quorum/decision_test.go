@@ -0,0 +1,94 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+func TestDecision(t *testing.T) {
+	dead := mockFollower("5", 0, 0, 0.01)
+	dead.LastCheckValid = false
+
+	set := vshard.ReplicaSet{
+		MasterUUID: "1",
+		Instances: []vshard.Instance{
+			mockDeadMaster("1"),
+			mockFollower("2", -1, 0, 0.01),
+			mockFollower("3", 0, 10, 0.2),
+			mockFollower("4", 0, 1000, 0.01),
+			dead,
+			mockFollower("6", 0, 0, 0.1),
+		},
+	}
+
+	e := NewSmartElector(Options{
+		ReasonableFollowerLSNLag: 100,
+	})
+
+	decision, err := e.ChooseMaster(set)
+	require.Nil(t, err)
+
+	assert.Equal(t, ModeSmart, decision.Mode)
+	assert.Equal(t, vshard.InstanceUUID("6"), decision.Winner)
+
+	type result struct {
+		UUID       vshard.InstanceUUID
+		Eligible   bool
+		ExcludedBy ExclusionReason
+		Rank       int
+	}
+	expected := []result{
+		{UUID: "6", Eligible: true, Rank: 1},
+		{UUID: "3", Eligible: true, Rank: 2},
+		{UUID: "2", ExcludedBy: ExcludedNegativePriority},
+		{UUID: "4", ExcludedBy: ExcludedLSNLag},
+		{UUID: "5", ExcludedBy: ExcludedNotAlive},
+	}
+	got := make([]result, 0, len(decision.Candidates))
+	for _, c := range decision.Candidates {
+		got = append(got, result{
+			UUID:       c.UUID,
+			Eligible:   c.Eligible,
+			ExcludedBy: c.ExcludedBy,
+			Rank:       c.Rank,
+		})
+	}
+	assert.Equal(t, expected, got)
+
+	c, ok := decision.Candidate("3")
+	require.True(t, ok)
+	assert.Equal(t, int64(10), c.LSNBehindMaster)
+	assert.Equal(t, 0.2, c.Idle)
+	assert.Equal(t, vshard.UpstreamFollow, c.UpstreamStatus)
+	assert.True(t, c.FingerprintMatch)
+
+	_, ok = decision.Candidate("1")
+	assert.False(t, ok)
+}
+
+func TestDecision_AllExcluded(t *testing.T) {
+	set := vshard.ReplicaSet{
+		MasterUUID: "1",
+		Instances: []vshard.Instance{
+			mockDeadMaster("1"),
+			mockFollower("2", -1, 0, 0.01),
+			mockFollower("3", 0, 0, 10),
+		},
+	}
+
+	e := NewIdleElector(Options{
+		ReasonableFollowerIdle: 5,
+	})
+
+	decision, err := e.ChooseMaster(set)
+	assert.Equal(t, ErrNoAliveFollowers, err)
+	require.NotNil(t, decision)
+	assert.Empty(t, decision.Winner)
+	require.Len(t, decision.Candidates, 2)
+	assert.Equal(t, ExcludedNegativePriority, decision.Candidates[0].ExcludedBy)
+	assert.Equal(t, ExcludedIdle, decision.Candidates[1].ExcludedBy)
+}
@@ -10,8 +10,10 @@ import (
 type Mode string
 
 const (
-	ModeIdle  Mode = "idle"
-	ModeSmart Mode = "smart"
+	ModeIdle     Mode = "idle"
+	ModeSmart    Mode = "smart"
+	ModePriority Mode = "priority"
+	ModeWeighted Mode = "weighted"
 )
 
 var (
@@ -22,11 +24,22 @@ var (
 type Options struct {
 	ReasonableFollowerLSNLag int64
 	ReasonableFollowerIdle   float64
+	ZonePolicy               ZonePolicy
+	// Weights are used by the weighted elector.
+	Weights Weights
+	// MasterAntiAffinity makes the electors prefer candidates whose host
+	// does not run a master of another replica set.
+	MasterAntiAffinity bool
+	// ReplicaSets returns the current replica sets of the cluster.
+	// Used to find hosts of the masters of other replica sets.
+	ReplicaSets func() []vshard.ReplicaSet
 }
 
 type Elector interface {
-	// ChooseMaster selects new master and returns back its uuid.
-	ChooseMaster(set vshard.ReplicaSet) (vshard.InstanceUUID, error)
+	// ChooseMaster selects new master and returns back the election decision.
+	// The decision is returned even if no candidate found
+	// so it is possible to see why the followers were excluded.
+	ChooseMaster(set vshard.ReplicaSet) (*Decision, error)
 	// Mode returns the elector type.
 	Mode() Mode
 }
@@ -37,39 +50,46 @@ func New(m Mode, opts Options) Elector {
 		return NewIdleElector(opts)
 	case ModeSmart:
 		return NewSmartElector(opts)
+	case ModePriority:
+		return NewPriorityElector(opts)
+	case ModeWeighted:
+		return NewWeightedElector(opts)
 	}
 
 	panic(fmt.Sprintf("Elector: got unknown mode %s", m))
 }
 
-// filter filters out the instances which must not be promoted to the master.
-func filter(instances []vshard.Instance, opts Options) []vshard.Instance {
-	filtered := make([]vshard.Instance, 0, len(instances))
+// exclusionReason returns the filter which excludes the instance from
+// the election or empty string if the instance might be promoted to the master.
+func exclusionReason(inst *vshard.Instance, opts Options) ExclusionReason {
+	// Exclude all followers with negative priority.
+	if inst.Priority < 0 {
+		return ExcludedNegativePriority
+	}
 
-	for i := range instances {
-		inst := &instances[i]
+	// Exclude followers located on the drained hosts.
+	if inst.Drained {
+		return ExcludedDrainedHost
+	}
 
-		// Exclude all followers with negative priority.
-		if inst.Priority < 0 {
-			continue
-		}
+	// Exclude followers from the forbidden zones.
+	if opts.ZonePolicy.forbidden(inst.Zone) {
+		return ExcludedForbiddenZone
+	}
 
-		if opts.ReasonableFollowerLSNLag != 0 {
-			// Exclude followers too far from the master.
-			if inst.LSNBehindMaster > opts.ReasonableFollowerLSNLag {
-				continue
-			}
+	if opts.ReasonableFollowerLSNLag != 0 {
+		// Exclude followers too far from the master.
+		if inst.LSNBehindMaster > opts.ReasonableFollowerLSNLag {
+			return ExcludedLSNLag
 		}
+	}
 
-		if opts.ReasonableFollowerIdle != 0 {
-			// Exclude followers too far from the master.
-			if inst.Idle() > opts.ReasonableFollowerIdle {
-				continue
-			}
+	if opts.ReasonableFollowerIdle != 0 {
+		// Exclude followers too far from the master.
+		if inst.Idle() > opts.ReasonableFollowerIdle {
+			return ExcludedIdle
 		}
-
-		filtered = append(filtered, *inst)
 	}
 
-	return filtered
+	return ""
 }
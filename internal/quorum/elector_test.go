@@ -8,7 +8,7 @@ import (
 	"github.com/shmel1k/qumomf/internal/vshard"
 )
 
-func Test_filter(t *testing.T) {
+func Test_exclusionReason(t *testing.T) {
 	tests := []struct {
 		name      string
 		opts      Options
@@ -36,6 +36,22 @@ func Test_filter(t *testing.T) {
 				"2", "3",
 			},
 		},
+		{
+			name: "ExcludeByDrainedHost",
+			opts: Options{},
+			instances: []vshard.Instance{
+				{
+					UUID:    "1",
+					Drained: true,
+				},
+				{
+					UUID: "2",
+				},
+			},
+			want: []vshard.InstanceUUID{
+				"2",
+			},
+		},
 		{
 			name: "ExcludeByLSN",
 			opts: Options{
@@ -91,6 +107,30 @@ func Test_filter(t *testing.T) {
 				"2", "3",
 			},
 		},
+		{
+			name: "ExcludeByZone",
+			opts: Options{
+				ZonePolicy: ZonePolicy{
+					ForbiddenZones: []string{"dc2"},
+				},
+			},
+			instances: []vshard.Instance{
+				{
+					UUID: "1",
+					Zone: "dc1",
+				},
+				{
+					UUID: "2",
+					Zone: "dc2",
+				},
+				{
+					UUID: "3",
+				},
+			},
+			want: []vshard.InstanceUUID{
+				"1", "3",
+			},
+		},
 		{
 			name: "ExcludeAll",
 			opts: Options{
@@ -133,10 +173,12 @@ func Test_filter(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			got := filter(tt.instances, tt.opts)
-			uuids := make([]vshard.InstanceUUID, len(got))
-			for i, inst := range got {
-				uuids[i] = inst.UUID
+			uuids := make([]vshard.InstanceUUID, 0, len(tt.instances))
+			for i := range tt.instances {
+				inst := &tt.instances[i]
+				if exclusionReason(inst, tt.opts) == "" {
+					uuids = append(uuids, inst.UUID)
+				}
 			}
 			assert.Equal(t, tt.want, uuids)
 		})
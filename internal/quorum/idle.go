@@ -1,15 +1,11 @@
 package quorum
 
 import (
-	"math"
+	"sort"
 
 	"github.com/shmel1k/qumomf/internal/vshard"
 )
 
-const (
-	maxIdle = math.MaxFloat64
-)
-
 type idleElector struct {
 	opts Options
 }
@@ -17,35 +13,46 @@ type idleElector struct {
 // NewIdleElector returns a new elector based on the follower's idle value.
 //
 // This elector chooses the candidate to be a master selecting
-// the follower with a minimum idle value.
+// the follower with a minimum idle value among the followers
+// located in the most preferred zone and, if master anti-affinity
+// is enabled, on hosts without masters of other replica sets.
 func NewIdleElector(opts Options) Elector {
 	return &idleElector{
 		opts: opts,
 	}
 }
 
-func (e *idleElector) ChooseMaster(set vshard.ReplicaSet) (vshard.InstanceUUID, error) {
-	followers := filter(set.AliveFollowers(), e.opts)
+func (e *idleElector) ChooseMaster(set vshard.ReplicaSet) (*Decision, error) {
+	decision, followers := newDecision(ModeIdle, set, e.opts)
 	if len(followers) == 0 {
-		return "", ErrNoAliveFollowers
+		return decision, ErrNoAliveFollowers
+	}
+
+	masterZone := ""
+	if master, err := set.Master(); err == nil {
+		masterZone = master.Zone
 	}
 
-	minIdle := maxIdle
-	minUUID := vshard.InstanceUUID("")
-	for i := range followers {
-		r := &followers[i]
+	sort.SliceStable(followers, func(i, j int) bool {
+		left, right := &followers[i], &followers[j]
 
-		if r.Idle() < minIdle {
-			minIdle = r.Idle()
-			minUUID = r.UUID
+		leftRank := e.opts.ZonePolicy.rank(left.Zone, masterZone)
+		rightRank := e.opts.ZonePolicy.rank(right.Zone, masterZone)
+		if leftRank != rightRank {
+			return leftRank < rightRank
 		}
-	}
 
-	if minUUID == "" {
-		return "", ErrNoCandidateFound
-	}
+		leftRank = colocationRank(decision, left.UUID, e.opts)
+		rightRank = colocationRank(decision, right.UUID, e.opts)
+		if leftRank != rightRank {
+			return leftRank < rightRank
+		}
+
+		return left.Idle() < right.Idle()
+	})
+	decision.choose(followers)
 
-	return minUUID, nil
+	return decision, nil
 }
 
 func (*idleElector) Mode() Mode {
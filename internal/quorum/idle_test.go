@@ -139,9 +139,9 @@ func TestIdleElector(t *testing.T) {
 	for _, v := range testData {
 		vt := v
 		t.Run(v.name, func(t *testing.T) {
-			uuid, err := e.ChooseMaster(vt.set)
+			decision, err := e.ChooseMaster(vt.set)
 			assert.Equal(t, vt.expectedErr, err)
-			assert.Equal(t, vt.expectedUUID, uuid)
+			assert.Equal(t, vt.expectedUUID, decision.Winner)
 		})
 	}
 }
@@ -0,0 +1,64 @@
+package quorum
+
+import (
+	"sort"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+type priorityElector struct {
+	opts Options
+}
+
+// NewPriorityElector returns a new elector based on the user promotion rules.
+//
+// This elector always chooses the follower with the highest priority.
+// Master anti-affinity, LSN behind the master and idle are used
+// only to choose between the followers with the same priority.
+func NewPriorityElector(opts Options) Elector {
+	return &priorityElector{
+		opts: opts,
+	}
+}
+
+func (e *priorityElector) ChooseMaster(set vshard.ReplicaSet) (*Decision, error) {
+	decision, followers := newDecision(ModePriority, set, e.opts)
+	if len(followers) == 0 {
+		return decision, ErrNoAliveFollowers
+	}
+
+	sort.SliceStable(followers, func(i, j int) bool {
+		left, right := &followers[i], &followers[j]
+
+		if left.Priority != right.Priority {
+			return left.Priority > right.Priority
+		}
+
+		leftRank := colocationRank(decision, left.UUID, e.opts)
+		rightRank := colocationRank(decision, right.UUID, e.opts)
+		if leftRank != rightRank {
+			return leftRank < rightRank
+		}
+
+		if left.LSNBehindMaster != right.LSNBehindMaster {
+			// Replica with LSN in front of master LSN likely has broken replication.
+			if left.LSNBehindMaster >= 0 && right.LSNBehindMaster < 0 {
+				return true
+			}
+			if left.LSNBehindMaster < 0 && right.LSNBehindMaster >= 0 {
+				return false
+			}
+
+			return left.LSNBehindMaster < right.LSNBehindMaster
+		}
+
+		return left.Idle() < right.Idle()
+	})
+	decision.choose(followers)
+
+	return decision, nil
+}
+
+func (*priorityElector) Mode() Mode {
+	return ModePriority
+}
@@ -0,0 +1,130 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+func mockFollower(uuid vshard.InstanceUUID, priority int, lsnBehindMaster int64, idle float64) vshard.Instance {
+	return vshard.Instance{
+		UUID:            uuid,
+		LastCheckValid:  true,
+		LSNBehindMaster: lsnBehindMaster,
+		Priority:        priority,
+		Upstream: &vshard.Upstream{
+			Status: vshard.UpstreamFollow,
+			Idle:   idle,
+		},
+		Downstream: &vshard.Downstream{
+			Status: vshard.DownstreamFollow,
+		},
+		StorageInfo: vshard.StorageInfo{
+			Replication: vshard.Replication{
+				Status: vshard.StatusFollow,
+			},
+		},
+	}
+}
+
+func mockDeadMaster(uuid vshard.InstanceUUID) vshard.Instance {
+	return vshard.Instance{
+		UUID:           uuid,
+		LastCheckValid: false,
+		StorageInfo: vshard.StorageInfo{
+			Replication: vshard.Replication{
+				Status: vshard.StatusMaster,
+			},
+		},
+	}
+}
+
+func TestPriorityElector(t *testing.T) {
+	var testData = []struct {
+		name         string
+		set          vshard.ReplicaSet
+		expectedUUID vshard.InstanceUUID
+		expectedErr  error
+	}{
+		{
+			name: "ShouldSelectHighestPriority",
+			set: vshard.ReplicaSet{
+				MasterUUID: "1",
+				Instances: []vshard.Instance{
+					mockDeadMaster("1"),
+					mockFollower("2", 0, 0, 0.01),
+					mockFollower("3", 10, 50, 4),
+					mockFollower("4", 5, 0, 0.01),
+				},
+			},
+			expectedUUID: "3",
+		},
+		{
+			name: "SamePriority_ShouldSelectByLSN",
+			set: vshard.ReplicaSet{
+				MasterUUID: "1",
+				Instances: []vshard.Instance{
+					mockDeadMaster("1"),
+					mockFollower("2", 10, 20, 0.01),
+					mockFollower("3", 10, 10, 1),
+					mockFollower("4", 10, -5, 0.01),
+				},
+			},
+			expectedUUID: "3",
+		},
+		{
+			name: "SamePriorityAndLSN_ShouldSelectByIdle",
+			set: vshard.ReplicaSet{
+				MasterUUID: "1",
+				Instances: []vshard.Instance{
+					mockDeadMaster("1"),
+					mockFollower("2", 10, 0, 0.5),
+					mockFollower("3", 10, 0, 0.1),
+				},
+			},
+			expectedUUID: "3",
+		},
+		{
+			name: "ShouldRespectFilter",
+			set: vshard.ReplicaSet{
+				MasterUUID: "1",
+				Instances: []vshard.Instance{
+					mockDeadMaster("1"),
+					mockFollower("2", 100, 1000, 0.1),
+					mockFollower("3", 50, 0, 10),
+					mockFollower("4", -1, 0, 0.1),
+					mockFollower("5", 0, 0, 0.1),
+				},
+			},
+			expectedUUID: "5",
+		},
+		{
+			name: "NoAliveFollowers_ShouldReturnErr",
+			set: vshard.ReplicaSet{
+				MasterUUID: "1",
+				Instances: []vshard.Instance{
+					mockDeadMaster("1"),
+					mockFollower("2", 100, 1000, 0.1),
+					mockFollower("3", -1, 0, 0.1),
+				},
+			},
+			expectedErr: ErrNoAliveFollowers,
+		},
+	}
+
+	e := NewPriorityElector(Options{
+		ReasonableFollowerLSNLag: 100,
+		ReasonableFollowerIdle:   5,
+	})
+
+	for _, v := range testData {
+		vt := v
+		t.Run(v.name, func(t *testing.T) {
+			decision, err := e.ChooseMaster(vt.set)
+			assert.Equal(t, vt.expectedErr, err)
+			assert.Equal(t, vt.expectedUUID, decision.Winner)
+		})
+	}
+}
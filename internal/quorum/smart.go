@@ -18,6 +18,8 @@ type smartElector struct {
 // NewSmartElector returns a new elector based on rules:
 //  - compare vshard configuration consistency,
 //  - compare upstream status,
+//  - compare zone preferences,
+//  - compare master anti-affinity across hosts,
 //  - compare LSN behind the master,
 //  - compare when replica got last heartbeat signal or data from master,
 //  - user promotion rules based on instance priorities.
@@ -27,20 +29,24 @@ func NewSmartElector(opts Options) Elector {
 	}
 }
 
-func (e *smartElector) ChooseMaster(set vshard.ReplicaSet) (vshard.InstanceUUID, error) {
-	followers := filter(set.AliveFollowers(), e.opts)
+func (e *smartElector) ChooseMaster(set vshard.ReplicaSet) (*Decision, error) {
+	decision, followers := newDecision(ModeSmart, set, e.opts)
 	if len(followers) == 0 {
-		return "", ErrNoAliveFollowers
+		return decision, ErrNoAliveFollowers
 	}
 
 	master, err := set.Master()
 	if err != nil {
-		return "", err
+		return decision, err
+	}
+	sorter := newInstanceSorter(master, followers, e.opts.ZonePolicy)
+	sorter.colocation = func(uuid vshard.InstanceUUID) int {
+		return colocationRank(decision, uuid, e.opts)
 	}
-	sorter := newInstanceSorter(master, followers)
 	sort.Sort(sorter)
+	decision.choose(followers)
 
-	return followers[0].UUID, nil
+	return decision, nil
 }
 
 func (e *smartElector) Mode() Mode {
@@ -49,14 +55,18 @@ func (e *smartElector) Mode() Mode {
 
 // instanceSorter sorts instances by their priority to be a new master.
 type instanceSorter struct {
-	master    vshard.Instance
-	instances []vshard.Instance
+	master     vshard.Instance
+	instances  []vshard.Instance
+	zonePolicy ZonePolicy
+	// colocation returns the anti-affinity rank of the instance.
+	colocation func(uuid vshard.InstanceUUID) int
 }
 
-func newInstanceSorter(master vshard.Instance, instances []vshard.Instance) *instanceSorter {
+func newInstanceSorter(master vshard.Instance, instances []vshard.Instance, zonePolicy ZonePolicy) *instanceSorter {
 	return &instanceSorter{
-		master:    master,
-		instances: instances,
+		master:     master,
+		instances:  instances,
+		zonePolicy: zonePolicy,
 	}
 }
 
@@ -89,6 +99,21 @@ func (s *instanceSorter) Less(i, j int) bool {
 		return false
 	}
 
+	// Prefer replicas from the preferred zones.
+	leftRank := s.zonePolicy.rank(left.Zone, s.master.Zone)
+	rightRank := s.zonePolicy.rank(right.Zone, s.master.Zone)
+	if leftRank != rightRank {
+		return leftRank < rightRank
+	}
+
+	// Prefer replicas on hosts without masters of other replica sets.
+	if s.colocation != nil {
+		leftRank, rightRank = s.colocation(left.UUID), s.colocation(right.UUID)
+		if leftRank != rightRank {
+			return leftRank < rightRank
+		}
+	}
+
 	// Prefer most up to date replica.
 	if left.LSNBehindMaster != right.LSNBehindMaster {
 		// Special case: when replication is broken and replica has been recovered from an old snapshot with
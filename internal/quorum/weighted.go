@@ -0,0 +1,142 @@
+package quorum
+
+import (
+	"sort"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+// Weights defines the contribution of each factor to the candidate score.
+//
+// All factors are normalised to [0, 1] among the eligible followers.
+type Weights struct {
+	// LSNLag rewards followers closer to the master by LSN.
+	LSNLag float64
+	// Idle rewards followers which got data or heartbeat from the master recently.
+	Idle float64
+	// UpstreamStatus rewards followers with follow upstream status.
+	UpstreamStatus float64
+	// Fingerprint rewards followers with the same vshard configuration as master.
+	Fingerprint float64
+	// Priority rewards followers with higher user priority.
+	Priority float64
+	// Zone rewards followers from the preferred zones.
+	Zone float64
+	// MasterColocation penalizes followers located on a host
+	// which already hosts a master of another replica set.
+	MasterColocation float64
+}
+
+type weightedElector struct {
+	opts Options
+}
+
+// NewWeightedElector returns a new elector based on the weighted sum of
+// normalised factors: LSN lag, idle, upstream status, vshard configuration
+// consistency, priority, zone and master colocation.
+//
+// This elector chooses the follower with the highest score.
+func NewWeightedElector(opts Options) Elector {
+	return &weightedElector{
+		opts: opts,
+	}
+}
+
+func (e *weightedElector) ChooseMaster(set vshard.ReplicaSet) (*Decision, error) {
+	decision, followers := newDecision(ModeWeighted, set, e.opts)
+	if len(followers) == 0 {
+		return decision, ErrNoAliveFollowers
+	}
+
+	master, _ := set.Master()
+	scores := e.score(decision, master, followers)
+	for i := range followers {
+		decision.setScore(followers[i].UUID, scores[followers[i].UUID])
+	}
+
+	sort.SliceStable(followers, func(i, j int) bool {
+		return scores[followers[i].UUID] > scores[followers[j].UUID]
+	})
+	decision.choose(followers)
+
+	return decision, nil
+}
+
+func (*weightedElector) Mode() Mode {
+	return ModeWeighted
+}
+
+func (e *weightedElector) score(d *Decision, master vshard.Instance, followers []vshard.Instance) map[vshard.InstanceUUID]float64 {
+	w := e.opts.Weights
+
+	lsn := newRange()
+	idle := newRange()
+	priority := newRange()
+	zone := newRange()
+	for i := range followers {
+		inst := &followers[i]
+		if inst.LSNBehindMaster >= 0 {
+			lsn.add(float64(inst.LSNBehindMaster))
+		}
+		idle.add(inst.Idle())
+		priority.add(float64(inst.Priority))
+		zone.add(float64(e.opts.ZonePolicy.rank(inst.Zone, master.Zone)))
+	}
+
+	scores := make(map[vshard.InstanceUUID]float64, len(followers))
+	for i := range followers {
+		inst := &followers[i]
+
+		var score float64
+		// Replica with LSN in front of master LSN likely has broken replication.
+		if inst.LSNBehindMaster >= 0 {
+			score += w.LSNLag * (1 - lsn.normalize(float64(inst.LSNBehindMaster)))
+		}
+		score += w.Idle * (1 - idle.normalize(inst.Idle()))
+		score += w.Priority * priority.normalize(float64(inst.Priority))
+		score += w.Zone * (1 - zone.normalize(float64(e.opts.ZonePolicy.rank(inst.Zone, master.Zone))))
+		if inst.Upstream != nil && inst.Upstream.Status == vshard.UpstreamFollow {
+			score += w.UpstreamStatus
+		}
+		if inst.VShardFingerprint == master.VShardFingerprint {
+			score += w.Fingerprint
+		}
+		if d.colocated[inst.UUID] {
+			score -= w.MasterColocation
+		}
+
+		scores[inst.UUID] = score
+	}
+
+	return scores
+}
+
+// valueRange tracks min and max values of a factor.
+type valueRange struct {
+	min, max float64
+	empty    bool
+}
+
+func newRange() *valueRange {
+	return &valueRange{empty: true}
+}
+
+func (r *valueRange) add(v float64) {
+	if r.empty || v < r.min {
+		r.min = v
+	}
+	if r.empty || v > r.max {
+		r.max = v
+	}
+	r.empty = false
+}
+
+// normalize maps the value to [0, 1].
+// If all values are equal, returns 0.
+func (r *valueRange) normalize(v float64) float64 {
+	if r.max == r.min {
+		return 0
+	}
+
+	return (v - r.min) / (r.max - r.min)
+}
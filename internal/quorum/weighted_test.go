@@ -0,0 +1,121 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+func TestWeightedElector(t *testing.T) {
+	master := mockDeadMaster("1")
+	master.URI = "host1:3301"
+	master.Zone = "dc1"
+
+	lagging := mockFollower("2", 0, 50, 0.1)
+	lagging.URI = "host2:3301"
+
+	fresh := mockFollower("3", 0, 0, 0.1)
+	fresh.URI = "host3:3301"
+
+	prioritized := mockFollower("4", 10, 20, 0.1)
+	prioritized.URI = "host4:3301"
+	prioritized.Zone = "dc1"
+
+	set := vshard.ReplicaSet{
+		UUID:       "set1",
+		MasterUUID: "1",
+		Instances:  []vshard.Instance{master, lagging, fresh, prioritized},
+	}
+
+	another := vshard.ReplicaSet{
+		UUID:       "set2",
+		MasterUUID: "5",
+		Instances: []vshard.Instance{
+			{
+				UUID: "5",
+				URI:  "host3:3302",
+				StorageInfo: vshard.StorageInfo{
+					Replication: vshard.Replication{
+						Status: vshard.StatusMaster,
+					},
+				},
+			},
+		},
+	}
+
+	var testData = []struct {
+		name         string
+		weights      Weights
+		expectedUUID vshard.InstanceUUID
+	}{
+		{
+			name:         "LSNLag",
+			weights:      Weights{LSNLag: 1},
+			expectedUUID: "3",
+		},
+		{
+			name:         "Priority",
+			weights:      Weights{LSNLag: 1, Priority: 2},
+			expectedUUID: "4",
+		},
+		{
+			name:         "Zone",
+			weights:      Weights{LSNLag: 1, Zone: 2},
+			expectedUUID: "4",
+		},
+		{
+			name:         "MasterColocation",
+			weights:      Weights{LSNLag: 1, MasterColocation: 2},
+			expectedUUID: "4",
+		},
+	}
+
+	for _, v := range testData {
+		vt := v
+		t.Run(v.name, func(t *testing.T) {
+			e := NewWeightedElector(Options{
+				Weights:    vt.weights,
+				ZonePolicy: ZonePolicy{PreferMasterZone: true},
+				ReplicaSets: func() []vshard.ReplicaSet {
+					return []vshard.ReplicaSet{set, another}
+				},
+			})
+
+			decision, err := e.ChooseMaster(set)
+			require.Nil(t, err)
+			assert.Equal(t, vt.expectedUUID, decision.Winner)
+
+			winner, ok := decision.Candidate(vt.expectedUUID)
+			require.True(t, ok)
+			for _, c := range decision.Candidates {
+				assert.LessOrEqual(t, c.Score, winner.Score)
+			}
+		})
+	}
+}
+
+func TestWeightedElector_NoAliveFollowers(t *testing.T) {
+	e := NewWeightedElector(Options{})
+
+	decision, err := e.ChooseMaster(vshard.ReplicaSet{})
+	assert.Equal(t, ErrNoAliveFollowers, err)
+	assert.Empty(t, decision.Winner)
+}
+
+func Test_valueRange(t *testing.T) {
+	r := newRange()
+	r.add(10)
+	r.add(20)
+	r.add(15)
+
+	assert.Equal(t, 0.0, r.normalize(10))
+	assert.Equal(t, 0.5, r.normalize(15))
+	assert.Equal(t, 1.0, r.normalize(20))
+
+	r = newRange()
+	r.add(5)
+	assert.Equal(t, 0.0, r.normalize(5))
+}
@@ -0,0 +1,44 @@
+package quorum
+
+// ZonePolicy defines where a new master should be placed.
+type ZonePolicy struct {
+	// PreferMasterZone makes the elector prefer followers
+	// located in the same zone as the failed master.
+	PreferMasterZone bool
+	// PreferredZones contains zones in order of preference.
+	PreferredZones []string
+	// ForbiddenZones contains zones where a new master
+	// must never be placed.
+	ForbiddenZones []string
+}
+
+// rank returns the preference rank of the zone relative to
+// the zone of the failed master. The lower rank is the better.
+func (p ZonePolicy) rank(zone, masterZone string) int {
+	if p.PreferMasterZone && zone != "" && zone == masterZone {
+		return 0
+	}
+
+	for i, z := range p.PreferredZones {
+		if z == zone {
+			return i + 1
+		}
+	}
+
+	return len(p.PreferredZones) + 1
+}
+
+// forbidden reports whether the zone is not allowed to host a master.
+func (p ZonePolicy) forbidden(zone string) bool {
+	if zone == "" {
+		return false
+	}
+
+	for _, z := range p.ForbiddenZones {
+		if z == zone {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+func mockZonedFollower(uuid vshard.InstanceUUID, zone string, lsnBehindMaster int64, idle float64) vshard.Instance {
+	inst := mockFollower(uuid, 0, lsnBehindMaster, idle)
+	inst.Zone = zone
+	return inst
+}
+
+func TestZonePolicy_rank(t *testing.T) {
+	p := ZonePolicy{
+		PreferMasterZone: true,
+		PreferredZones:   []string{"dc2", "dc3"},
+	}
+
+	assert.Equal(t, 0, p.rank("dc1", "dc1"))
+	assert.Equal(t, 1, p.rank("dc2", "dc1"))
+	assert.Equal(t, 2, p.rank("dc3", "dc1"))
+	assert.Equal(t, 3, p.rank("dc4", "dc1"))
+	assert.Equal(t, 3, p.rank("", ""))
+
+	p.PreferMasterZone = false
+	assert.Equal(t, 3, p.rank("dc1", "dc1"))
+}
+
+func TestZoneAwareElection(t *testing.T) {
+	master := mockDeadMaster("1")
+	master.Zone = "dc1"
+
+	set := vshard.ReplicaSet{
+		MasterUUID: "1",
+		Instances: []vshard.Instance{
+			master,
+			mockZonedFollower("2", "dc2", 0, 0.01),
+			mockZonedFollower("3", "dc1", 10, 0.2),
+			mockZonedFollower("4", "dc3", 0, 0.02),
+			mockZonedFollower("5", "dc1", 5, 0.1),
+		},
+	}
+
+	var testData = []struct {
+		name         string
+		policy       ZonePolicy
+		expectedUUID vshard.InstanceUUID
+		expectedErr  error
+	}{
+		{
+			name:         "NoPolicy",
+			policy:       ZonePolicy{},
+			expectedUUID: "2",
+		},
+		{
+			name: "PreferMasterZone",
+			policy: ZonePolicy{
+				PreferMasterZone: true,
+			},
+			expectedUUID: "5",
+		},
+		{
+			name: "PreferredZones",
+			policy: ZonePolicy{
+				PreferredZones: []string{"dc3", "dc2"},
+			},
+			expectedUUID: "4",
+		},
+		{
+			name: "ForbiddenZones",
+			policy: ZonePolicy{
+				ForbiddenZones: []string{"dc2", "dc3"},
+			},
+			expectedUUID: "5",
+		},
+		{
+			name: "AllZonesForbidden_ShouldReturnErr",
+			policy: ZonePolicy{
+				ForbiddenZones: []string{"dc1", "dc2", "dc3"},
+			},
+			expectedErr: ErrNoAliveFollowers,
+		},
+	}
+
+	for _, v := range testData {
+		vt := v
+		t.Run(v.name, func(t *testing.T) {
+			for _, e := range []Elector{NewSmartElector(Options{ZonePolicy: vt.policy}), NewIdleElector(Options{ZonePolicy: vt.policy})} {
+				decision, err := e.ChooseMaster(set)
+				assert.Equal(t, vt.expectedErr, err, e.Mode())
+				assert.Equal(t, vt.expectedUUID, decision.Winner, e.Mode())
+			}
+		})
+	}
+}
@@ -6,3 +6,31 @@ type ClusterSnapshotResp struct {
 	Name     string
 	Snapshot vshard.Snapshot
 }
+
+// ReadOnlyOverride is a runtime change of the cluster readonly mode.
+type ReadOnlyOverride struct {
+	ClusterName string `json:"cluster_name"`
+	ReadOnly    bool   `json:"readonly"`
+	// Author is who changed the readonly mode.
+	Author string `json:"author"`
+	// Reason describes why the readonly mode was changed.
+	Reason    string `json:"reason"`
+	CreatedAt int64  `json:"created_at"`
+	// RevertAt is a time when the override expires and the readonly mode
+	// from the configuration is restored. Zero value means the override never expires.
+	RevertAt int64 `json:"revert_at"`
+	// Reverted indicates whether the entry restores the readonly mode from the configuration.
+	Reverted bool `json:"reverted"`
+}
+
+// HostDrain is a runtime change of the host drain mode.
+type HostDrain struct {
+	ClusterName string `json:"cluster_name"`
+	Host        string `json:"host"`
+	Drained     bool   `json:"drained"`
+	// Author is who drained or undrained the host.
+	Author string `json:"author"`
+	// Reason describes why the host was drained or undrained.
+	Reason    string `json:"reason"`
+	CreatedAt int64  `json:"created_at"`
+}
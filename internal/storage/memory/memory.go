@@ -0,0 +1,352 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/shmel1k/qumomf/internal/storage"
+	"github.com/shmel1k/qumomf/internal/vshard"
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
+)
+
+// record is an encoded document, so the stored data
+// is not affected by the changes of the caller's values.
+type record struct {
+	createdAt int64
+	data      []byte
+}
+
+type hookJob struct {
+	clusterName string
+	status      orchestrator.HookJobStatus
+	data        []byte
+}
+
+type memory struct {
+	config Config
+
+	// clusters keeps the order of the clusters as they were saved first.
+	clusters   []string
+	snapshots  map[string]record
+	history    map[string][]record
+	recoveries map[string][]record
+	overrides  map[string][]record
+	drains     map[string][]record
+	// hookJobs are ordered by ID starting from 1.
+	hookJobs []hookJob
+
+	mu sync.RWMutex
+}
+
+type Config struct {
+	// SnapshotRetention is a period the historical snapshots are kept.
+	// Zero value means the snapshots are never removed.
+	SnapshotRetention time.Duration
+}
+
+// New returns the storage keeping the data in memory.
+// The data is lost on restart.
+func New(cfg Config) storage.Storage {
+	return &memory{
+		config:     cfg,
+		snapshots:  make(map[string]record),
+		history:    make(map[string][]record),
+		recoveries: make(map[string][]record),
+		overrides:  make(map[string][]record),
+		drains:     make(map[string][]record),
+	}
+}
+
+func (s *memory) GetClusters(_ context.Context) ([]storage.ClusterSnapshotResp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resp := make([]storage.ClusterSnapshotResp, 0, len(s.clusters))
+	for _, name := range s.clusters {
+		snapResp := storage.ClusterSnapshotResp{Name: name}
+		err := json.Unmarshal(s.snapshots[name].data, &snapResp.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		resp = append(resp, snapResp)
+	}
+
+	return resp, nil
+}
+
+func (s *memory) SaveSnapshot(_ context.Context, clusterName string, snapshot vshard.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.snapshots[clusterName]; !ok {
+		s.clusters = append(s.clusters, clusterName)
+	}
+	rec := record{createdAt: snapshot.Created, data: data}
+	s.snapshots[clusterName] = rec
+
+	history := s.history[clusterName]
+	var last []byte
+	if len(history) > 0 {
+		last = history[len(history)-1].data
+	}
+	appendHistory, err := storage.AppendToHistory(last, &snapshot)
+	if err != nil {
+		return err
+	}
+	if appendHistory {
+		history = append(history, rec)
+	}
+
+	if s.config.SnapshotRetention > 0 {
+		before := snapshot.Created - int64(s.config.SnapshotRetention.Seconds())
+		history = cleanupHistory(history, before)
+	}
+	s.history[clusterName] = history
+
+	return nil
+}
+
+// cleanupHistory removes the snapshots created before the given time
+// except the last one, so the state of the cluster is known at any moment of the retention period.
+func cleanupHistory(history []record, before int64) []record {
+	last := -1
+	for i := range history {
+		if history[i].createdAt < before {
+			last = i
+		}
+	}
+	if last <= 0 {
+		return history
+	}
+
+	kept := make([]record, 0, len(history))
+	for i := range history {
+		if history[i].createdAt >= before || i == last {
+			kept = append(kept, history[i])
+		}
+	}
+
+	return kept
+}
+
+func (s *memory) SaveRecovery(_ context.Context, recovery orchestrator.Recovery) error {
+	return s.saveRecord(s.recoveries, recovery.ClusterName, recovery.EndTimestamp, recovery)
+}
+
+func (s *memory) GetClusterSnapshot(_ context.Context, clusterName string) (vshard.Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ns vshard.Snapshot
+	rec, ok := s.snapshots[clusterName]
+	if !ok {
+		return ns, storage.ErrEmptyResult
+	}
+	err := json.Unmarshal(rec.data, &ns)
+
+	return ns, err
+}
+
+func (s *memory) GetSnapshotTimestamps(_ context.Context, clusterName string, from, to int64) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resp := make([]int64, 0)
+	for _, rec := range s.history[clusterName] {
+		if rec.createdAt >= from && (to == 0 || rec.createdAt <= to) {
+			resp = append(resp, rec.createdAt)
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *memory) GetSnapshotAt(_ context.Context, clusterName string, at int64) (vshard.Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ns vshard.Snapshot
+	history := s.history[clusterName]
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].createdAt <= at {
+			err := json.Unmarshal(history[i].data, &ns)
+			return ns, err
+		}
+	}
+
+	return ns, storage.ErrEmptyResult
+}
+
+func (s *memory) GetRecoveries(_ context.Context, clusterName string) ([]orchestrator.Recovery, error) {
+	resp := make([]orchestrator.Recovery, 0)
+	err := s.getRecords(s.recoveries, clusterName, func(data []byte) error {
+		var recovery orchestrator.Recovery
+		err := json.Unmarshal(data, &recovery)
+		resp = append(resp, recovery)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *memory) SaveReadOnlyOverride(_ context.Context, override storage.ReadOnlyOverride) error {
+	return s.saveRecord(s.overrides, override.ClusterName, override.CreatedAt, override)
+}
+
+func (s *memory) GetReadOnlyOverrides(_ context.Context, clusterName string) ([]storage.ReadOnlyOverride, error) {
+	resp := make([]storage.ReadOnlyOverride, 0)
+	err := s.getRecords(s.overrides, clusterName, func(data []byte) error {
+		var override storage.ReadOnlyOverride
+		err := json.Unmarshal(data, &override)
+		resp = append(resp, override)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *memory) SaveHostDrain(_ context.Context, drain storage.HostDrain) error {
+	return s.saveRecord(s.drains, drain.ClusterName, drain.CreatedAt, drain)
+}
+
+func (s *memory) GetHostDrains(_ context.Context, clusterName string) ([]storage.HostDrain, error) {
+	resp := make([]storage.HostDrain, 0)
+	err := s.getRecords(s.drains, clusterName, func(data []byte) error {
+		var drain storage.HostDrain
+		err := json.Unmarshal(data, &drain)
+		resp = append(resp, drain)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *memory) saveRecord(records map[string][]record, clusterName string, createdAt int64, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	records[clusterName] = append(records[clusterName], record{createdAt: createdAt, data: data})
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *memory) getRecords(records map[string][]record, clusterName string, add func(data []byte) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rec := range records[clusterName] {
+		err := add(rec.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *memory) SaveHookJob(_ context.Context, job orchestrator.HookJob) (int64, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.ID == 0 {
+		s.hookJobs = append(s.hookJobs, hookJob{
+			clusterName: job.ClusterName,
+			status:      job.Status,
+			data:        data,
+		})
+		return int64(len(s.hookJobs)), nil
+	}
+
+	if job.ID < 0 || job.ID > int64(len(s.hookJobs)) {
+		return 0, orchestrator.ErrHookJobNotFound
+	}
+	stored := &s.hookJobs[job.ID-1]
+	stored.status = job.Status
+	stored.data = data
+
+	return job.ID, nil
+}
+
+func (s *memory) GetHookJob(_ context.Context, id int64) (orchestrator.HookJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var job orchestrator.HookJob
+	if id <= 0 || id > int64(len(s.hookJobs)) {
+		return job, orchestrator.ErrHookJobNotFound
+	}
+	err := json.Unmarshal(s.hookJobs[id-1].data, &job)
+	job.ID = id
+
+	return job, err
+}
+
+func (s *memory) GetHookJobs(_ context.Context, clusterName string, status orchestrator.HookJobStatus) ([]orchestrator.HookJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resp := make([]orchestrator.HookJob, 0)
+	for i := range s.hookJobs {
+		stored := &s.hookJobs[i]
+		if stored.clusterName != clusterName || (status != "" && stored.status != status) {
+			continue
+		}
+
+		var job orchestrator.HookJob
+		err := json.Unmarshal(stored.data, &job)
+		if err != nil {
+			return nil, err
+		}
+		job.ID = int64(i + 1)
+
+		resp = append(resp, job)
+	}
+
+	return resp, nil
+}
+
+func (s *memory) ClaimHookJobs(_ context.Context, clusterName string, now int64) ([]orchestrator.HookJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]storage.RawHookJob, 0)
+	for i := range s.hookJobs {
+		stored := &s.hookJobs[i]
+		if stored.clusterName == clusterName {
+			jobs = append(jobs, storage.RawHookJob{ID: int64(i + 1), Data: stored.data})
+		}
+	}
+
+	return storage.ClaimHookJobs(jobs, now, func(id int64, status orchestrator.HookJobStatus, old, new []byte) (bool, error) {
+		stored := &s.hookJobs[id-1]
+		if !bytes.Equal(stored.data, old) {
+			return false, nil
+		}
+		stored.status = status
+		stored.data = new
+
+		return true, nil
+	})
+}
+
+func (s *memory) Close() error {
+	return nil
+}
@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shmel1k/qumomf/internal/storage"
+	"github.com/shmel1k/qumomf/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(_ *testing.T, retention time.Duration) (storage.Storage, func()) {
+		return New(Config{SnapshotRetention: retention}), func() {}
+	}, func(_ *testing.T, db storage.Storage, id int64, data []byte) {
+		s := db.(*memory)
+		s.mu.Lock()
+		s.hookJobs[id-1].data = data
+		s.mu.Unlock()
+	})
+}
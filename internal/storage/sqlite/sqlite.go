@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"errors"
 	"time"
 
 	"github.com/shmel1k/qumomf/internal/storage"
@@ -21,33 +20,105 @@ const (
 							ON CONFLICT(cluster_name) DO UPDATE SET
   								created_at = excluded.created_at,
   								data = excluded.data`
+	queryGetLastHistorySnapshot = `SELECT data
+		FROM snapshot_history
+		WHERE cluster_name = ?
+		ORDER BY id DESC limit 1`
+	queryInsertHistorySnapshot = `INSERT INTO snapshot_history(cluster_name, created_at, data)
+							VALUES(?, ?, ?)`
+	// queryCleanupSnapshotHistory keeps the last snapshot created before the retention period,
+	// so the state of the cluster is known at any moment of the period.
+	queryCleanupSnapshotHistory = `DELETE FROM snapshot_history
+		WHERE cluster_name = ? AND created_at < ? AND id < (
+			SELECT MAX(id) FROM snapshot_history WHERE cluster_name = ? AND created_at < ?
+		)`
 	querySaveRecoveries = `INSERT INTO recoveries(cluster_name, created_at, data) 
 							VALUES(?, ?, ?)`
+	querySaveReadOnlyOverride = `INSERT INTO readonly_overrides(cluster_name, created_at, data)
+							VALUES(?, ?, ?)`
+	querySaveHostDrain = `INSERT INTO host_drains(cluster_name, created_at, data)
+							VALUES(?, ?, ?)`
+	queryInsertHookJob = `INSERT INTO hook_jobs(cluster_name, status, created_at, data)
+							VALUES(?, ?, ?, ?)`
+	queryUpdateHookJob = `UPDATE hook_jobs SET status = ?, data = ?
+							WHERE id = ?`
+	querySwapHookJob = `UPDATE hook_jobs SET status = ?, data = ?
+							WHERE id = ? AND data = ?`
 	initDatabaseQueries = `CREATE TABLE IF NOT EXISTS snapshots (
 		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,		
 		"cluster_name" TEXT UNIQUE,
 		"created_at" INTEGER,
 		"data" BLOB
 	  );
+	CREATE TABLE IF NOT EXISTS snapshot_history (
+		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"cluster_name" TEXT,
+		"created_at" INTEGER,
+		"data" BLOB
+	  );
+	CREATE INDEX IF NOT EXISTS snapshot_history_cluster_created ON snapshot_history(cluster_name, created_at);
 	CREATE TABLE IF NOT EXISTS recoveries (
 		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,		
 		"cluster_name" TEXT,
 		"created_at" INTEGER,
 		"data" BLOB
-	  )`
+	  );
+	CREATE TABLE IF NOT EXISTS readonly_overrides (
+		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"cluster_name" TEXT,
+		"created_at" INTEGER,
+		"data" BLOB
+	  );
+	CREATE TABLE IF NOT EXISTS host_drains (
+		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"cluster_name" TEXT,
+		"created_at" INTEGER,
+		"data" BLOB
+	  );
+	CREATE TABLE IF NOT EXISTS hook_jobs (
+		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"cluster_name" TEXT,
+		"status" TEXT,
+		"created_at" INTEGER,
+		"data" BLOB
+	  );
+	CREATE INDEX IF NOT EXISTS hook_jobs_cluster_status ON hook_jobs(cluster_name, status)`
 	queryGetLastSnapshot = `SELECT data
 		FROM snapshots
 		WHERE cluster_name = ?
 		ORDER BY id DESC limit 1`
+	queryGetSnapshotTimestamps = `SELECT created_at
+		FROM snapshot_history
+		WHERE cluster_name = ? AND created_at >= ? AND (? = 0 OR created_at <= ?)
+		ORDER BY id`
+	queryGetSnapshotAt = `SELECT data
+		FROM snapshot_history
+		WHERE cluster_name = ? AND created_at <= ?
+		ORDER BY id DESC limit 1`
 	queryGetRecoveries = `SELECT data
 		FROM recoveries
 		WHERE cluster_name = ?`
 	queryGetClusters = `SELECT cluster_name, data
 		FROM snapshots`
-)
-
-var (
-	ErrEmptyResult = errors.New("empty result")
+	queryGetReadOnlyOverrides = `SELECT data
+		FROM readonly_overrides
+		WHERE cluster_name = ?
+		ORDER BY id`
+	queryGetHostDrains = `SELECT data
+		FROM host_drains
+		WHERE cluster_name = ?
+		ORDER BY id`
+	queryGetHookJob = `SELECT id, data
+		FROM hook_jobs
+		WHERE id = ?`
+	queryGetHookJobs = `SELECT id, data
+		FROM hook_jobs
+		WHERE cluster_name = ? AND (? = '' OR status = ?)
+		ORDER BY id`
+	queryGetClaimableHookJobs = `SELECT id, data
+		FROM hook_jobs
+		WHERE cluster_name = ? AND status IN (?, ?)
+		ORDER BY id`
 )
 
 type sqlite struct {
@@ -59,6 +130,9 @@ type Config struct {
 	FileName       string
 	ConnectTimeout time.Duration
 	QueryTimeout   time.Duration
+	// SnapshotRetention is a period the historical snapshots are kept.
+	// Zero value means the snapshots are never removed.
+	SnapshotRetention time.Duration
 }
 
 func New(cfg Config) (storage.Storage, error) {
@@ -121,7 +195,50 @@ func (s *sqlite) SaveSnapshot(ctx context.Context, clusterName string, snapshot
 		return err
 	}
 
-	_, err = s.db.ExecContext(ctx, querySaveSnapshot, clusterName, snapshot.Created, data)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, querySaveSnapshot, clusterName, snapshot.Created, data)
+	if err != nil {
+		return err
+	}
+
+	err = saveHistorySnapshot(ctx, tx, clusterName, snapshot, data)
+	if err != nil {
+		return err
+	}
+
+	if s.config.SnapshotRetention > 0 {
+		before := snapshot.Created - int64(s.config.SnapshotRetention.Seconds())
+		_, err = tx.ExecContext(ctx, queryCleanupSnapshotHistory, clusterName, before, clusterName, before)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// saveHistorySnapshot adds the snapshot to the history
+// if it differs from the last saved one.
+func saveHistorySnapshot(ctx context.Context, tx *sql.Tx, clusterName string, snapshot vshard.Snapshot, data []byte) error {
+	var last []byte
+	err := tx.QueryRowContext(ctx, queryGetLastHistorySnapshot, clusterName).Scan(&last)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	appendHistory, err := storage.AppendToHistory(last, &snapshot)
+	if err != nil || !appendHistory {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryInsertHistorySnapshot, clusterName, snapshot.Created, data)
 
 	return err
 }
@@ -150,7 +267,51 @@ func (s *sqlite) GetClusterSnapshot(ctx context.Context, clusterName string) (vs
 	var ns vshard.Snapshot
 	err := row.Scan(&data)
 	if err == sql.ErrNoRows {
-		return ns, ErrEmptyResult
+		return ns, storage.ErrEmptyResult
+	}
+	err = json.Unmarshal(data, &ns)
+
+	return ns, err
+}
+
+func (s *sqlite) GetSnapshotTimestamps(ctx context.Context, clusterName string, from, to int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	var createdAt int64
+	resp := make([]int64, 0)
+	rows, err := s.db.QueryContext(ctx, queryGetSnapshotTimestamps, clusterName, from, to, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp = append(resp, createdAt)
+	}
+
+	return resp, err
+}
+
+func (s *sqlite) GetSnapshotAt(ctx context.Context, clusterName string, at int64) (vshard.Snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	data := make([]byte, 0)
+	row := s.db.QueryRowContext(ctx, queryGetSnapshotAt, clusterName, at)
+
+	var ns vshard.Snapshot
+	err := row.Scan(&data)
+	if err == sql.ErrNoRows {
+		return ns, storage.ErrEmptyResult
+	}
+	if err != nil {
+		return ns, err
 	}
 	err = json.Unmarshal(data, &ns)
 
@@ -187,6 +348,219 @@ func (s *sqlite) GetRecoveries(ctx context.Context, clusterName string) ([]orche
 	return resp, err
 }
 
+func (s *sqlite) SaveReadOnlyOverride(ctx context.Context, override storage.ReadOnlyOverride) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(override)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, querySaveReadOnlyOverride, override.ClusterName, override.CreatedAt, data)
+
+	return err
+}
+
+func (s *sqlite) GetReadOnlyOverrides(ctx context.Context, clusterName string) ([]storage.ReadOnlyOverride, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	data := make([]byte, 0)
+	resp := make([]storage.ReadOnlyOverride, 0)
+	rows, err := s.db.QueryContext(ctx, queryGetReadOnlyOverrides, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, err
+		}
+
+		var override storage.ReadOnlyOverride
+		err = json.Unmarshal(data, &override)
+		if err != nil {
+			return nil, err
+		}
+
+		resp = append(resp, override)
+	}
+
+	return resp, err
+}
+
+func (s *sqlite) SaveHostDrain(ctx context.Context, drain storage.HostDrain) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(drain)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, querySaveHostDrain, drain.ClusterName, drain.CreatedAt, data)
+
+	return err
+}
+
+func (s *sqlite) GetHostDrains(ctx context.Context, clusterName string) ([]storage.HostDrain, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	data := make([]byte, 0)
+	resp := make([]storage.HostDrain, 0)
+	rows, err := s.db.QueryContext(ctx, queryGetHostDrains, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, err
+		}
+
+		var drain storage.HostDrain
+		err = json.Unmarshal(data, &drain)
+		if err != nil {
+			return nil, err
+		}
+
+		resp = append(resp, drain)
+	}
+
+	return resp, err
+}
+
+func (s *sqlite) SaveHookJob(ctx context.Context, job orchestrator.HookJob) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return 0, err
+	}
+
+	if job.ID == 0 {
+		res, err := s.db.ExecContext(ctx, queryInsertHookJob, job.ClusterName, job.Status, job.CreatedAt, data)
+		if err != nil {
+			return 0, err
+		}
+		return res.LastInsertId()
+	}
+
+	res, err := s.db.ExecContext(ctx, queryUpdateHookJob, job.Status, data, job.ID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, orchestrator.ErrHookJobNotFound
+	}
+
+	return job.ID, nil
+}
+
+func (s *sqlite) GetHookJob(ctx context.Context, id int64) (orchestrator.HookJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	data := make([]byte, 0)
+	row := s.db.QueryRowContext(ctx, queryGetHookJob, id)
+
+	var job orchestrator.HookJob
+	err := row.Scan(&id, &data)
+	if err == sql.ErrNoRows {
+		return job, orchestrator.ErrHookJobNotFound
+	}
+	if err != nil {
+		return job, err
+	}
+	err = json.Unmarshal(data, &job)
+	job.ID = id
+
+	return job, err
+}
+
+func (s *sqlite) GetHookJobs(ctx context.Context, clusterName string, status orchestrator.HookJobStatus) ([]orchestrator.HookJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	var id int64
+	data := make([]byte, 0)
+	resp := make([]orchestrator.HookJob, 0)
+	rows, err := s.db.QueryContext(ctx, queryGetHookJobs, clusterName, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&id, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		var job orchestrator.HookJob
+		err = json.Unmarshal(data, &job)
+		if err != nil {
+			return nil, err
+		}
+		job.ID = id
+
+		resp = append(resp, job)
+	}
+
+	return resp, err
+}
+
+func (s *sqlite) ClaimHookJobs(ctx context.Context, clusterName string, now int64) ([]orchestrator.HookJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, queryGetClaimableHookJobs, clusterName, orchestrator.HookJobPending, orchestrator.HookJobRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]storage.RawHookJob, 0)
+	for rows.Next() {
+		var job storage.RawHookJob
+		err = rows.Scan(&job.ID, &job.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	return storage.ClaimHookJobs(jobs, now, func(id int64, status orchestrator.HookJobStatus, old, new []byte) (bool, error) {
+		res, err := s.db.ExecContext(ctx, querySwapHookJob, status, new, id, old)
+		if err != nil {
+			return false, err
+		}
+		n, err := res.RowsAffected()
+
+		return n > 0, err
+	})
+}
+
+func (s *sqlite) Close() error {
+	return s.db.Close()
+}
+
 func createTables(ctx context.Context, db *sql.DB) error {
 	_, err := db.ExecContext(ctx, initDatabaseQueries)
 
@@ -2,15 +2,98 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 
 	"github.com/shmel1k/qumomf/internal/vshard"
 	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
 )
 
+// ErrEmptyResult is returned by the storages if the requested data is not found.
+var ErrEmptyResult = errors.New("empty result")
+
 type Storage interface {
 	GetClusters(context.Context) ([]ClusterSnapshotResp, error)
 	SaveSnapshot(context.Context, string, vshard.Snapshot) error
 	SaveRecovery(context.Context, orchestrator.Recovery) error
 	GetClusterSnapshot(context.Context, string) (vshard.Snapshot, error)
+	// GetSnapshotTimestamps returns the creation times of the historical snapshots
+	// of the cluster in the given range. Zero upper bound means no limit.
+	GetSnapshotTimestamps(context.Context, string, int64, int64) ([]int64, error)
+	// GetSnapshotAt returns the last snapshot of the cluster created not later than the given time.
+	GetSnapshotAt(context.Context, string, int64) (vshard.Snapshot, error)
 	GetRecoveries(context.Context, string) ([]orchestrator.Recovery, error)
+	SaveReadOnlyOverride(context.Context, ReadOnlyOverride) error
+	GetReadOnlyOverrides(context.Context, string) ([]ReadOnlyOverride, error)
+	SaveHostDrain(context.Context, HostDrain) error
+	GetHostDrains(context.Context, string) ([]HostDrain, error)
+	SaveHookJob(context.Context, orchestrator.HookJob) (int64, error)
+	GetHookJob(context.Context, int64) (orchestrator.HookJob, error)
+	GetHookJobs(context.Context, string, orchestrator.HookJobStatus) ([]orchestrator.HookJob, error)
+	ClaimHookJobs(context.Context, string, int64) ([]orchestrator.HookJob, error)
+	// Close releases the connections of the storage.
+	Close() error
+}
+
+// AppendToHistory reports whether the snapshot is appended to the history of the cluster.
+// The history is appended only if the snapshot differs from the last snapshot in the history
+// either in topology or in any property compared by vshard.DiffSnapshots.
+// The last snapshot is passed as it is stored, nil means the history is empty.
+func AppendToHistory(last []byte, snapshot *vshard.Snapshot) (bool, error) {
+	if last == nil {
+		return true, nil
+	}
+
+	var prev vshard.Snapshot
+	err := json.Unmarshal(last, &prev)
+	if err != nil {
+		return false, err
+	}
+
+	return !prev.SameStateAs(snapshot), nil
+}
+
+// RawHookJob is the hook job document as it is kept in the storage.
+type RawHookJob struct {
+	ID   int64
+	Data []byte
+}
+
+// SwapHookJobFunc replaces the stored document of the job with the new one
+// only if the stored document has not been changed since it was read.
+// It reports whether the document is replaced.
+type SwapHookJobFunc func(id int64, status orchestrator.HookJobStatus, old, new []byte) (bool, error)
+
+// ClaimHookJobs claims the jobs ready to be run at the given time.
+// The jobs are claimed by swapping their documents, so the job read
+// by several qumomf nodes at the same time is claimed only by one of them.
+// On error the jobs claimed so far are returned as well.
+func ClaimHookJobs(jobs []RawHookJob, now int64, swap SwapHookJobFunc) ([]orchestrator.HookJob, error) {
+	claimed := make([]orchestrator.HookJob, 0)
+	for _, raw := range jobs {
+		var job orchestrator.HookJob
+		err := json.Unmarshal(raw.Data, &job)
+		if err != nil {
+			return claimed, err
+		}
+		job.ID = raw.ID
+		if !job.Claimable(now) {
+			continue
+		}
+
+		job.Claim(now)
+		data, err := json.Marshal(job)
+		if err != nil {
+			return claimed, err
+		}
+		ok, err := swap(job.ID, job.Status, raw.Data, data)
+		if err != nil {
+			return claimed, err
+		}
+		if ok {
+			claimed = append(claimed, job)
+		}
+	}
+
+	return claimed, nil
 }
@@ -0,0 +1,421 @@
+// Package storagetest contains the conformance tests of the storage backends.
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/shmel1k/qumomf/internal/storage"
+	"github.com/shmel1k/qumomf/internal/vshard"
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
+)
+
+// snapshotRetention is a period the historical snapshots are kept by the tested storages.
+const snapshotRetention = 100 * time.Second
+
+var (
+	tClusterName = "testCluster"
+	tSnapshot    = vshard.Snapshot{
+		Created:     123,
+		Routers:     []vshard.Router{},
+		ReplicaSets: []vshard.ReplicaSet{},
+	}
+	tRecovery = orchestrator.Recovery{
+		Type:        "test type",
+		ClusterName: tClusterName,
+		Hooks: []orchestrator.HookExecution{
+			{
+				Type:     orchestrator.HookPreFailover,
+				Command:  "echo test",
+				Attempts: 1,
+				Stdout:   "test\n",
+			},
+		},
+	}
+	tReadOnlyOverride = storage.ReadOnlyOverride{
+		ClusterName: tClusterName,
+		ReadOnly:    true,
+		Author:      "admin",
+		Reason:      "maintenance",
+		CreatedAt:   123,
+		RevertAt:    456,
+	}
+)
+
+var (
+	dummyContext = context.Background()
+)
+
+// Factory returns an empty storage keeping the historical snapshots
+// for the given period and the function releasing the storage.
+type Factory func(t *testing.T, retention time.Duration) (storage.Storage, func())
+
+// CorruptHookJobFunc replaces the stored document of the hook job
+// with the given one bypassing the storage API.
+type CorruptHookJobFunc func(t *testing.T, db storage.Storage, id int64, data []byte)
+
+// Run runs the conformance tests against the storages returned by the factory.
+// Each test gets a new empty storage.
+func Run(t *testing.T, factory Factory, corrupt CorruptHookJobFunc) {
+	suite.Run(t, &storageSuite{
+		factory: factory,
+		corrupt: corrupt,
+	})
+}
+
+type storageSuite struct {
+	suite.Suite
+	factory Factory
+	corrupt CorruptHookJobFunc
+
+	db      storage.Storage
+	release func()
+}
+
+func (s *storageSuite) SetupTest() {
+	s.db, s.release = s.factory(s.T(), snapshotRetention)
+	require.NotNil(s.T(), s.db)
+}
+
+func (s *storageSuite) TearDownTest() {
+	s.release()
+}
+
+func (s *storageSuite) TestEmptyResult() {
+	t := s.T()
+	_, err := s.db.GetClusterSnapshot(dummyContext, tClusterName)
+	require.Equal(t, storage.ErrEmptyResult, err)
+
+	_, err = s.db.GetSnapshotAt(dummyContext, tClusterName, 123)
+	require.Equal(t, storage.ErrEmptyResult, err)
+
+	clusters, err := s.db.GetClusters(dummyContext)
+	require.NoError(t, err)
+	require.Empty(t, clusters)
+}
+
+func (s *storageSuite) TestSaveSnapshot() {
+	t := s.T()
+	err := s.db.SaveSnapshot(dummyContext, tClusterName, tSnapshot)
+	require.NoError(t, err)
+
+	snap, err := s.db.GetClusterSnapshot(dummyContext, tClusterName)
+	require.NoError(t, err)
+	require.Equal(t, tSnapshot, snap)
+
+	clusters, err := s.db.GetClusters(dummyContext)
+	require.NoError(t, err)
+	require.Equal(t, []storage.ClusterSnapshotResp{{Name: tClusterName, Snapshot: tSnapshot}}, clusters)
+}
+
+func (s *storageSuite) TestSaveRecovery() {
+	t := s.T()
+	err := s.db.SaveRecovery(dummyContext, tRecovery)
+	require.NoError(t, err)
+
+	results, err := s.db.GetRecoveries(dummyContext, tClusterName)
+	require.NoError(t, err)
+	require.Equal(t, []orchestrator.Recovery{tRecovery}, results)
+
+	results, err = s.db.GetRecoveries(dummyContext, "unknown")
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func (s *storageSuite) TestSaveSnapshot_ShouldNotDuplicateSnapshots() {
+	t := s.T()
+	var lastCreatedAt int64
+	for i := 0; i < 3; i++ {
+		lastCreatedAt = time.Now().Unix()
+		err := s.db.SaveSnapshot(dummyContext, tClusterName, vshard.Snapshot{
+			Created: lastCreatedAt,
+		})
+		require.NoError(t, err)
+	}
+
+	snap, err := s.db.GetClusterSnapshot(dummyContext, tClusterName)
+	require.NoError(t, err)
+	assert.Equal(t, snap.Created, lastCreatedAt)
+
+	clusters, err := s.db.GetClusters(dummyContext)
+	require.NoError(t, err)
+	assert.Len(t, clusters, 1)
+
+	// Snapshots with the same topology are not duplicated in the history.
+	timestamps, err := s.db.GetSnapshotTimestamps(dummyContext, tClusterName, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, timestamps, 1)
+}
+
+func (s *storageSuite) TestSnapshotHistory() {
+	t := s.T()
+
+	newSnapshot := func(created int64, master vshard.InstanceUUID) vshard.Snapshot {
+		return vshard.Snapshot{
+			Created: created,
+			Routers: []vshard.Router{},
+			ReplicaSets: []vshard.ReplicaSet{{
+				UUID:       "set_1",
+				MasterUUID: master,
+				Instances: []vshard.Instance{
+					{UUID: "instance_1"},
+					{UUID: "instance_2"},
+				},
+			}},
+		}
+	}
+
+	for _, snap := range []vshard.Snapshot{
+		newSnapshot(1000, "instance_1"),
+		newSnapshot(1010, "instance_1"),
+		newSnapshot(1020, "instance_2"),
+		newSnapshot(1030, "instance_1"),
+	} {
+		err := s.db.SaveSnapshot(dummyContext, tClusterName, snap)
+		require.NoError(t, err)
+	}
+
+	timestamps, err := s.db.GetSnapshotTimestamps(dummyContext, tClusterName, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1000, 1020, 1030}, timestamps)
+
+	timestamps, err = s.db.GetSnapshotTimestamps(dummyContext, tClusterName, 1010, 1025)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1020}, timestamps)
+
+	snap, err := s.db.GetSnapshotAt(dummyContext, tClusterName, 1015)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), snap.Created)
+	assert.Equal(t, vshard.InstanceUUID("instance_1"), snap.ReplicaSets[0].MasterUUID)
+
+	snap, err = s.db.GetSnapshotAt(dummyContext, tClusterName, 1025)
+	require.NoError(t, err)
+	assert.Equal(t, vshard.InstanceUUID("instance_2"), snap.ReplicaSets[0].MasterUUID)
+
+	_, err = s.db.GetSnapshotAt(dummyContext, tClusterName, 999)
+	assert.Equal(t, storage.ErrEmptyResult, err)
+
+	// The last snapshot before the retention period is kept.
+	err = s.db.SaveSnapshot(dummyContext, tClusterName, newSnapshot(1125, "instance_2"))
+	require.NoError(t, err)
+
+	timestamps, err = s.db.GetSnapshotTimestamps(dummyContext, tClusterName, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1020, 1030, 1125}, timestamps)
+
+	timestamps, err = s.db.GetSnapshotTimestamps(dummyContext, "unknown", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, timestamps)
+}
+
+func (s *storageSuite) TestSnapshotHistory_StateChanges() {
+	t := s.T()
+
+	newSnapshot := func(created int64, lag int64) vshard.Snapshot {
+		return vshard.Snapshot{
+			Created: created,
+			Routers: []vshard.Router{},
+			ReplicaSets: []vshard.ReplicaSet{{
+				UUID:       "set_1",
+				MasterUUID: "instance_1",
+				Instances: []vshard.Instance{
+					{UUID: "instance_1"},
+					{UUID: "instance_2", LSNBehindMaster: lag},
+				},
+			}},
+		}
+	}
+
+	// The snapshots with the same topology but different state are kept,
+	// so the diffs between the historical snapshots show the changes.
+	for _, snap := range []vshard.Snapshot{
+		newSnapshot(1000, 0),
+		newSnapshot(1010, 0),
+		newSnapshot(1020, 50),
+	} {
+		err := s.db.SaveSnapshot(dummyContext, tClusterName, snap)
+		require.NoError(t, err)
+	}
+
+	timestamps, err := s.db.GetSnapshotTimestamps(dummyContext, tClusterName, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1000, 1020}, timestamps)
+}
+
+func (s *storageSuite) TestSaveReadOnlyOverride() {
+	t := s.T()
+	reverted := storage.ReadOnlyOverride{
+		ClusterName: tClusterName,
+		ReadOnly:    false,
+		Author:      "qumomf",
+		CreatedAt:   456,
+		Reverted:    true,
+	}
+
+	for _, override := range []storage.ReadOnlyOverride{tReadOnlyOverride, reverted} {
+		err := s.db.SaveReadOnlyOverride(dummyContext, override)
+		require.NoError(t, err)
+	}
+
+	results, err := s.db.GetReadOnlyOverrides(dummyContext, tClusterName)
+	require.NoError(t, err)
+	require.Equal(t, []storage.ReadOnlyOverride{tReadOnlyOverride, reverted}, results)
+
+	results, err = s.db.GetReadOnlyOverrides(dummyContext, "unknown")
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func (s *storageSuite) TestSaveHostDrain() {
+	t := s.T()
+	drained := storage.HostDrain{
+		ClusterName: tClusterName,
+		Host:        "host-1",
+		Drained:     true,
+		Author:      "admin",
+		Reason:      "maintenance",
+		CreatedAt:   123,
+	}
+	undrained := storage.HostDrain{
+		ClusterName: tClusterName,
+		Host:        "host-1",
+		Author:      "admin",
+		CreatedAt:   456,
+	}
+
+	for _, drain := range []storage.HostDrain{drained, undrained} {
+		err := s.db.SaveHostDrain(dummyContext, drain)
+		require.NoError(t, err)
+	}
+
+	results, err := s.db.GetHostDrains(dummyContext, tClusterName)
+	require.NoError(t, err)
+	require.Equal(t, []storage.HostDrain{drained, undrained}, results)
+
+	results, err = s.db.GetHostDrains(dummyContext, "unknown")
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func (s *storageSuite) TestSaveHookJob() {
+	t := s.T()
+	job := orchestrator.HookJob{
+		ClusterName: tClusterName,
+		Type:        orchestrator.HookPostSuccessfulFailover,
+		Command:     "echo test",
+		Payload:     []byte(`{"event":"PostSuccessfulFailover"}`),
+		MaxAttempts: 3,
+		Status:      orchestrator.HookJobPending,
+		CreatedAt:   123,
+	}
+
+	id, err := s.db.SaveHookJob(dummyContext, job)
+	require.NoError(t, err)
+	require.NotZero(t, id)
+	job.ID = id
+
+	got, err := s.db.GetHookJob(dummyContext, id)
+	require.NoError(t, err)
+	require.Equal(t, job, got)
+
+	job.Status = orchestrator.HookJobDead
+	job.Attempts = 3
+	_, err = s.db.SaveHookJob(dummyContext, job)
+	require.NoError(t, err)
+
+	results, err := s.db.GetHookJobs(dummyContext, tClusterName, orchestrator.HookJobDead)
+	require.NoError(t, err)
+	require.Equal(t, []orchestrator.HookJob{job}, results)
+
+	results, err = s.db.GetHookJobs(dummyContext, tClusterName, "")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	results, err = s.db.GetHookJobs(dummyContext, tClusterName, orchestrator.HookJobPending)
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	_, err = s.db.GetHookJob(dummyContext, id+1)
+	require.Equal(t, orchestrator.ErrHookJobNotFound, err)
+
+	_, err = s.db.SaveHookJob(dummyContext, orchestrator.HookJob{ID: id + 1})
+	require.Equal(t, orchestrator.ErrHookJobNotFound, err)
+}
+
+func (s *storageSuite) TestClaimHookJobs() {
+	t := s.T()
+	job := orchestrator.HookJob{
+		ClusterName:   tClusterName,
+		Type:          orchestrator.HookPostSuccessfulFailover,
+		Command:       "echo test",
+		Payload:       []byte(`{"event":"PostSuccessfulFailover"}`),
+		Timeout:       time.Second,
+		MaxAttempts:   3,
+		Status:        orchestrator.HookJobPending,
+		NextAttemptAt: 1000,
+	}
+
+	id, err := s.db.SaveHookJob(dummyContext, job)
+	require.NoError(t, err)
+
+	claimed, err := s.db.ClaimHookJobs(dummyContext, tClusterName, 999)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	claimed, err = s.db.ClaimHookJobs(dummyContext, tClusterName, 1000)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, id, claimed[0].ID)
+	assert.Equal(t, orchestrator.HookJobRunning, claimed[0].Status)
+
+	got, err := s.db.GetHookJob(dummyContext, id)
+	require.NoError(t, err)
+	assert.Equal(t, claimed[0], got)
+
+	// The running job is claimed again only when its claim expires.
+	claimed, err = s.db.ClaimHookJobs(dummyContext, tClusterName, 1000)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	claimed, err = s.db.ClaimHookJobs(dummyContext, tClusterName, got.NextAttemptAt)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	claimed, err = s.db.ClaimHookJobs(dummyContext, "unknown", got.NextAttemptAt)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+}
+
+func (s *storageSuite) TestClaimHookJobs_Error() {
+	t := s.T()
+	job := orchestrator.HookJob{
+		ClusterName: tClusterName,
+		Type:        orchestrator.HookPostSuccessfulFailover,
+		Command:     "echo test",
+		Payload:     []byte(`{}`),
+		MaxAttempts: 1,
+		Status:      orchestrator.HookJobPending,
+	}
+
+	id, err := s.db.SaveHookJob(dummyContext, job)
+	require.NoError(t, err)
+	corruptedID, err := s.db.SaveHookJob(dummyContext, job)
+	require.NoError(t, err)
+	// The document is a valid JSON, so it is accepted by any storage, but not a valid job.
+	s.corrupt(t, s.db, corruptedID, []byte(`{"status":1}`))
+
+	// The jobs claimed before the error are returned and stay claimed.
+	claimed, err := s.db.ClaimHookJobs(dummyContext, tClusterName, 1000)
+	require.Error(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, id, claimed[0].ID)
+
+	got, err := s.db.GetHookJob(dummyContext, id)
+	require.NoError(t, err)
+	assert.Equal(t, orchestrator.HookJobRunning, got.Status)
+}
@@ -0,0 +1,593 @@
+package tarantool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	tnt "github.com/viciious/go-tarantool"
+
+	"github.com/shmel1k/qumomf/internal/storage"
+	"github.com/shmel1k/qumomf/internal/vshard"
+	"github.com/shmel1k/qumomf/internal/vshard/orchestrator"
+)
+
+// The history, recoveries, overrides, drains and hook jobs are kept in the spaces
+// with auto increment primary key, so the order of the records is preserved.
+const (
+	initSchemaLua = `
+		local function log_space(name)
+			local space = box.schema.space.create(name, {
+				if_not_exists = true,
+				format = {
+					{name = 'id', type = 'unsigned'},
+					{name = 'cluster_name', type = 'string'},
+					{name = 'created_at', type = 'integer'},
+					{name = 'data', type = 'string'},
+				},
+			})
+			space:create_index('primary', {if_not_exists = true, sequence = true, parts = {'id'}})
+			return space
+		end
+
+		local snapshots = box.schema.space.create('qumomf_snapshots', {
+			if_not_exists = true,
+			format = {
+				{name = 'cluster_name', type = 'string'},
+				{name = 'created_at', type = 'integer'},
+				{name = 'data', type = 'string'},
+			},
+		})
+		snapshots:create_index('primary', {if_not_exists = true, parts = {'cluster_name'}})
+
+		local history = log_space('qumomf_snapshot_history')
+		history:create_index('cluster_created', {if_not_exists = true, parts = {'cluster_name', 'created_at', 'id'}})
+
+		for _, name in ipairs({'qumomf_recoveries', 'qumomf_readonly_overrides', 'qumomf_host_drains'}) do
+			log_space(name):create_index('cluster', {if_not_exists = true, parts = {'cluster_name', 'id'}})
+		end
+
+		local jobs = box.schema.space.create('qumomf_hook_jobs', {
+			if_not_exists = true,
+			format = {
+				{name = 'id', type = 'unsigned'},
+				{name = 'cluster_name', type = 'string'},
+				{name = 'status', type = 'string'},
+				{name = 'created_at', type = 'integer'},
+				{name = 'data', type = 'string'},
+			},
+		})
+		jobs:create_index('primary', {if_not_exists = true, sequence = true, parts = {'id'}})
+		jobs:create_index('cluster', {if_not_exists = true, parts = {'cluster_name', 'id'}})
+		jobs:create_index('cluster_status', {if_not_exists = true, parts = {'cluster_name', 'status', 'id'}})
+	`
+	saveSnapshotLua = `
+		local cluster_name, created_at, data, append, cleanup, before = ...
+		box.atomic(function()
+			box.space.qumomf_snapshots:replace({cluster_name, created_at, data})
+			if append then
+				box.space.qumomf_snapshot_history:insert({box.NULL, cluster_name, created_at, data})
+			end
+			if not cleanup then
+				return
+			end
+
+			-- Keep the last snapshot created before the retention period,
+			-- so the state of the cluster is known at any moment of the period.
+			local expired = {}
+			local index = box.space.qumomf_snapshot_history.index.cluster_created
+			for _, t in index:pairs({cluster_name, before}, {iterator = 'LT'}) do
+				if t.cluster_name ~= cluster_name then
+					break
+				end
+				table.insert(expired, t.id)
+			end
+			for i = 2, #expired do
+				box.space.qumomf_snapshot_history:delete(expired[i])
+			end
+		end)
+	`
+	getLastHistorySnapshotLua = `
+		local cluster_name = ...
+		local index = box.space.qumomf_snapshot_history.index.cluster_created
+		for _, t in index:pairs({cluster_name}, {iterator = 'REQ'}) do
+			return t.data
+		end
+		return nil
+	`
+	getLastSnapshotLua = `
+		local cluster_name = ...
+		local t = box.space.qumomf_snapshots:get(cluster_name)
+		return t and t.data
+	`
+	getSnapshotTimestampsLua = `
+		local cluster_name, from, to = ...
+		local timestamps = {}
+		local index = box.space.qumomf_snapshot_history.index.cluster_created
+		for _, t in index:pairs({cluster_name, from}, {iterator = 'GE'}) do
+			if t.cluster_name ~= cluster_name or (to ~= 0 and t.created_at > to) then
+				break
+			end
+			table.insert(timestamps, t.created_at)
+		end
+		return timestamps
+	`
+	getSnapshotAtLua = `
+		local cluster_name, at = ...
+		local index = box.space.qumomf_snapshot_history.index.cluster_created
+		for _, t in index:pairs({cluster_name, at}, {iterator = 'LE'}) do
+			if t.cluster_name == cluster_name then
+				return t.data
+			end
+			break
+		end
+		return nil
+	`
+	getClustersLua = `
+		local clusters = {}
+		for _, t in box.space.qumomf_snapshots:pairs() do
+			table.insert(clusters, {t.cluster_name, t.data})
+		end
+		return clusters
+	`
+	// insertLogLua and getLogLua are formatted with the name of the space.
+	insertLogLua = `
+		local cluster_name, created_at, data = ...
+		box.space.%s:insert({box.NULL, cluster_name, created_at, data})
+	`
+	getLogLua = `
+		local cluster_name = ...
+		local records = {}
+		for _, t in box.space.%s.index.cluster:pairs({cluster_name}) do
+			table.insert(records, t.data)
+		end
+		return records
+	`
+	insertHookJobLua = `
+		local cluster_name, status, created_at, data = ...
+		local t = box.space.qumomf_hook_jobs:insert({box.NULL, cluster_name, status, created_at, data})
+		return t.id
+	`
+	updateHookJobLua = `
+		local id, status, data = ...
+		local t = box.space.qumomf_hook_jobs:update(id, {{'=', 3, status}, {'=', 5, data}})
+		return t ~= nil
+	`
+	getHookJobLua = `
+		local id = ...
+		local t = box.space.qumomf_hook_jobs:get(id)
+		return t and t.data
+	`
+	getHookJobsLua = `
+		local cluster_name, status = ...
+		local index, key = box.space.qumomf_hook_jobs.index.cluster, {cluster_name}
+		if status ~= '' then
+			index, key = box.space.qumomf_hook_jobs.index.cluster_status, {cluster_name, status}
+		end
+
+		local jobs = {}
+		for _, t in index:pairs(key) do
+			table.insert(jobs, {t.id, t.data})
+		end
+		return jobs
+	`
+	getClaimableHookJobsLua = `
+		local cluster_name, pending, running = ...
+		local index = box.space.qumomf_hook_jobs.index.cluster_status
+
+		local jobs = {}
+		for _, status in ipairs({pending, running}) do
+			for _, t in index:pairs({cluster_name, status}) do
+				table.insert(jobs, {t.id, t.data})
+			end
+		end
+		return jobs
+	`
+	swapHookJobLua = `
+		local id, status, old, new = ...
+		local t = box.space.qumomf_hook_jobs:get(id)
+		if t == nil or t.data ~= old then
+			return false
+		end
+		box.space.qumomf_hook_jobs:update(id, {{'=', 3, status}, {'=', 5, new}})
+		return true
+	`
+)
+
+const (
+	spaceRecoveries        = "qumomf_recoveries"
+	spaceReadOnlyOverrides = "qumomf_readonly_overrides"
+	spaceHostDrains        = "qumomf_host_drains"
+)
+
+var (
+	ErrUnexpectedResponse = errors.New("unexpected response")
+)
+
+type tarantool struct {
+	pool   vshard.ConnPool
+	conn   *vshard.Connector
+	config Config
+}
+
+type Config struct {
+	// Addr is an address of the dedicated Tarantool instance, e.g. localhost:3301.
+	// The spaces are created and accessed by evaluating Lua code, so the user requires
+	// the privileges granted by box.schema.user.grant(user, 'read,write,execute,create', 'universe').
+	Addr              string
+	User              string
+	Password          string
+	ConnectTimeout    time.Duration
+	QueryTimeout      time.Duration
+	SnapshotRetention time.Duration
+}
+
+// New connects to Tarantool and creates the spaces if they do not exist.
+func New(cfg Config) (storage.Storage, error) {
+	pool := vshard.NewConnPool(vshard.ConnOptions{
+		User:           cfg.User,
+		Password:       cfg.Password,
+		ConnectTimeout: cfg.ConnectTimeout,
+		QueryTimeout:   cfg.QueryTimeout,
+	}, nil)
+
+	s := &tarantool{
+		pool:   pool,
+		conn:   pool.Get(cfg.Addr),
+		config: cfg,
+	}
+
+	_, err := s.eval(context.Background(), initSchemaLua)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *tarantool) Close() error {
+	s.pool.Close()
+
+	return nil
+}
+
+func (s *tarantool) GetClusters(ctx context.Context) ([]storage.ClusterSnapshotResp, error) {
+	rows, err := s.eval(ctx, getClustersLua)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]storage.ClusterSnapshotResp, 0, len(rows))
+	for _, row := range rows {
+		tuple, ok := row.([]interface{})
+		if !ok || len(tuple) != 2 {
+			return nil, ErrUnexpectedResponse
+		}
+
+		snapResp := storage.ClusterSnapshotResp{}
+		snapResp.Name, ok = tuple[0].(string)
+		if !ok {
+			return nil, ErrUnexpectedResponse
+		}
+
+		err = unmarshal(tuple[1], &snapResp.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		resp = append(resp, snapResp)
+	}
+
+	return resp, nil
+}
+
+func (s *tarantool) SaveSnapshot(ctx context.Context, clusterName string, snapshot vshard.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// Concurrent saves of several qumomf nodes might append the same snapshot twice
+	// which does not affect the point-in-time queries.
+	rows, err := s.eval(ctx, getLastHistorySnapshotLua, clusterName)
+	if err != nil {
+		return err
+	}
+	var last []byte
+	if len(rows) > 0 && rows[0] != nil {
+		data, ok := rows[0].(string)
+		if !ok {
+			return ErrUnexpectedResponse
+		}
+		last = []byte(data)
+	}
+	appendHistory, err := storage.AppendToHistory(last, &snapshot)
+	if err != nil {
+		return err
+	}
+
+	cleanup := s.config.SnapshotRetention > 0
+	before := snapshot.Created - int64(s.config.SnapshotRetention.Seconds())
+
+	_, err = s.eval(ctx, saveSnapshotLua, clusterName, snapshot.Created, string(data), appendHistory, cleanup, before)
+
+	return err
+}
+
+func (s *tarantool) SaveRecovery(ctx context.Context, recovery orchestrator.Recovery) error {
+	return s.insertLog(ctx, spaceRecoveries, recovery.ClusterName, recovery.EndTimestamp, recovery)
+}
+
+func (s *tarantool) GetClusterSnapshot(ctx context.Context, clusterName string) (vshard.Snapshot, error) {
+	return s.querySnapshot(ctx, getLastSnapshotLua, clusterName)
+}
+
+func (s *tarantool) GetSnapshotTimestamps(ctx context.Context, clusterName string, from, to int64) ([]int64, error) {
+	rows, err := s.eval(ctx, getSnapshotTimestampsLua, clusterName, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		createdAt, err := toInt64(row)
+		if err != nil {
+			return nil, err
+		}
+
+		resp = append(resp, createdAt)
+	}
+
+	return resp, nil
+}
+
+func (s *tarantool) GetSnapshotAt(ctx context.Context, clusterName string, at int64) (vshard.Snapshot, error) {
+	return s.querySnapshot(ctx, getSnapshotAtLua, clusterName, at)
+}
+
+func (s *tarantool) querySnapshot(ctx context.Context, lua string, args ...interface{}) (vshard.Snapshot, error) {
+	var ns vshard.Snapshot
+
+	rows, err := s.eval(ctx, lua, args...)
+	if err != nil {
+		return ns, err
+	}
+	if len(rows) == 0 || rows[0] == nil {
+		return ns, storage.ErrEmptyResult
+	}
+	err = unmarshal(rows[0], &ns)
+
+	return ns, err
+}
+
+func (s *tarantool) GetRecoveries(ctx context.Context, clusterName string) ([]orchestrator.Recovery, error) {
+	resp := make([]orchestrator.Recovery, 0)
+	err := s.getLog(ctx, spaceRecoveries, clusterName, func(data interface{}) error {
+		var recovery orchestrator.Recovery
+		err := unmarshal(data, &recovery)
+		resp = append(resp, recovery)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *tarantool) SaveReadOnlyOverride(ctx context.Context, override storage.ReadOnlyOverride) error {
+	return s.insertLog(ctx, spaceReadOnlyOverrides, override.ClusterName, override.CreatedAt, override)
+}
+
+func (s *tarantool) GetReadOnlyOverrides(ctx context.Context, clusterName string) ([]storage.ReadOnlyOverride, error) {
+	resp := make([]storage.ReadOnlyOverride, 0)
+	err := s.getLog(ctx, spaceReadOnlyOverrides, clusterName, func(data interface{}) error {
+		var override storage.ReadOnlyOverride
+		err := unmarshal(data, &override)
+		resp = append(resp, override)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *tarantool) SaveHostDrain(ctx context.Context, drain storage.HostDrain) error {
+	return s.insertLog(ctx, spaceHostDrains, drain.ClusterName, drain.CreatedAt, drain)
+}
+
+func (s *tarantool) GetHostDrains(ctx context.Context, clusterName string) ([]storage.HostDrain, error) {
+	resp := make([]storage.HostDrain, 0)
+	err := s.getLog(ctx, spaceHostDrains, clusterName, func(data interface{}) error {
+		var drain storage.HostDrain
+		err := unmarshal(data, &drain)
+		resp = append(resp, drain)
+		return err
+	})
+
+	return resp, err
+}
+
+func (s *tarantool) insertLog(ctx context.Context, space, clusterName string, createdAt int64, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.eval(ctx, fmt.Sprintf(insertLogLua, space), clusterName, createdAt, string(data))
+
+	return err
+}
+
+func (s *tarantool) getLog(ctx context.Context, space, clusterName string, add func(data interface{}) error) error {
+	rows, err := s.eval(ctx, fmt.Sprintf(getLogLua, space), clusterName)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		err = add(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *tarantool) SaveHookJob(ctx context.Context, job orchestrator.HookJob) (int64, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return 0, err
+	}
+
+	if job.ID == 0 {
+		rows, err := s.eval(ctx, insertHookJobLua, job.ClusterName, string(job.Status), job.CreatedAt, string(data))
+		if err != nil {
+			return 0, err
+		}
+		if len(rows) == 0 {
+			return 0, ErrUnexpectedResponse
+		}
+		return toInt64(rows[0])
+	}
+
+	rows, err := s.eval(ctx, updateHookJobLua, job.ID, string(job.Status), string(data))
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, ErrUnexpectedResponse
+	}
+	if updated, _ := rows[0].(bool); !updated {
+		return 0, orchestrator.ErrHookJobNotFound
+	}
+
+	return job.ID, nil
+}
+
+func (s *tarantool) GetHookJob(ctx context.Context, id int64) (orchestrator.HookJob, error) {
+	var job orchestrator.HookJob
+
+	rows, err := s.eval(ctx, getHookJobLua, id)
+	if err != nil {
+		return job, err
+	}
+	if len(rows) == 0 || rows[0] == nil {
+		return job, orchestrator.ErrHookJobNotFound
+	}
+	err = unmarshal(rows[0], &job)
+	job.ID = id
+
+	return job, err
+}
+
+func (s *tarantool) GetHookJobs(ctx context.Context, clusterName string, status orchestrator.HookJobStatus) ([]orchestrator.HookJob, error) {
+	jobs, err := s.getRawHookJobs(ctx, getHookJobsLua, clusterName, string(status))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]orchestrator.HookJob, 0, len(jobs))
+	for _, raw := range jobs {
+		var job orchestrator.HookJob
+		err = json.Unmarshal(raw.Data, &job)
+		if err != nil {
+			return nil, err
+		}
+		job.ID = raw.ID
+
+		resp = append(resp, job)
+	}
+
+	return resp, nil
+}
+
+func (s *tarantool) ClaimHookJobs(ctx context.Context, clusterName string, now int64) ([]orchestrator.HookJob, error) {
+	jobs, err := s.getRawHookJobs(ctx, getClaimableHookJobsLua, clusterName,
+		string(orchestrator.HookJobPending), string(orchestrator.HookJobRunning))
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.ClaimHookJobs(jobs, now, func(id int64, status orchestrator.HookJobStatus, old, new []byte) (bool, error) {
+		rows, err := s.eval(ctx, swapHookJobLua, id, string(status), string(old), string(new))
+		if err != nil {
+			return false, err
+		}
+		if len(rows) == 0 {
+			return false, ErrUnexpectedResponse
+		}
+		swapped, _ := rows[0].(bool)
+
+		return swapped, nil
+	})
+}
+
+// getRawHookJobs returns the hook jobs selected by the Lua code as the list of {id, data} tuples.
+func (s *tarantool) getRawHookJobs(ctx context.Context, lua string, args ...interface{}) ([]storage.RawHookJob, error) {
+	rows, err := s.eval(ctx, lua, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]storage.RawHookJob, 0, len(rows))
+	for _, row := range rows {
+		tuple, ok := row.([]interface{})
+		if !ok || len(tuple) != 2 {
+			return nil, ErrUnexpectedResponse
+		}
+
+		id, err := toInt64(tuple[0])
+		if err != nil {
+			return nil, err
+		}
+		data, ok := tuple[1].(string)
+		if !ok {
+			return nil, ErrUnexpectedResponse
+		}
+
+		jobs = append(jobs, storage.RawHookJob{ID: id, Data: []byte(data)})
+	}
+
+	return jobs, nil
+}
+
+// eval evaluates the Lua code and returns its first result.
+// A table is returned as the list of its values, a scalar as the list of one value.
+func (s *tarantool) eval(ctx context.Context, lua string, args ...interface{}) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.QueryTimeout)
+	defer cancel()
+
+	resp := s.conn.Exec(ctx, &tnt.Eval{
+		Expression: lua,
+		Tuple:      args,
+	})
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	if len(resp.Data) == 0 {
+		return nil, nil
+	}
+
+	return resp.Data[0], nil
+}
+
+func unmarshal(data interface{}, v interface{}) error {
+	s, ok := data.(string)
+	if !ok {
+		return ErrUnexpectedResponse
+	}
+
+	return json.Unmarshal([]byte(s), v)
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case uint64:
+		return int64(n), nil
+	default:
+		return 0, ErrUnexpectedResponse
+	}
+}
@@ -0,0 +1,56 @@
+package tarantool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/storage"
+	"github.com/shmel1k/qumomf/internal/storage/storagetest"
+)
+
+var (
+	tAddr = "127.0.0.1:9308"
+)
+
+func TestStorage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("test requires dev env - skipping it in short mode.")
+	}
+
+	storagetest.Run(t, func(t *testing.T, retention time.Duration) (storage.Storage, func()) {
+		db, err := New(Config{
+			Addr:              tAddr,
+			User:              "qumomf",
+			Password:          "qumomf",
+			ConnectTimeout:    3 * time.Second,
+			QueryTimeout:      3 * time.Second,
+			SnapshotRetention: retention,
+		})
+		require.NoError(t, err)
+		truncate(t, db)
+
+		return db, func() {
+			truncate(t, db)
+			require.NoError(t, db.Close())
+		}
+	}, func(t *testing.T, db storage.Storage, id int64, data []byte) {
+		_, err := db.(*tarantool).eval(context.Background(), `
+			local id, data = ...
+			box.space.qumomf_hook_jobs:update(id, {{'=', 5, data}})
+		`, id, string(data))
+		require.NoError(t, err)
+	})
+}
+
+func truncate(t *testing.T, db storage.Storage) {
+	_, err := db.(*tarantool).eval(context.Background(), `
+		for _, name in ipairs({'qumomf_snapshots', 'qumomf_snapshot_history', 'qumomf_recoveries',
+			'qumomf_readonly_overrides', 'qumomf_host_drains', 'qumomf_hook_jobs'}) do
+			box.space[name]:truncate()
+		end
+	`)
+	require.NoError(t, err)
+}
@@ -8,10 +8,19 @@ func Timestamp() int64 {
 	return time.Now().Unix()
 }
 
+// TimestampMs returns the current unix time in milliseconds.
+func TimestampMs() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
 func NewBool(v bool) *bool {
 	return &v
 }
 
+func NewInt(v int) *int {
+	return &v
+}
+
 func NewDuration(v time.Duration) *time.Duration {
 	return &v
 }
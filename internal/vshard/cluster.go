@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
@@ -45,6 +46,30 @@ var (
 			return repl
 		`,
 	}
+	vshardInstanceStateQuery = &tarantool.Eval{
+		Expression: `
+			local info = box.info
+
+			local vclock = {}
+			for id, lsn in pairs(info.vclock) do
+				table.insert(vclock, {id, lsn})
+			end
+
+			local upstreams = {}
+			for _, r in pairs(info.replication) do
+				if r.upstream ~= nil then
+					table.insert(upstreams, {r.id, r.upstream.status})
+				end
+			end
+
+			return {
+				status = info.status,
+				read_only = box.cfg.read_only,
+				vclock = vclock,
+				upstreams = upstreams,
+			}
+		`,
+	}
 	vshardInstanceInfoQuery = &tarantool.Eval{
 		// to calculate crc32 of the shard config we have to
 		// deep sort the config otherwise we might get different hashes
@@ -77,6 +102,11 @@ var (
 			data.storage = vshard.storage.info()
 			data.read_only = box.cfg.read_only
 			data.vshard_fingerprint = c:result()
+
+			local this_cfg = shard_cfg[box.info.uuid]
+			if this_cfg ~= nil and this_cfg.zone ~= nil then
+				data.zone = tostring(this_cfg.zone)
+			end
 			return data
 		`,
 	}
@@ -94,8 +124,11 @@ type Cluster struct {
 	pool     ConnPool
 	snapshot Snapshot
 
-	readOnly          bool
-	hasActiveRecovery bool
+	readOnly bool
+
+	// activeRecoveries contains the replica sets
+	// which are being recovered by qumomf.
+	activeRecoveries map[ReplicaSetUUID]struct{}
 
 	mutex  sync.RWMutex
 	logger zerolog.Logger
@@ -117,9 +150,13 @@ func NewCluster(name string, cfg config.ClusterConfig) *Cluster {
 		snapshot: Snapshot{
 			Created: util.Timestamp(),
 		},
-		readOnly: *cfg.ReadOnly,
+		readOnly:         *cfg.ReadOnly,
+		activeRecoveries: make(map[ReplicaSetUUID]struct{}),
 	}
 	c.snapshot.UpdatePriorities(cfg.Priorities)
+	c.snapshot.UpdateZones(cfg.Zones)
+	c.snapshot.UpdateHosts(cfg.Hosts)
+	c.snapshot.UpdateDrainedHosts(make(map[string]bool))
 
 	routers := make([]Router, 0, len(cfg.Routers))
 	for _, r := range cfg.Routers {
@@ -147,6 +184,40 @@ func (c *Cluster) SetPriorities(priorities map[string]int) {
 	c.mutex.Unlock()
 }
 
+// SetHostDrained marks or unmarks the host as drained.
+// Instances on the drained hosts are excluded from the election.
+func (c *Cluster) SetHostDrained(host string, drained bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Snapshots given away share the map, so it is never modified in place.
+	hosts := make(map[string]bool, len(c.snapshot.drained)+1)
+	for h := range c.snapshot.drained {
+		hosts[h] = true
+	}
+	if drained {
+		hosts[host] = true
+	} else {
+		delete(hosts, host)
+	}
+
+	c.snapshot.UpdateDrainedHosts(hosts)
+}
+
+// DrainedHosts returns the sorted list of the drained hosts.
+func (c *Cluster) DrainedHosts() []string {
+	c.mutex.RLock()
+	hosts := make([]string, 0, len(c.snapshot.drained))
+	for h := range c.snapshot.drained {
+		hosts = append(hosts, h)
+	}
+	c.mutex.RUnlock()
+
+	sort.Strings(hosts)
+
+	return hosts
+}
+
 func (c *Cluster) Dump() string {
 	c.mutex.RLock()
 	j, _ := json.Marshal(c.snapshot)
@@ -242,25 +313,59 @@ func (c *Cluster) Instance(uuid InstanceUUID) (Instance, error) {
 	return Instance{}, ErrInstanceNotFound
 }
 
-func (c *Cluster) StartRecovery() {
+// StartRecovery marks the replica set as being recovered.
+//
+// It returns false if the replica set already has an active recovery
+// or the cluster has reached the limit of concurrent recoveries.
+// Non-positive limit means there is no limit.
+func (c *Cluster) StartRecovery(uuid ReplicaSetUUID, limit int) bool {
 	c.mutex.Lock()
-	c.hasActiveRecovery = true
-	c.mutex.Unlock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.activeRecoveries[uuid]; ok {
+		return false
+	}
+	if limit > 0 && len(c.activeRecoveries) >= limit {
+		return false
+	}
+	c.activeRecoveries[uuid] = struct{}{}
+
+	return true
 }
 
-func (c *Cluster) StopRecovery() {
+func (c *Cluster) StopRecovery(uuid ReplicaSetUUID) {
 	c.mutex.Lock()
-	c.hasActiveRecovery = false
+	delete(c.activeRecoveries, uuid)
 	c.mutex.Unlock()
 }
 
-// HasActiveRecovery indicates when the cluster is suffering from
+// HasActiveRecovery indicates when the replica set is suffering from
 // some kind of failure and qumomf is running a failover process.
-func (c *Cluster) HasActiveRecovery() bool {
+func (c *Cluster) HasActiveRecovery(uuid ReplicaSetUUID) bool {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	return c.hasActiveRecovery
+	_, ok := c.activeRecoveries[uuid]
+	return ok
+}
+
+// ActiveRecoveries returns the number of replica sets being recovered.
+func (c *Cluster) ActiveRecoveries() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return len(c.activeRecoveries)
+}
+
+// InstanceState reads the live state of the instance bypassing the snapshot.
+func (c *Cluster) InstanceState(ctx context.Context, inst Instance) (InstanceState, error) {
+	conn := c.Connector(inst.URI)
+	resp := conn.Exec(ctx, vshardInstanceStateQuery)
+	if resp.Error != nil {
+		return InstanceState{}, resp.Error
+	}
+
+	return ParseInstanceState(resp.Data)
 }
 
 func (c *Cluster) Shutdown() {
@@ -378,6 +483,9 @@ func (c *Cluster) Discover() {
 	c.mutex.Lock()
 	if c.snapshot.Created <= ns.Created {
 		ns.UpdatePriorities(c.snapshot.priorities)
+		ns.UpdateZones(c.snapshot.zones)
+		ns.UpdateHosts(c.snapshot.hosts)
+		ns.UpdateDrainedHosts(c.snapshot.drained)
 		c.snapshot = ns
 
 		if c.onClusterDiscoveredCB != nil {
@@ -469,6 +577,7 @@ func (c *Cluster) discoverInstance(ctx context.Context, inst *Instance) {
 	inst.Readonly = info.Readonly
 	inst.StorageInfo = info.StorageInfo
 	inst.VShardFingerprint = info.VShardFingerprint
+	inst.Zone = info.Zone
 	inst.LastCheckValid = true
 }
 
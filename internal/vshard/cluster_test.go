@@ -216,3 +216,69 @@ func TestCluster_Instance(t *testing.T) {
 		})
 	}
 }
+
+func TestCluster_StartRecovery(t *testing.T) {
+	c := MockCluster()
+
+	require.True(t, c.StartRecovery("set_1", 2))
+	assert.True(t, c.HasActiveRecovery("set_1"))
+	assert.False(t, c.HasActiveRecovery("set_2"))
+
+	// The same replica set must not be recovered concurrently.
+	assert.False(t, c.StartRecovery("set_1", 2))
+
+	require.True(t, c.StartRecovery("set_2", 2))
+	assert.Equal(t, 2, c.ActiveRecoveries())
+
+	// The limit of concurrent recoveries is reached.
+	assert.False(t, c.StartRecovery("set_3", 2))
+	assert.True(t, c.StartRecovery("set_3", 0))
+
+	c.StopRecovery("set_1")
+	c.StopRecovery("set_3")
+	assert.False(t, c.HasActiveRecovery("set_1"))
+	assert.Equal(t, 1, c.ActiveRecoveries())
+	assert.True(t, c.StartRecovery("set_1", 2))
+}
+
+func TestCluster_SetHostDrained(t *testing.T) {
+	c := MockCluster()
+	c.snapshot = Snapshot{
+		Created: util.Timestamp(),
+		Routers: c.Routers(),
+		ReplicaSets: []ReplicaSet{
+			{
+				UUID:       "set_1",
+				MasterUUID: "set_1_replica_1",
+				Instances: []Instance{
+					{
+						UUID: "set_1_replica_1",
+						URI:  "host-1:3301",
+					},
+					{
+						UUID: "set_1_replica_2",
+						URI:  "host-2:3301",
+					},
+				},
+			},
+		},
+	}
+
+	c.SetHostDrained("host-1", true)
+	c.SetHostDrained("host-3", true)
+	assert.Equal(t, []string{"host-1", "host-3"}, c.DrainedHosts())
+
+	inst, err := c.Instance("set_1_replica_1")
+	require.NoError(t, err)
+	assert.True(t, inst.Drained)
+	inst, err = c.Instance("set_1_replica_2")
+	require.NoError(t, err)
+	assert.False(t, inst.Drained)
+
+	c.SetHostDrained("host-1", false)
+	assert.Equal(t, []string{"host-3"}, c.DrainedHosts())
+
+	inst, err = c.Instance("set_1_replica_1")
+	require.NoError(t, err)
+	assert.False(t, inst.Drained)
+}
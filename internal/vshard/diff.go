@@ -0,0 +1,254 @@
+package vshard
+
+import (
+	"sort"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeRemoved ChangeType = "removed"
+	ChangeChanged ChangeType = "changed"
+)
+
+// SnapshotDiff describes the changes of the replica sets
+// and instances between two snapshots of the cluster.
+type SnapshotDiff struct {
+	// From and To are the creation times of the compared snapshots.
+	From        int64            `json:"from"`
+	To          int64            `json:"to"`
+	ReplicaSets []ReplicaSetDiff `json:"replica_sets"`
+}
+
+// ReplicaSetDiff contains only the changed properties of the replica set.
+type ReplicaSetDiff struct {
+	UUID   ReplicaSetUUID `json:"uuid"`
+	Change ChangeType     `json:"change"`
+	Master *MasterChange  `json:"master,omitempty"`
+	// Health is the change of the replica set health level reported by the master.
+	Health    *StringChange  `json:"health,omitempty"`
+	Instances []InstanceDiff `json:"instances,omitempty"`
+}
+
+// InstanceDiff contains only the changed properties of the instance.
+type InstanceDiff struct {
+	UUID              InstanceUUID  `json:"uuid"`
+	URI               string        `json:"uri"`
+	Change            ChangeType    `json:"change"`
+	Readonly          *BoolChange   `json:"readonly,omitempty"`
+	VShardFingerprint *Uint64Change `json:"vshard_fingerprint,omitempty"`
+	// Health is the change of the storage health level.
+	Health            *StringChange `json:"health,omitempty"`
+	ReplicationStatus *StringChange `json:"replication_status,omitempty"`
+	AlertsAdded       []Alert       `json:"alerts_added,omitempty"`
+	AlertsRemoved     []Alert       `json:"alerts_removed,omitempty"`
+	LSNBehindMaster   *LSNLagChange `json:"lsn_behind_master,omitempty"`
+}
+
+type MasterChange struct {
+	From    InstanceUUID `json:"from"`
+	FromURI string       `json:"from_uri"`
+	To      InstanceUUID `json:"to"`
+	ToURI   string       `json:"to_uri"`
+}
+
+type StringChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type BoolChange struct {
+	From bool `json:"from"`
+	To   bool `json:"to"`
+}
+
+type Uint64Change struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+type LSNLagChange struct {
+	From  int64 `json:"from"`
+	To    int64 `json:"to"`
+	Delta int64 `json:"delta"`
+}
+
+// DiffSnapshots compares two snapshots of the cluster.
+// Added and removed replica sets and instances are reported with all their instances,
+// the changed ones contain only the changed properties.
+func DiffSnapshots(from, to *Snapshot) SnapshotDiff {
+	diff := SnapshotDiff{
+		From:        from.Created,
+		To:          to.Created,
+		ReplicaSets: make([]ReplicaSetDiff, 0),
+	}
+
+	for i := range from.ReplicaSets {
+		prev := &from.ReplicaSets[i]
+		next, err := to.ReplicaSet(prev.UUID)
+		if err != nil {
+			diff.ReplicaSets = append(diff.ReplicaSets, wholeReplicaSetDiff(prev, ChangeRemoved))
+			continue
+		}
+		if d, changed := diffReplicaSets(prev, &next); changed {
+			diff.ReplicaSets = append(diff.ReplicaSets, d)
+		}
+	}
+
+	for i := range to.ReplicaSets {
+		next := &to.ReplicaSets[i]
+		if _, err := from.ReplicaSet(next.UUID); err != nil {
+			diff.ReplicaSets = append(diff.ReplicaSets, wholeReplicaSetDiff(next, ChangeAdded))
+		}
+	}
+
+	sort.Slice(diff.ReplicaSets, func(i, j int) bool {
+		return diff.ReplicaSets[i].UUID < diff.ReplicaSets[j].UUID
+	})
+
+	return diff
+}
+
+func wholeReplicaSetDiff(set *ReplicaSet, change ChangeType) ReplicaSetDiff {
+	d := ReplicaSetDiff{
+		UUID:   set.UUID,
+		Change: change,
+	}
+	for i := range set.Instances {
+		inst := &set.Instances[i]
+		d.Instances = append(d.Instances, InstanceDiff{
+			UUID:   inst.UUID,
+			URI:    inst.URI,
+			Change: change,
+		})
+	}
+	sortInstanceDiffs(d.Instances)
+
+	return d
+}
+
+func diffReplicaSets(prev, next *ReplicaSet) (ReplicaSetDiff, bool) {
+	d := ReplicaSetDiff{
+		UUID:   prev.UUID,
+		Change: ChangeChanged,
+	}
+	changed := false
+
+	if prev.MasterUUID != next.MasterUUID {
+		d.Master = &MasterChange{
+			From:    prev.MasterUUID,
+			FromURI: prev.MasterURI,
+			To:      next.MasterUUID,
+			ToURI:   next.MasterURI,
+		}
+		changed = true
+	}
+
+	_, prevHealth := prev.HealthStatus()
+	_, nextHealth := next.HealthStatus()
+	if prevHealth != nextHealth {
+		d.Health = &StringChange{From: string(prevHealth), To: string(nextHealth)}
+		changed = true
+	}
+
+	instances := make(map[InstanceUUID]*Instance, len(next.Instances))
+	for i := range next.Instances {
+		instances[next.Instances[i].UUID] = &next.Instances[i]
+	}
+
+	for i := range prev.Instances {
+		p := &prev.Instances[i]
+		n, ok := instances[p.UUID]
+		if !ok {
+			d.Instances = append(d.Instances, InstanceDiff{UUID: p.UUID, URI: p.URI, Change: ChangeRemoved})
+			continue
+		}
+		delete(instances, p.UUID)
+
+		if id, ok := diffInstances(p, n); ok {
+			d.Instances = append(d.Instances, id)
+		}
+	}
+
+	for _, n := range instances {
+		d.Instances = append(d.Instances, InstanceDiff{UUID: n.UUID, URI: n.URI, Change: ChangeAdded})
+	}
+
+	sortInstanceDiffs(d.Instances)
+
+	return d, changed || len(d.Instances) > 0
+}
+
+func diffInstances(prev, next *Instance) (InstanceDiff, bool) {
+	d := InstanceDiff{
+		UUID:   next.UUID,
+		URI:    next.URI,
+		Change: ChangeChanged,
+	}
+	changed := false
+
+	if prev.Readonly != next.Readonly {
+		d.Readonly = &BoolChange{From: prev.Readonly, To: next.Readonly}
+		changed = true
+	}
+
+	if prev.VShardFingerprint != next.VShardFingerprint {
+		d.VShardFingerprint = &Uint64Change{From: prev.VShardFingerprint, To: next.VShardFingerprint}
+		changed = true
+	}
+
+	if prev.CriticalLevel() != next.CriticalLevel() {
+		d.Health = &StringChange{From: string(prev.CriticalLevel()), To: string(next.CriticalLevel())}
+		changed = true
+	}
+
+	prevStatus := prev.StorageInfo.Replication.Status
+	nextStatus := next.StorageInfo.Replication.Status
+	if prevStatus != nextStatus {
+		d.ReplicationStatus = &StringChange{From: string(prevStatus), To: string(nextStatus)}
+		changed = true
+	}
+
+	d.AlertsAdded = subtractAlerts(next.StorageInfo.Alerts, prev.StorageInfo.Alerts)
+	d.AlertsRemoved = subtractAlerts(prev.StorageInfo.Alerts, next.StorageInfo.Alerts)
+	if len(d.AlertsAdded) > 0 || len(d.AlertsRemoved) > 0 {
+		changed = true
+	}
+
+	if prev.LSNBehindMaster != next.LSNBehindMaster {
+		d.LSNBehindMaster = &LSNLagChange{
+			From:  prev.LSNBehindMaster,
+			To:    next.LSNBehindMaster,
+			Delta: next.LSNBehindMaster - prev.LSNBehindMaster,
+		}
+		changed = true
+	}
+
+	return d, changed
+}
+
+// subtractAlerts returns the alerts of a which are not in b.
+func subtractAlerts(a, b []Alert) []Alert {
+	var diff []Alert
+	for _, alert := range a {
+		found := false
+		for _, another := range b {
+			if alert == another {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, alert)
+		}
+	}
+
+	return diff
+}
+
+func sortInstanceDiffs(diffs []InstanceDiff) {
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].UUID < diffs[j].UUID
+	})
+}
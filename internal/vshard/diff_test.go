@@ -0,0 +1,126 @@
+package vshard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	alert := Alert{Type: AlertUnreachableReplica, Description: "Replica is unreachable"}
+
+	from := Snapshot{
+		Created: 100,
+		ReplicaSets: []ReplicaSet{
+			{
+				UUID:       "set_1",
+				MasterUUID: "instance_1",
+				MasterURI:  "localhost:3301",
+				Instances: []Instance{
+					{UUID: "instance_1", URI: "localhost:3301", VShardFingerprint: 1},
+					{UUID: "instance_2", URI: "localhost:3302", Readonly: true, VShardFingerprint: 1, LSNBehindMaster: 10},
+					{UUID: "instance_3", URI: "localhost:3303", Readonly: true},
+				},
+			},
+			{
+				UUID:       "set_2",
+				MasterUUID: "instance_4",
+				Instances:  []Instance{{UUID: "instance_4"}},
+			},
+			{
+				UUID:       "set_3",
+				MasterUUID: "instance_5",
+				Instances:  []Instance{{UUID: "instance_5", URI: "localhost:3305"}},
+			},
+		},
+	}
+
+	to := Snapshot{
+		Created: 200,
+		ReplicaSets: []ReplicaSet{
+			{
+				UUID:       "set_1",
+				MasterUUID: "instance_2",
+				MasterURI:  "localhost:3302",
+				Instances: []Instance{
+					{
+						UUID:              "instance_1",
+						URI:               "localhost:3301",
+						Readonly:          true,
+						VShardFingerprint: 2,
+						StorageInfo: StorageInfo{
+							Status:      HealthCodeOrange,
+							Replication: Replication{Status: StatusDisconnected},
+							Alerts:      []Alert{alert},
+						},
+					},
+					{UUID: "instance_2", URI: "localhost:3302", VShardFingerprint: 2},
+					{UUID: "instance_6", URI: "localhost:3306", Readonly: true},
+				},
+			},
+			{
+				UUID:       "set_2",
+				MasterUUID: "instance_4",
+				Instances:  []Instance{{UUID: "instance_4"}},
+			},
+			{
+				UUID:       "set_4",
+				MasterUUID: "instance_7",
+				Instances:  []Instance{{UUID: "instance_7", URI: "localhost:3307"}},
+			},
+		},
+	}
+
+	diff := DiffSnapshots(&from, &to)
+	assert.Equal(t, int64(100), diff.From)
+	assert.Equal(t, int64(200), diff.To)
+	require.Len(t, diff.ReplicaSets, 3)
+
+	changed := diff.ReplicaSets[0]
+	assert.Equal(t, ReplicaSetUUID("set_1"), changed.UUID)
+	assert.Equal(t, ChangeChanged, changed.Change)
+	assert.Equal(t, &MasterChange{
+		From:    "instance_1",
+		FromURI: "localhost:3301",
+		To:      "instance_2",
+		ToURI:   "localhost:3302",
+	}, changed.Master)
+	assert.Nil(t, changed.Health, "both masters are green")
+	assert.Equal(t, []InstanceDiff{
+		{
+			UUID:              "instance_1",
+			URI:               "localhost:3301",
+			Change:            ChangeChanged,
+			Readonly:          &BoolChange{From: false, To: true},
+			VShardFingerprint: &Uint64Change{From: 1, To: 2},
+			Health:            &StringChange{From: "green", To: "orange"},
+			ReplicationStatus: &StringChange{From: "", To: "disconnected"},
+			AlertsAdded:       []Alert{alert},
+		},
+		{
+			UUID:              "instance_2",
+			URI:               "localhost:3302",
+			Change:            ChangeChanged,
+			Readonly:          &BoolChange{From: true, To: false},
+			VShardFingerprint: &Uint64Change{From: 1, To: 2},
+			LSNBehindMaster:   &LSNLagChange{From: 10, To: 0, Delta: -10},
+		},
+		{UUID: "instance_3", URI: "localhost:3303", Change: ChangeRemoved},
+		{UUID: "instance_6", URI: "localhost:3306", Change: ChangeAdded},
+	}, changed.Instances)
+
+	assert.Equal(t, ReplicaSetDiff{
+		UUID:      "set_3",
+		Change:    ChangeRemoved,
+		Instances: []InstanceDiff{{UUID: "instance_5", URI: "localhost:3305", Change: ChangeRemoved}},
+	}, diff.ReplicaSets[1])
+	assert.Equal(t, ReplicaSetDiff{
+		UUID:      "set_4",
+		Change:    ChangeAdded,
+		Instances: []InstanceDiff{{UUID: "instance_7", URI: "localhost:3307", Change: ChangeAdded}},
+	}, diff.ReplicaSets[2])
+
+	same := DiffSnapshots(&to, &to)
+	assert.Empty(t, same.ReplicaSets)
+}
@@ -1,6 +1,9 @@
 package vshard
 
-import "strings"
+import (
+	"net"
+	"strings"
+)
 
 type InstanceUUID string
 
@@ -94,6 +97,18 @@ type Instance struct {
 	//
 	// If priority less than 0, instance will not participate in the master election.
 	Priority int `json:"priority"`
+
+	// Zone is a location (e.g. datacenter) of the instance read from
+	// the vshard configuration or overridden by qumomf configuration.
+	Zone string `json:"zone"`
+
+	// HostLabel is a host name of the instance defined in qumomf configuration.
+	// If empty, the host is derived from the instance URI.
+	HostLabel string `json:"host_label,omitempty"`
+
+	// Drained indicates whether the host of the instance is drained.
+	// Instances on the drained hosts are excluded from the election.
+	Drained bool `json:"drained,omitempty"`
 }
 
 // InstanceIdent contains unique UUID and URI of the instance.
@@ -181,6 +196,28 @@ func (i *Instance) Idle() float64 {
 	return i.Upstream.Idle
 }
 
+// Host returns the host label of the instance
+// or the host part of the instance URI if no label is set.
+// Empty string means the host is unknown, such instances
+// must not be considered to share a host with each other.
+func (i *Instance) Host() string {
+	if i.HostLabel != "" {
+		return i.HostLabel
+	}
+
+	uri := i.URI
+	if idx := strings.LastIndex(uri, "@"); idx >= 0 {
+		uri = uri[idx+1:]
+	}
+
+	host, _, err := net.SplitHostPort(uri)
+	if err != nil {
+		return uri
+	}
+
+	return host
+}
+
 func (i *Instance) SameAs(another Instance) bool {
 	return i.UUID == another.UUID &&
 		i.URI == another.URI &&
@@ -188,7 +225,10 @@ func (i *Instance) SameAs(another Instance) bool {
 		i.ID == another.ID &&
 		i.Readonly == another.Readonly &&
 		i.StorageInfo.Replication.Status == another.StorageInfo.Replication.Status &&
-		i.StorageInfo.Status == another.StorageInfo.Status
+		i.StorageInfo.Status == another.StorageInfo.Status &&
+		i.Zone == another.Zone &&
+		i.HostLabel == another.HostLabel &&
+		i.Drained == another.Drained
 }
 
 // InstanceInfo is a helper structure contains
@@ -197,6 +237,19 @@ type InstanceInfo struct {
 	Readonly          bool
 	VShardFingerprint uint64
 	StorageInfo       StorageInfo
+	Zone              string
+}
+
+// InstanceState is a live state of the instance
+// read directly from box.info.
+type InstanceState struct {
+	// Status is the instance status, e.g. running or orphan.
+	Status   string
+	ReadOnly bool
+	// VClock maps the instance id to its LSN.
+	VClock map[uint64]int64
+	// Upstreams maps the instance id to the upstream status.
+	Upstreams map[uint64]UpstreamStatus
 }
 
 type StorageInfo struct {
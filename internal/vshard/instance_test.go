@@ -0,0 +1,29 @@
+package vshard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstance_Host(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "127.0.0.1:3301", want: "127.0.0.1"},
+		{uri: "qumomf:qumomf@qumomf_1_m.ddk:3301", want: "qumomf_1_m.ddk"},
+		{uri: "[::1]:3301", want: "::1"},
+		{uri: "localhost", want: "localhost"},
+		{uri: ":3301", want: ""},
+		{uri: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		inst := Instance{URI: tt.uri}
+		assert.Equal(t, tt.want, inst.Host(), tt.uri)
+	}
+
+	inst := Instance{URI: "127.0.0.1:3301", HostLabel: "host1"}
+	assert.Equal(t, "host1", inst.Host())
+}
@@ -16,4 +16,13 @@ type FailoverConfig struct {
 	Elector                     quorum.Elector
 	InstanceRecoveryBlockTime   time.Duration
 	ReplicaSetRecoveryBlockTime time.Duration
+	// RecoveryTimeout is a total deadline of each recovery.
+	// Non-positive value means no deadline.
+	RecoveryTimeout time.Duration
+	// MaxConcurrentRecoveries is a max number of replica sets
+	// recovered at the same time. Non-positive value means no limit.
+	MaxConcurrentRecoveries int
+	// MaxCandidateAttempts is a max number of ranked candidates
+	// checked before promotion. Non-positive value means one attempt.
+	MaxCandidateAttempts int
 }
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"sort"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+// DrainReport contains the switchovers moving masters away from the drained host.
+type DrainReport struct {
+	Host        string              `json:"host"`
+	Switchovers []PlannedSwitchover `json:"switchovers"`
+	// Unmovable contains the replica sets which masters
+	// are located on the host but have no candidate to move to.
+	Unmovable []vshard.ReplicaSetUUID `json:"unmovable"`
+	Results   []SwitchoverResult      `json:"results"`
+	// InProgress indicates whether the switchovers are still being executed.
+	InProgress bool `json:"in_progress"`
+	// Completed indicates whether all masters have been moved away from the host.
+	Completed bool `json:"completed"`
+}
+
+// PlanDrain returns the switchovers moving all masters away from the host.
+//
+// The candidate is chosen among the followers allowed by the rebalance
+// planner located on other hosts. The least loaded host is preferred.
+// Replica sets with no such followers or unavailable masters are returned as unmovable.
+func PlanDrain(sets []vshard.ReplicaSet, host string) (switchovers []PlannedSwitchover, unmovable []vshard.ReplicaSetUUID) {
+	p := newPlacement(sets)
+
+	sorted := make([]vshard.ReplicaSet, len(sets))
+	copy(sorted, sets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].UUID < sorted[j].UUID
+	})
+
+	switchovers = make([]PlannedSwitchover, 0)
+	unmovable = make([]vshard.ReplicaSetUUID, 0)
+	for i := range sorted {
+		set := &sorted[i]
+		master, err := set.Master()
+		if err != nil || master.Host() != host {
+			continue
+		}
+
+		if !master.LastCheckValid {
+			unmovable = append(unmovable, set.UUID)
+			continue
+		}
+
+		found := false
+		var (
+			best      vshard.Instance
+			bestDelta int
+		)
+		for _, candidate := range rebalanceCandidates(*set, master) { //nolint:gocritic
+			if candidate.Host() == host {
+				continue
+			}
+
+			delta := p.moveCost(master, candidate)
+			if !found || delta < bestDelta || (delta == bestDelta && candidate.Priority > best.Priority) {
+				found, best, bestDelta = true, candidate, delta
+			}
+		}
+
+		if !found {
+			unmovable = append(unmovable, set.UUID)
+			continue
+		}
+
+		p.move(master, best)
+		switchovers = append(switchovers, PlannedSwitchover{
+			SetUUID:  set.UUID,
+			From:     master.UUID,
+			FromHost: master.Host(),
+			FromZone: master.Zone,
+			To:       best.UUID,
+			ToHost:   best.Host(),
+			ToZone:   best.Zone,
+		})
+	}
+
+	return switchovers, unmovable
+}
@@ -0,0 +1,48 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+func TestPlanDrain(t *testing.T) {
+	deadMaster := tRebalanceInstance("c1", "h1", "", 0)
+	deadMaster.LastCheckValid = false
+	drained := tRebalanceInstance("b3", "h3", "", 0)
+	drained.Drained = true
+
+	sets := []vshard.ReplicaSet{
+		tRebalanceSet("rs1", "a1",
+			tRebalanceInstance("a1", "h1", "", 0),
+			tRebalanceInstance("a2", "h2", "", 0),
+			tRebalanceInstance("a3", "h3", "", 0),
+		),
+		tRebalanceSet("rs2", "b1",
+			tRebalanceInstance("b1", "h1", "", 0),
+			tRebalanceInstance("b2", "h2", "", 0),
+			drained,
+		),
+		tRebalanceSet("rs3", "c1",
+			deadMaster,
+			tRebalanceInstance("c2", "h2", "", 0),
+		),
+		tRebalanceSet("rs4", "d1",
+			tRebalanceInstance("d1", "h1", "", 0),
+			tRebalanceInstance("d2", "h1", "", 0),
+		),
+		tRebalanceSet("rs5", "e2",
+			tRebalanceInstance("e1", "h1", "", 0),
+			tRebalanceInstance("e2", "h2", "", 0),
+		),
+	}
+
+	switchovers, unmovable := PlanDrain(sets, "h1")
+	assert.Equal(t, []PlannedSwitchover{
+		{SetUUID: "rs1", From: "a1", FromHost: "h1", To: "a3", ToHost: "h3"},
+		{SetUUID: "rs2", From: "b1", FromHost: "h1", To: "b2", ToHost: "h2"},
+	}, switchovers)
+	assert.Equal(t, []vshard.ReplicaSetUUID{"rs3", "rs4"}, unmovable)
+}
@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"sync"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+// setEvents tracks the states of the replica sets to fire
+// the lifecycle hooks once per problem occurrence.
+type setEvents struct {
+	mu     sync.Mutex
+	states map[vshard.ReplicaSetUUID]ReplicaSetState
+	fired  map[vshard.ReplicaSetUUID]map[HookType]bool
+}
+
+func newSetEvents() *setEvents {
+	return &setEvents{
+		states: make(map[vshard.ReplicaSetUUID]ReplicaSetState),
+		fired:  make(map[vshard.ReplicaSetUUID]map[HookType]bool),
+	}
+}
+
+// transit records the new state of the replica set.
+// If the set has got a new problem or the problem has been resolved,
+// it returns the hook type to fire along with the previous state.
+func (e *setEvents) transit(uuid vshard.ReplicaSetUUID, state ReplicaSetState) (t HookType, prev ReplicaSetState, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	prev, known := e.states[uuid]
+	if known && prev == state {
+		return "", prev, false
+	}
+	e.states[uuid] = state
+	delete(e.fired, uuid)
+
+	switch {
+	case state != NoProblem:
+		return HookProblemDetected, prev, true
+	case known:
+		return HookProblemResolved, prev, true
+	}
+
+	return "", prev, false
+}
+
+// once reports whether the hook has not been fired yet
+// for the current state of the replica set and marks it as fired.
+func (e *setEvents) once(uuid vshard.ReplicaSetUUID, t HookType) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	fired, ok := e.fired[uuid]
+	if !ok {
+		fired = make(map[HookType]bool)
+		e.fired[uuid] = fired
+	}
+	if fired[t] {
+		return false
+	}
+	fired[t] = true
+
+	return true
+}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetEvents_Transit(t *testing.T) {
+	events := newSetEvents()
+
+	tests := []struct {
+		name         string
+		state        ReplicaSetState
+		expectedOk   bool
+		expectedType HookType
+		expectedPrev ReplicaSetState
+	}{
+		{
+			name:       "FirstHealthy",
+			state:      NoProblem,
+			expectedOk: false,
+		},
+		{
+			name:         "Detected",
+			state:        DeadMaster,
+			expectedOk:   true,
+			expectedType: HookProblemDetected,
+			expectedPrev: NoProblem,
+		},
+		{
+			name:       "SameProblem",
+			state:      DeadMaster,
+			expectedOk: false,
+		},
+		{
+			name:         "AnotherProblem",
+			state:        DeadFollowers,
+			expectedOk:   true,
+			expectedType: HookProblemDetected,
+			expectedPrev: DeadMaster,
+		},
+		{
+			name:         "Resolved",
+			state:        NoProblem,
+			expectedOk:   true,
+			expectedType: HookProblemResolved,
+			expectedPrev: DeadFollowers,
+		},
+		{
+			name:       "StillHealthy",
+			state:      NoProblem,
+			expectedOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		got, prev, ok := events.transit("set_1", tt.state)
+		assert.Equal(t, tt.expectedOk, ok, tt.name)
+		if tt.expectedOk {
+			assert.Equal(t, tt.expectedType, got, tt.name)
+			assert.Equal(t, tt.expectedPrev, prev, tt.name)
+		}
+	}
+
+	// The first known state of the set might be a problem.
+	got, _, ok := events.transit("set_2", DeadMaster)
+	assert.True(t, ok)
+	assert.Equal(t, HookProblemDetected, got)
+}
+
+func TestSetEvents_Once(t *testing.T) {
+	events := newSetEvents()
+
+	events.transit("set_1", DeadMaster)
+	assert.True(t, events.once("set_1", HookRecoveryBlocked))
+	assert.False(t, events.once("set_1", HookRecoveryBlocked))
+	assert.True(t, events.once("set_1", HookReadOnlySkipped))
+	assert.True(t, events.once("set_2", HookRecoveryBlocked))
+
+	// The hooks are fired again after the state change.
+	events.transit("set_1", NoProblem)
+	events.transit("set_1", DeadMaster)
+	assert.True(t, events.once("set_1", HookRecoveryBlocked))
+}
@@ -64,6 +64,11 @@ type Failover interface {
 	Serve(stream AnalysisReadStream)
 	Shutdown()
 	SetOnClusterRecovered(func(Recovery))
+	// SetOnAnalysis sets the callback receiving each analysis of the replica sets.
+	SetOnAnalysis(func(ReplicationAnalysis))
+	// Switchover gracefully moves the master role
+	// of the replica set to the given follower.
+	Switchover(ctx context.Context, set vshard.ReplicaSetUUID, candidate vshard.InstanceUUID) (*Recovery, error)
 }
 
 type failover struct {
@@ -76,11 +81,39 @@ type failover struct {
 	recvSetTTL      time.Duration
 	recvInstanceTTL time.Duration
 
+	// recoveryTimeout is a deadline of each recovery.
+	recoveryTimeout time.Duration
+
+	// maxCandidateAttempts limits the number of ranked
+	// candidates checked before promotion.
+	maxCandidateAttempts int
+
+	// maxConcurrentRecoveries limits the number of replica sets
+	// being recovered at the same time.
+	maxConcurrentRecoveries int
+	// queue contains the analyses waiting for a free recovery slot.
+	queue *recoveryQueue
+	// configSync serializes updates of vshard configuration on the cluster nodes.
+	// The recovery script modifies the current configuration read on the node,
+	// so concurrent updates might overwrite each other.
+	configSync sync.Mutex
+	// discoverySync serializes the forced discoveries run after the recoveries and switchovers.
+	discoverySync sync.Mutex
+	// recoveriesWG tracks the running recoveries, so shutdown waits for them.
+	recoveriesWG sync.WaitGroup
+	// lastEvent is closed when the hooks of the last dispatched lifecycle event are done.
+	lastEvent  chan struct{}
+	eventsSync sync.Mutex
+	// eventsWG tracks the running lifecycle hooks, so shutdown waits for them.
+	eventsWG sync.WaitGroup
+
 	stop   chan struct{}
 	logger zerolog.Logger
 
 	onClusterRecoveredCB func(Recovery)
+	onAnalysisCB         func(ReplicationAnalysis)
 	sampler              sampler
+	events               *setEvents
 }
 
 func NewDefaultFailover(cluster *vshard.Cluster, cfg FailoverConfig, logger zerolog.Logger) Failover {
@@ -93,11 +126,17 @@ func NewDefaultFailover(cluster *vshard.Cluster, cfg FailoverConfig, logger zero
 		recvInstanceTTL: cfg.InstanceRecoveryBlockTime,
 		stop:            make(chan struct{}, 1),
 		logger:          logger,
+
+		recoveryTimeout:         cfg.RecoveryTimeout,
+		maxCandidateAttempts:    cfg.MaxCandidateAttempts,
+		maxConcurrentRecoveries: cfg.MaxConcurrentRecoveries,
+		queue:                   newRecoveryQueue(),
 		sampler: sampler{
 			fingerprints: map[string]string{},
 			enabled:      true,
 			mu:           &sync.RWMutex{},
 		},
+		events: newSetEvents(),
 	}
 }
 
@@ -105,6 +144,10 @@ func (f *failover) SetOnClusterRecovered(onClusterRecovered func(Recovery)) {
 	f.onClusterRecoveredCB = onClusterRecovered
 }
 
+func (f *failover) SetOnAnalysis(onAnalysis func(ReplicationAnalysis)) {
+	f.onAnalysisCB = onAnalysis
+}
+
 func (f *failover) Serve(stream AnalysisReadStream) {
 	ctx := context.Background()
 
@@ -119,7 +162,11 @@ func (f *failover) Serve(stream AnalysisReadStream) {
 			case <-cleanupTick.C:
 				f.cleanup(false)
 			case analysis := <-stream:
-				if f.shouldBeAnalysisChecked() {
+				f.trackState(analysis)
+				if f.onAnalysisCB != nil {
+					f.onAnalysisCB(*analysis)
+				}
+				if f.shouldBeAnalysisChecked(analysis) {
 					f.checkAndRecover(ctx, analysis)
 				}
 			}
@@ -129,21 +176,90 @@ func (f *failover) Serve(stream AnalysisReadStream) {
 
 func (f *failover) Shutdown() {
 	f.stop <- struct{}{}
+	f.recoveriesWG.Wait()
+	f.eventsWG.Wait()
 }
 
-func (f *failover) shouldBeAnalysisChecked() bool {
+func (f *failover) shouldBeAnalysisChecked(analysis *ReplicationAnalysis) bool {
 	if f.cluster.ReadOnly() {
 		f.logger.Info().Msgf("Readonly cluster: skip check and recovery step for all shards")
+		if recvFunc, _ := f.getCheckAndRecoveryFunc(analysis.State); recvFunc != nil {
+			f.notifyOnce(HookReadOnlySkipped, analysis)
+		}
 		return false
 	}
-	if f.cluster.HasActiveRecovery() {
-		f.logger.Info().Msgf("Cluster has active recovery: skip check and recovery step for all shards")
+	if f.cluster.HasActiveRecovery(analysis.Set.UUID) {
+		f.logger.Info().
+			Str("replica_set", string(analysis.Set.UUID)).
+			Msg("Replica set has active recovery: skip check and recovery step for the shard")
 		return false
 	}
 	return true
 }
 
-func (f *failover) checkAndRecover(ctx context.Context, analysis *ReplicationAnalysis) {
+// trackState runs the hooks if the replica set has got
+// a new problem or the problem has been resolved.
+func (f *failover) trackState(analysis *ReplicationAnalysis) {
+	t, prev, ok := f.events.transit(analysis.Set.UUID, analysis.State)
+	if !ok {
+		return
+	}
+
+	recv := f.newEventRecovery(analysis)
+	if t == HookProblemResolved {
+		// Let the hooks know which problem has been resolved.
+		recv.Type = string(prev)
+	}
+	f.dispatchEvent(t, recv)
+}
+
+// notifyOnce runs the hooks of the given type if they have not been run yet
+// for the current state of the replica set. Failures of the hooks are ignored.
+func (f *failover) notifyOnce(t HookType, analysis *ReplicationAnalysis) {
+	if !f.events.once(analysis.Set.UUID, t) {
+		return
+	}
+
+	f.dispatchEvent(t, f.newEventRecovery(analysis))
+}
+
+// dispatchEvent runs the lifecycle hooks in background, so slow hooks
+// never delay the analyses and recoveries. The hooks of the events
+// run one at a time in the order the events are dispatched.
+func (f *failover) dispatchEvent(t HookType, recv *Recovery) {
+	f.eventsSync.Lock()
+	prev := f.lastEvent
+	done := make(chan struct{})
+	f.lastEvent = done
+	f.eventsSync.Unlock()
+
+	f.eventsWG.Add(1)
+	go func() {
+		defer f.eventsWG.Done()
+		defer close(done)
+
+		if prev != nil {
+			<-prev
+		}
+		_ = f.hooker.ExecuteProcesses(context.Background(), t, recv, false)
+	}()
+}
+
+// newEventRecovery returns the recovery describing the replica set state for
+// the lifecycle hooks. Such recoveries are not registered.
+func (f *failover) newEventRecovery(analysis *ReplicationAnalysis) *Recovery {
+	master, _ := analysis.Set.Master()
+	recv := NewRecovery(RecoveryScopeSet, master.Ident(), *analysis)
+	recv.ClusterName = f.cluster.Name
+	recv.EndTimestamp = recv.StartTimestamp
+
+	return recv
+}
+
+// checkAndRecover starts the recovery of the replica set or queues it
+// if the limit of concurrent recoveries is reached.
+// Returns false if the replica set has nothing to recover.
+func (f *failover) checkAndRecover(ctx context.Context, analysis *ReplicationAnalysis) bool {
 	logger := f.logger.With().
 		Str("replica_set", string(analysis.Set.UUID)).
 		Str("master_uri", analysis.Set.MasterURI).
@@ -153,40 +269,132 @@ func (f *failover) checkAndRecover(ctx context.Context, analysis *ReplicationAna
 
 	recvFunc, desc := f.getCheckAndRecoveryFunc(analysis.State)
 	if recvFunc == nil {
+		// The replica set has nothing to recover anymore.
+		f.queue.remove(analysis.Set.UUID)
+
 		if desc != "" {
 			logger.Warn().
 				Strs("dead_followers", analysis.DeadFollowers).
 				Msg(desc)
 		}
-		return
+		return false
+	}
+
+	if !f.cluster.StartRecovery(analysis.Set.UUID, f.maxConcurrentRecoveries) {
+		f.queue.push(analysis)
+		logger.Warn().Msgf("Cluster has reached the limit of %d concurrent recoveries: the recovery is queued", f.maxConcurrentRecoveries)
+		return true
 	}
+	f.queue.remove(analysis.Set.UUID)
+
+	f.recoveriesWG.Add(1)
+	go func() {
+		defer f.recoveriesWG.Done()
+		f.recover(ctx, analysis, recvFunc, desc, logger)
+	}()
+
+	return true
+}
 
-	f.cluster.StartRecovery()
+// recover applies the recovery function to the replica set
+// and then runs the next queued recovery if any.
+func (f *failover) recover(ctx context.Context, analysis *ReplicationAnalysis, recvFunc RecoveryFunc, desc string, logger zerolog.Logger) {
 	logger.Warn().
 		Strs("dead_followers", analysis.DeadFollowers).
 		Msg(desc)
 	logger.Info().Msgf("Cluster snapshot before recovery: %s", f.cluster.Dump())
-	recoveries := recvFunc(ctx, analysis)
+
+	recvCtx, cancel := f.withRecoveryDeadline(ctx)
+	recoveries := recvFunc(recvCtx, analysis)
+	cancel()
+
+	timedOut := false
 	for _, recv := range recoveries {
-		if f.onClusterRecoveredCB != nil {
-			go f.onClusterRecoveredCB(*recv)
-		}
 		f.registryRecovery(recv)
 
 		if recv.IsSuccessful {
-			_ = f.hooker.ExecuteProcesses(HookPostSuccessfulFailover, recv, false)
+			_ = f.executeHooks(ctx, HookPostSuccessfulFailover, recv, false)
 		} else {
-			_ = f.hooker.ExecuteProcesses(HookPostUnsuccessfulFailover, recv, false)
+			_ = f.executeHooks(ctx, HookPostUnsuccessfulFailover, recv, false)
 		}
 
-		logger.Info().Msgf("Finished recovery: %s", recv)
+		timedOut = timedOut || recv.TimedOut
 	}
-	if len(recoveries) > 0 {
+	if len(recoveries) > 0 && !timedOut {
 		logger.Info().Msg("Run a force discovery after applied recoveries")
+		f.discoverySync.Lock()
 		f.cluster.Discover()
 		logger.Info().Msgf("Cluster snapshot after recovery: %s", f.cluster.Dump())
+		f.discoverySync.Unlock()
+		for _, recv := range recoveries {
+			recv.AddStep(StepForcedDiscovery, "")
+		}
 	}
-	f.cluster.StopRecovery()
+	for _, recv := range recoveries {
+		if f.onClusterRecoveredCB != nil {
+			go f.onClusterRecoveredCB(*recv)
+		}
+
+		logger.Info().Msgf("Finished recovery: %s", recv)
+	}
+	f.cluster.StopRecovery(analysis.Set.UUID)
+
+	f.runQueued(ctx)
+}
+
+// runQueued pops the queued replica sets until one of them is scheduled
+// for the recovery or the queue is empty. The queued analyses might be stale,
+// so each replica set is analyzed again using the current cluster snapshot.
+func (f *failover) runQueued(ctx context.Context) {
+	for {
+		next := f.queue.pop()
+		if next == nil {
+			return
+		}
+
+		analysis := f.reanalyze(next.Set.UUID)
+		if analysis == nil || !f.shouldBeAnalysisChecked(analysis) {
+			continue
+		}
+		if f.checkAndRecover(ctx, analysis) {
+			return
+		}
+	}
+}
+
+// reanalyze returns the analysis of the replica set in the current cluster snapshot.
+// Returns nil if the replica set is not found.
+func (f *failover) reanalyze(uuid vshard.ReplicaSetUUID) *ReplicationAnalysis {
+	set, err := f.cluster.ReplicaSet(uuid)
+	if err != nil {
+		f.logger.Warn().Err(err).Str("replica_set", string(uuid)).Msg("Queued replica set is not found in the cluster snapshot: skip recovery")
+		return nil
+	}
+
+	return analyze(set, f.logger.With().Str("replica_set", string(uuid)).Logger())
+}
+
+// withRecoveryDeadline returns a context which is canceled
+// when the recovery deadline is exceeded.
+func (f *failover) withRecoveryDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.recoveryTimeout > 0 {
+		return context.WithTimeout(ctx, f.recoveryTimeout)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// executeHooks runs the hooks of the given type and records it in the recovery timeline.
+func (f *failover) executeHooks(ctx context.Context, t HookType, recv *Recovery, failOnError bool) error {
+	recv.AddStep(StepHooksStarted, string(t))
+	err := f.hooker.ExecuteProcesses(ctx, t, recv, failOnError)
+	if err != nil {
+		recv.AddStep(StepHooksFinished, fmt.Sprintf("%s: %s", t, err))
+	} else {
+		recv.AddStep(StepHooksFinished, string(t))
+	}
+
+	return err
 }
 
 func (f *failover) getCheckAndRecoveryFunc(state ReplicaSetState) (rf RecoveryFunc, desc string) {
@@ -227,6 +435,7 @@ func (f *failover) promoteFollowerToMaster(ctx context.Context, analysis *Replic
 
 	if f.hasBlockedRecovery(string(badSet.UUID)) {
 		logger.Warn().Msg("ReplicaSet has been recovered recently so new failover is blocked")
+		f.notifyOnce(HookRecoveryBlocked, analysis)
 		return nil
 	}
 
@@ -238,54 +447,91 @@ func (f *failover) promoteFollowerToMaster(ctx context.Context, analysis *Replic
 		recv.EndTimestamp = util.Timestamp()
 	}()
 
-	err := f.hooker.ExecuteProcesses(HookPreFailover, recv, true)
+	err := f.executeHooks(ctx, HookPreFailover, recv, true)
 	if err != nil {
 		return []*Recovery{recv}
 	}
+	if recv.CheckDeadline(ctx) {
+		return []*Recovery{recv}
+	}
 
-	candidateUUID, err := f.elector.ChooseMaster(badSet)
+	decision, err := f.elector.ChooseMaster(badSet)
+	recv.Election = decision
 	if err != nil {
+		recv.AddStep(StepMasterElected, err.Error())
 		logger.Err(err).Msg("Failed to elect a new master")
 		return []*Recovery{recv}
 	}
+	recv.AddStep(StepMasterElected, string(decision.Winner))
 
-	candidate, _ := f.cluster.Instance(candidateUUID)
-	recv.Successor = candidate.Ident()
-	if ok, reason := f.shouldPromoteFollower(candidate); !ok {
-		logger.Warn().Msgf("Promotion of the chosen candidate is too complex. The recovery is interrupted. Reason: %s", reason)
+	candidate, ok := f.chooseCandidate(ctx, recv, decision, failed)
+	if !ok {
+		logger.Warn().Msg("None of the elected candidates might be promoted. The recovery is interrupted")
 		return []*Recovery{recv}
 	}
+	candidateUUID := candidate.UUID
+	recv.Successor = candidate.Ident()
 
 	logger.Info().Str("uuid", string(candidateUUID)).Str("uri", candidate.URI).
 		Msg("New master is elected. Going to update cluster configuration")
 
-	recvQuery := buildRecoveryQuery(badSet.UUID, candidateUUID)
+	f.configSync.Lock()
+	defer f.configSync.Unlock()
+
+	if recv.CheckDeadline(ctx) {
+		return []*Recovery{recv}
+	}
+
+	if !f.applyMasterConfig(ctx, recv, badSet.UUID, candidate, logger) {
+		return []*Recovery{recv}
+	}
+
+	recv.IsSuccessful = true
+	return []*Recovery{recv}
+}
+
+// applyMasterConfig pushes the vshard configuration with the new master of the replica set
+// to the new master, routers and then other cluster nodes.
+// Returns false if the configuration was not applied to the new master
+// or the recovery deadline is exceeded. Caller must hold configSync.
+func (f *failover) applyMasterConfig(ctx context.Context, recv *Recovery, setUUID vshard.ReplicaSetUUID, candidate vshard.Instance, logger zerolog.Logger) bool {
+	candidateUUID := candidate.UUID
+	recvQuery := buildRecoveryQuery(setUUID, candidateUUID)
 
 	// First priority is updating the configuration of the new master.
 	// If any error, exit from the recovery.
 	conn := f.cluster.Connector(candidate.URI)
 	resp := conn.Exec(ctx, recvQuery)
 	if resp.Error == nil {
+		recv.AddStep(StepCandidateConfigApplied, "")
 		logger.Info().
 			Str("URI", candidate.URI).
 			Str("UUID", string(candidateUUID)).
 			Msg("Configuration of the chosen master was updated")
 	} else {
+		recv.AddStep(StepCandidateConfigApplied, resp.Error.Error())
 		logger.Err(resp.Error).
 			Str("URI", candidate.URI).
 			Str("UUID", string(candidateUUID)).
 			Msg("Recovery fatal error: failed to update the configuration of the chosen master")
 
-		return []*Recovery{recv}
+		recv.CheckDeadline(ctx)
+		return false
 	}
 
 	// Update routers configuration to accept write requests as quickly as possible.
 	routers := f.cluster.Routers()
+	updated := 0
 	for i := range routers {
+		if recv.CheckDeadline(ctx) {
+			return false
+		}
+
 		r := &routers[i]
 		conn := f.cluster.Connector(r.URI)
 		resp := conn.Exec(ctx, recvQuery)
 		if resp.Error == nil {
+			updated++
 			logger.Info().
 				Str("URI", r.URI).
 				Msg("Configuration was updated on router")
@@ -295,11 +541,13 @@ func (f *failover) promoteFollowerToMaster(ctx context.Context, analysis *Replic
 				Msg("Failed to update configuration on router")
 		}
 	}
+	recv.AddStep(StepRoutersUpdated, fmt.Sprintf("updated %d of %d routers", updated, len(routers)))
 
 	instances := f.cluster.Instances()
 	sort.Sort(NewInstanceFailoverSorter(instances))
 
 	// Update the configuration of all the cluster members.
+	updated = 0
 	for i := range instances {
 		inst := &instances[i]
 
@@ -307,9 +555,14 @@ func (f *failover) promoteFollowerToMaster(ctx context.Context, analysis *Replic
 			continue
 		}
 
+		if recv.CheckDeadline(ctx) {
+			return false
+		}
+
 		conn := f.cluster.Connector(inst.URI)
 		resp := conn.Exec(ctx, recvQuery)
 		if resp.Error == nil {
+			updated++
 			logger.Info().
 				Str("URI", inst.URI).
 				Str("UUID", string(inst.UUID)).
@@ -321,9 +574,95 @@ func (f *failover) promoteFollowerToMaster(ctx context.Context, analysis *Replic
 				Msg("Failed to update configuration on node")
 		}
 	}
+	recv.AddStep(StepNodesUpdated, fmt.Sprintf("updated %d of %d nodes", updated, len(instances)-1))
 
-	recv.IsSuccessful = true
-	return []*Recovery{recv}
+	return true
+}
+
+// chooseCandidate walks through the ranked candidates of the election decision
+// and returns the first one which passes the promotion checks.
+// The number of checked candidates is limited by maxCandidateAttempts.
+func (f *failover) chooseCandidate(ctx context.Context, recv *Recovery, decision *quorum.Decision, failed vshard.Instance) (vshard.Instance, bool) {
+	logger := f.logger.With().Str("replica_set", string(recv.SetUUID)).Logger()
+
+	attempts := f.maxCandidateAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	for i := range decision.Candidates {
+		c := &decision.Candidates[i]
+		if !c.Eligible || attempts == 0 {
+			break
+		}
+		attempts--
+
+		if recv.CheckDeadline(ctx) {
+			return vshard.Instance{}, false
+		}
+
+		candidate, err := f.cluster.Instance(c.UUID)
+		if err != nil {
+			recv.AddStep(StepCandidateRejected, fmt.Sprintf("%s: %s", c.UUID, err))
+			continue
+		}
+
+		ok, reason := f.shouldPromoteFollower(candidate)
+		if ok {
+			ok, reason = f.preflightCheck(ctx, candidate, failed)
+		}
+		if !ok {
+			recv.AddStep(StepCandidateRejected, fmt.Sprintf("%s: %s", c.UUID, reason))
+			logger.Warn().
+				Str("URI", candidate.URI).
+				Str("UUID", string(candidate.UUID)).
+				Msgf("Candidate might not be promoted, trying the next one. Reason: %s", reason)
+			continue
+		}
+
+		recv.AddStep(StepCandidateChecked, string(c.UUID))
+		return candidate, true
+	}
+
+	return vshard.Instance{}, false
+}
+
+// preflightCheck reads the live state of the candidate right before
+// promotion because the snapshot might be outdated.
+func (f *failover) preflightCheck(ctx context.Context, candidate, failed vshard.Instance) (ok bool, reason string) {
+	state, err := f.cluster.InstanceState(ctx, candidate)
+	if err != nil {
+		return false, fmt.Sprintf("failed to read the candidate state: %s", err)
+	}
+
+	return checkCandidateState(state, candidate, failed)
+}
+
+// checkCandidateState compares the live state of the candidate with its snapshot.
+func checkCandidateState(state vshard.InstanceState, candidate, failed vshard.Instance) (ok bool, reason string) {
+	if state.Status != "running" {
+		return false, fmt.Sprintf("candidate has status %s instead of running", state.Status)
+	}
+
+	if !state.ReadOnly {
+		return false, "candidate is already writable: it might have been promoted by someone else"
+	}
+
+	if failed.ID == 0 {
+		return true, ""
+	}
+
+	if state.Upstreams[failed.ID] == vshard.UpstreamStopped {
+		return false, "candidate replication from the failed master was stopped due to an error"
+	}
+
+	// The candidate must not lose the data it had when the snapshot was taken.
+	expectedLSN := failed.LSN - candidate.LSNBehindMaster
+	if lsn := state.VClock[failed.ID]; lsn < expectedLSN {
+		return false, fmt.Sprintf("candidate has fallen behind: vclock LSN of the failed master is %d, expected at least %d", lsn, expectedLSN)
+	}
+
+	return true, ""
 }
 
 // shouldPromoteFollower performs some checks of the chosen candidate to ensure
@@ -365,6 +704,7 @@ func (f *failover) applyFollowerRoleToCoMasters(ctx context.Context, analysis *R
 				Str("URI", inst.URI).
 				Str("UUID", string(inst.UUID)).
 				Msg("Instance has been recovered recently so new failover is blocked")
+			f.notifyOnce(HookRecoveryBlocked, analysis)
 
 			continue
 		}
@@ -374,23 +714,41 @@ func (f *failover) applyFollowerRoleToCoMasters(ctx context.Context, analysis *R
 		recv.ClusterName = f.cluster.Name
 		recv.Successor = inst.Ident()
 
-		err := f.hooker.ExecuteProcesses(HookPreFailover, recv, true)
-		if err != nil {
+		// The skipped co-masters are reported as timed out recoveries,
+		// so the caller does not consider the replica set recovered.
+		if recv.CheckDeadline(ctx) {
+			logger.Warn().
+				Str("URI", inst.URI).
+				Str("UUID", string(inst.UUID)).
+				Msg("Recovery deadline is exceeded: the co-master is skipped")
+			recv.EndTimestamp = util.Timestamp()
+			recoveries = append(recoveries, recv)
+
+			continue
+		}
+
+		err := f.executeHooks(ctx, HookPreFailover, recv, true)
+		if err != nil || recv.CheckDeadline(ctx) {
 			recv.EndTimestamp = util.Timestamp()
 			recoveries = append(recoveries, recv)
 
 			continue
 		}
 
+		f.configSync.Lock()
 		conn := f.cluster.Connector(inst.URI)
 		resp := conn.Exec(ctx, recvQuery)
+		f.configSync.Unlock()
 		if resp.Error == nil {
+			recv.AddStep(StepNodeConfigApplied, "")
 			logger.Info().
 				Str("URI", inst.URI).
 				Str("UUID", string(inst.UUID)).
 				Msg("Configuration was updated on node")
 			recv.IsSuccessful = true
 		} else {
+			recv.AddStep(StepNodeConfigApplied, resp.Error.Error())
+			recv.CheckDeadline(ctx)
 			logger.Err(resp.Error).
 				Str("URI", inst.URI).
 				Str("UUID", string(inst.UUID)).
@@ -1,6 +1,10 @@
 package orchestrator
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -36,6 +40,28 @@ var (
 				ReasonableFollowerIdle:   1,
 			},
 		},
+		{
+			name: "PriorityElector",
+			mode: quorum.ModePriority,
+			opts: quorum.Options{
+				ReasonableFollowerLSNLag: 10,
+				ReasonableFollowerIdle:   1,
+			},
+		},
+		{
+			name: "WeightedElector",
+			mode: quorum.ModeWeighted,
+			opts: quorum.Options{
+				ReasonableFollowerLSNLag: 10,
+				ReasonableFollowerIdle:   1,
+				Weights: quorum.Weights{
+					LSNLag:         1,
+					Idle:           1,
+					UpstreamStatus: 1,
+					Fingerprint:    1,
+				},
+			},
+		},
 	}
 )
 
@@ -121,6 +147,8 @@ func (s *failoverTestSuite) Test_failover_promoteFollowerToMaster() {
 			require.Nil(t, err)
 
 			assert.Equal(t, recv.Successor.UUID, recvSet.MasterUUID)
+			require.NotNil(t, recv.Election)
+			assert.Equal(t, recv.Successor.UUID, recv.Election.Winner)
 
 			master, err := recvSet.Master()
 			require.Nil(t, err)
@@ -228,3 +256,130 @@ func (s *failoverTestSuite) Test_failover_applyFollowerRoleToCoMasters() {
 func TestFailover(t *testing.T) {
 	suite.Run(t, newFailoverTestSuite())
 }
+
+func Test_checkCandidateState(t *testing.T) {
+	failed := vshard.Instance{ID: 1, LSN: 100}
+	candidate := vshard.Instance{ID: 2, LSNBehindMaster: 10}
+
+	tests := []struct {
+		name   string
+		modify func(state *vshard.InstanceState)
+		wantOk bool
+	}{
+		{
+			name:   "Healthy",
+			modify: func(state *vshard.InstanceState) {},
+			wantOk: true,
+		},
+		{
+			name: "NotRunning",
+			modify: func(state *vshard.InstanceState) {
+				state.Status = "orphan"
+			},
+		},
+		{
+			name: "Writable",
+			modify: func(state *vshard.InstanceState) {
+				state.ReadOnly = false
+			},
+		},
+		{
+			name: "ReplicationStopped",
+			modify: func(state *vshard.InstanceState) {
+				state.Upstreams[1] = vshard.UpstreamStopped
+			},
+		},
+		{
+			name: "FallenBehind",
+			modify: func(state *vshard.InstanceState) {
+				state.VClock[1] = 50
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			state := vshard.InstanceState{
+				Status:    "running",
+				ReadOnly:  true,
+				VClock:    map[uint64]int64{1: 90},
+				Upstreams: map[uint64]vshard.UpstreamStatus{1: vshard.UpstreamDisconnected},
+			}
+			tt.modify(&state)
+
+			ok, reason := checkCandidateState(state, candidate, failed)
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Empty(t, reason)
+			} else {
+				assert.NotEmpty(t, reason)
+			}
+		})
+	}
+}
+
+func Test_failover_applyFollowerRoleToCoMasters_deadline(t *testing.T) {
+	f := NewDefaultFailover(vshard.MockCluster(), FailoverConfig{}, zerolog.Nop()).(*failover)
+
+	analysis := &ReplicationAnalysis{
+		Set: vshard.ReplicaSet{
+			UUID:       "set_1",
+			MasterUUID: "master",
+			Instances: []vshard.Instance{
+				{UUID: "master", VShardFingerprint: 1},
+				{UUID: "co_master_1", VShardFingerprint: 2},
+				{UUID: "co_master_2", VShardFingerprint: 3},
+			},
+		},
+		State: MasterMasterReplication,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	recoveries := f.applyFollowerRoleToCoMasters(ctx, analysis)
+	require.Len(t, recoveries, 2)
+	for _, recv := range recoveries {
+		assert.False(t, recv.IsSuccessful)
+		assert.True(t, recv.TimedOut)
+	}
+	assert.Equal(t, "co_master_1", recoveries[0].ScopeKey())
+	assert.Equal(t, "co_master_2", recoveries[1].ScopeKey())
+}
+
+func Test_failover_trackState(t *testing.T) {
+	filename := genUniqueFilename(os.TempDir(), "qumomf-hook-test")
+	require.NotEmpty(t, filename)
+	defer func() {
+		_ = os.Remove(filename)
+	}()
+
+	hooker := NewBashHooker(zerolog.Nop())
+	hooker.AddHook(HookProblemDetected, fmt.Sprintf("sleep 0.5; echo detected >> %s", filename))
+	hooker.AddHook(HookProblemResolved, fmt.Sprintf("echo resolved >> %s", filename))
+	f := NewDefaultFailover(vshard.MockCluster(), FailoverConfig{Hooker: hooker}, zerolog.Nop()).(*failover)
+
+	analysis := &ReplicationAnalysis{
+		Set: vshard.ReplicaSet{
+			UUID:       "set_1",
+			MasterUUID: "master",
+			Instances:  []vshard.Instance{{UUID: "master"}},
+		},
+		State: NoProblem,
+	}
+
+	// The lifecycle hooks must not delay the analysis loop.
+	start := time.Now()
+	for _, state := range []ReplicaSetState{NoProblem, DeadMaster, NoProblem} {
+		analysis.State = state
+		f.trackState(analysis)
+	}
+	assert.Less(t, int64(time.Since(start)), int64(300*time.Millisecond))
+
+	// The hooks run in the order of the events.
+	f.eventsWG.Wait()
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "detected\nresolved\n", string(data))
+}
@@ -1,15 +1,25 @@
 package orchestrator
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/rs/zerolog"
+
+	"github.com/shmel1k/qumomf/internal/util"
+	"github.com/shmel1k/qumomf/internal/vshard"
 )
 
 type HookType string
@@ -18,24 +28,158 @@ const (
 	HookPreFailover              HookType = "PreFailover"
 	HookPostSuccessfulFailover   HookType = "PostSuccessfulFailover"
 	HookPostUnsuccessfulFailover HookType = "PostUnsuccessfulFailover"
+
+	// HookProblemDetected is executed when a replica set gets a new problem state.
+	HookProblemDetected HookType = "ProblemDetected"
+	// HookProblemResolved is executed when a replica set returns to NoProblem state.
+	HookProblemResolved HookType = "ProblemResolved"
+	// HookRecoveryBlocked is executed when a recovery is skipped
+	// because the replica set or instance has been recovered recently.
+	HookRecoveryBlocked HookType = "RecoveryBlocked"
+	// HookReadOnlySkipped is executed when a recovery is skipped
+	// because the cluster is in readonly mode.
+	HookReadOnlySkipped HookType = "ReadOnlySkipped"
+	// HookPreSwitchover is executed before the planned switchover.
+	HookPreSwitchover HookType = "PreSwitchover"
+	// HookPostSwitchover is executed at the end of the planned switchover.
+	HookPostSwitchover HookType = "PostSwitchover"
 )
 
 const (
 	ShellBash = "bash"
 )
 
+const defaultHookRetryBackoff = time.Second
+
+// hookOutputLimit is a max number of bytes of each hook output stream
+// kept in the recovery.
+const hookOutputLimit = 4096
+
+// Hook is a shell command or a webhook executed through the recovery process.
+type Hook struct {
+	// Name is used in logs to identify the hook.
+	Name string
+	// Command is a shell command, the leading "&" makes it async.
+	// Either Command or Webhook must be set.
+	Command string
+	// Template indicates whether Command is a text/template executed
+	// over the recovery instead of the placeholders replacement.
+	Template bool
+	// Webhook describes the HTTP endpoint the recovery data is posted to.
+	Webhook *Webhook
+	// Timeout is a deadline of a single attempt.
+	// Zero value means the timeout of the hooker is used.
+	Timeout time.Duration
+	// Async indicates whether the hook should not block the recovery.
+	Async bool
+	// Retries is a number of retries after the failed attempt.
+	Retries int
+	// RetryBackoff is a delay before the first retry,
+	// it is doubled before each next retry.
+	RetryBackoff time.Duration
+	// Env contains additional environment variables of the shell command.
+	Env map[string]string
+	// Filter defines the recoveries the hook is executed for.
+	Filter HookFilter
+
+	tmpl    *template.Template
+	tmplErr error
+}
+
+// HookFilter restricts the recoveries the hook is executed for.
+// Empty lists match any value.
+type HookFilter struct {
+	FailureTypes []string
+	Clusters     []string
+	ReplicaSets  []vshard.ReplicaSetUUID
+}
+
+// Match reports whether the recovery passes the filter.
+func (f HookFilter) Match(recv *Recovery) bool {
+	if !matchString(f.FailureTypes, recv.Type) || !matchString(f.Clusters, recv.ClusterName) {
+		return false
+	}
+
+	if len(f.ReplicaSets) == 0 {
+		return true
+	}
+	for _, uuid := range f.ReplicaSets {
+		if uuid == recv.SetUUID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchString(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HookExecution is a result of the hook executed through the recovery process.
+type HookExecution struct {
+	Type HookType
+	Name string
+	// Command is the shell command with replaced placeholders.
+	Command string
+	// URL is the webhook endpoint.
+	URL   string
+	Async bool
+	// Attempts is a number of the made attempts.
+	Attempts int
+	// ExitCode is an exit code of the last shell command attempt,
+	// -1 if the process was not started or was killed.
+	ExitCode int
+	// StatusCode is an HTTP status of the last webhook response.
+	StatusCode int
+	// Stdout contains the output of the last shell command attempt
+	// or the body of the last webhook response.
+	Stdout string
+	Stderr string
+	// Truncated indicates whether the output exceeded the limit.
+	Truncated bool
+	// Error is empty if the hook succeeded.
+	Error string
+	// StartTimestamp is a unix time in milliseconds when the hook was started.
+	StartTimestamp int64
+	// Duration is a total duration of all attempts in milliseconds.
+	Duration int64
+	// JobID is an ID of the queued job of the async hook.
+	JobID int64
+	// JobStatus is a status of the queued job. It is filled on reading the recovery,
+	// the result of the finished job replaces the result of the hook start.
+	JobStatus HookJobStatus
+}
+
+// hookAttempt makes a single attempt to execute the hook and writes the result to res.
+// It reports whether the failed attempt might be retried.
+type hookAttempt func(ctx context.Context, res *HookExecution) (retry bool, err error)
+
 type Hooker struct {
 	processesShellCommand string
-	processes             map[HookType][]string
+	hooks                 map[HookType][]Hook
+	client                *http.Client
 	timeout               time.Duration
 	timeoutAsync          time.Duration
-	logger                zerolog.Logger
+	// queue persists the async hooks, they run in background if it is not set.
+	queue  *HookQueue
+	logger zerolog.Logger
 }
 
 func NewHooker(shell string, logger zerolog.Logger) *Hooker {
 	return &Hooker{
 		processesShellCommand: shell,
-		processes:             make(map[HookType][]string),
+		hooks:                 make(map[HookType][]Hook),
+		client:                &http.Client{},
 		timeout:               2 * time.Second,
 		timeoutAsync:          10 * time.Minute,
 		logger:                logger,
@@ -56,53 +200,140 @@ func (h *Hooker) SetTimeoutAsync(t time.Duration) {
 	h.timeoutAsync = t
 }
 
+// SetQueue sets the queue the async hooks are executed through.
+func (h *Hooker) SetQueue(q *HookQueue) {
+	h.queue = q
+}
+
+// AddHook adds the shell commands executed on the given hook type.
 func (h *Hooker) AddHook(t HookType, commands ...string) {
-	hooks, ok := h.processes[t]
-	if !ok {
-		hooks = make([]string, 0, len(commands))
+	for _, command := range commands {
+		h.hooks[t] = append(h.hooks[t], Hook{
+			Command: command,
+		})
+	}
+}
+
+// AddHooks adds the hooks executed on the given hook type.
+// Templates of the hooks are parsed in advance, a hook with
+// the invalid template fails on each execution.
+func (h *Hooker) AddHooks(t HookType, hooks ...Hook) {
+	for _, hook := range hooks { //nolint:gocritic
+		if hook.Template {
+			hook.tmpl, hook.tmplErr = ParseHookTemplate(hook.Command)
+			if hook.tmplErr != nil {
+				h.logger.Err(hook.tmplErr).Msgf("Failed to parse template of %s hook %q", t, hook.Name)
+			}
+		}
+		h.hooks[t] = append(h.hooks[t], hook)
 	}
-	hooks = append(hooks, commands...)
-	h.processes[t] = hooks
 }
 
-// ExecuteProcesses executes a list of processes.
-func (h *Hooker) ExecuteProcesses(t HookType, recv *Recovery, failOnError bool) (err error) {
-	processes := h.processes[t]
-	if len(processes) == 0 {
+// Hooks returns a copy of the hooks of each type.
+func (h *Hooker) Hooks() map[HookType][]Hook {
+	dst := make(map[HookType][]Hook, len(h.hooks))
+	for t, hooks := range h.hooks {
+		dst[t] = append([]Hook(nil), hooks...)
+	}
+
+	return dst
+}
+
+// ExecuteProcesses executes the hooks matching the recovery in order of definition.
+// The context limits the execution of the sync hooks including their retries,
+// async hooks are not bound to it.
+func (h *Hooker) ExecuteProcesses(ctx context.Context, t HookType, recv *Recovery, failOnError bool) (err error) {
+	hooks := make([]Hook, 0, len(h.hooks[t]))
+	for _, hook := range h.hooks[t] { //nolint:gocritic
+		if hook.Filter.Match(recv) {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == 0 {
 		h.logger.Info().Msgf("No %s hooks to run", t)
 		return nil
 	}
 
-	h.logger.Info().Msgf("Running %d %s hooks", len(processes), t)
-	for i, process := range processes {
-		command, async := prepareCommand(process, recv)
-		env := applyEnvironmentVariables(recv)
+	h.logger.Info().Msgf("Running %d %s hooks", len(hooks), t)
+	env := applyEnvironmentVariables(recv)
 
-		fullDescription := fmt.Sprintf("%s hook %d of %d", t, i+1, len(processes))
-		if async {
-			fullDescription = fmt.Sprintf("%s (async)", fullDescription)
+	// The payload is prepared in advance because
+	// the recovery might be changed while async hooks are running.
+	payload, err := newHookPayload(t, recv)
+	if err != nil {
+		h.logger.Err(err).Msgf("Failed to marshal %s hooks payload", t)
+		return err
+	}
+
+	for i, hook := range hooks {
+		hook := hook
+		fullDescription := fmt.Sprintf("%s hook %d of %d", t, i+1, len(hooks))
+		if hook.Name != "" {
+			fullDescription = fmt.Sprintf("%s %q", fullDescription, hook.Name)
+		}
+
+		res := HookExecution{
+			Type:     t,
+			Name:     hook.Name,
+			Async:    hook.Async,
+			ExitCode: -1,
 		}
-		if async {
-			go func() {
-				ctx, cancel := context.WithTimeout(context.Background(), h.timeoutAsync)
-				// Ignore errors, it is async process.
-				_ = h.executeProcess(ctx, command, env, fullDescription)
-				cancel()
-			}()
+		var attempt hookAttempt
+		var prepareErr error
+		if hook.Webhook != nil {
+			res.URL = hook.Webhook.URL
+			h.logger.Info().Msgf("Running %s: POST %s", fullDescription, hook.Webhook.URL)
+			attempt = h.webhookAttempt(hook.Webhook, t, payload)
 		} else {
-			ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
-			cmdErr := h.executeProcess(ctx, command, env, fullDescription)
-			cancel()
-
-			if cmdErr != nil {
-				if failOnError {
-					h.logger.Warn().Msgf("Not running further %s hooks", t)
-					return cmdErr
+			var command string
+			var asyncCommand bool
+			command, asyncCommand, prepareErr = prepareHookCommand(&hook, t, recv)
+			res.Command = command
+			res.Async = res.Async || asyncCommand
+			h.logger.Info().Msgf("Running %s: %s", fullDescription, command)
+			attempt = h.processAttempt(command, appendEnv(env, hook.Env), payload)
+			if prepareErr != nil {
+				attempt = func(context.Context, *HookExecution) (bool, error) {
+					return false, prepareErr
 				}
-				if err == nil {
-					// Keep first error encountered.
-					err = cmdErr
+			}
+		}
+
+		if res.Async {
+			// The result of the async hook is not waited for,
+			// so only the fact of the start is kept in the recovery.
+			res.StartTimestamp = util.TimestampMs()
+			fullDescription = fmt.Sprintf("%s (async)", fullDescription)
+
+			if h.queue != nil && prepareErr == nil {
+				job, queueErr := h.queue.Enqueue(context.Background(), newHookJob(t, &hook, res.Command, payload))
+				if queueErr == nil {
+					h.logger.Info().Msgf("Queued %s as job %d", fullDescription, job.ID)
+					res.JobID = job.ID
+					recv.Hooks = append(recv.Hooks, res)
+					continue
 				}
+				h.logger.Err(queueErr).Msgf("Failed to queue %s, running it in background", fullDescription)
+			}
+
+			recv.Hooks = append(recv.Hooks, res)
+			go func(res HookExecution) {
+				// Ignore errors, it is async hook.
+				_ = h.executeHook(context.Background(), hook, h.timeoutAsync, attempt, &res, fullDescription)
+			}(res)
+			continue
+		}
+
+		hookErr := h.executeHook(ctx, hook, h.timeout, attempt, &res, fullDescription)
+		recv.Hooks = append(recv.Hooks, res)
+		if hookErr != nil {
+			if failOnError {
+				h.logger.Warn().Msgf("Not running further %s hooks", t)
+				return hookErr
+			}
+			if err == nil {
+				// Keep first error encountered.
+				err = hookErr
 			}
 		}
 	}
@@ -111,24 +342,244 @@ func (h *Hooker) ExecuteProcesses(t HookType, recv *Recovery, failOnError bool)
 	return err
 }
 
-func (h *Hooker) executeProcess(ctx context.Context, command string, env []string, fullDescription string) error {
-	// Log the command to be run and record how long it takes as this may be useful.
-	h.logger.Info().Msgf("Running %s: %s", fullDescription, command)
+// executeHook runs the attempts of the hook until the first success,
+// a non-retriable error or the retries are exhausted.
+// The result of the hook is written to res.
+//
+// The retries are stopped when the context is done,
+// the timeout of each attempt is derived from the context.
+func (h *Hooker) executeHook(ctx context.Context, hook Hook, defaultTimeout time.Duration, attempt hookAttempt, res *HookExecution, fullDescription string) error {
+	// Record how long it takes as this may be useful.
 	start := time.Now()
+	res.StartTimestamp = util.TimestampMs()
 
-	cmd := exec.CommandContext(ctx, h.processesShellCommand, "-c", command) //nolint:gosec
-	cmd.Env = env
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	backoff := hook.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultHookRetryBackoff
+	}
 
-	err := cmd.Run()
+	var err error
+	for i := 0; i <= hook.Retries; i++ {
+		if i > 0 {
+			h.logger.Warn().Msgf("Retrying %s in %v after error: %v", fullDescription, backoff, err)
+			if !sleepContext(ctx, backoff) {
+				err = fmt.Errorf("%v (retries are aborted: %v)", err, ctx.Err())
+				break
+			}
+			backoff *= 2
+		}
+
+		var retry bool
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		retry, err = attempt(attemptCtx, res)
+		cancel()
+		res.Attempts++
+		if err == nil || !retry {
+			break
+		}
+	}
+
+	res.Duration = time.Since(start).Milliseconds()
 	if err == nil {
 		h.logger.Info().Msgf("Completed %s in %v", fullDescription, time.Since(start))
 	} else {
+		res.Error = err.Error()
 		h.logger.Error().Msgf("Execution of %s failed in %v with error: %v", fullDescription, time.Since(start), err)
 	}
 
 	return err
 }
 
+// sleepContext pauses the current goroutine for the given duration.
+// Returns false if the context is done earlier.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (h *Hooker) webhookAttempt(hook *Webhook, t HookType, payload []byte) hookAttempt {
+	return func(ctx context.Context, res *HookExecution) (bool, error) {
+		return h.sendWebhook(ctx, hook, t, payload, res)
+	}
+}
+
+func (h *Hooker) processAttempt(command string, env []string, payload []byte) hookAttempt {
+	return func(ctx context.Context, res *HookExecution) (bool, error) {
+		return true, h.executeProcess(ctx, command, env, payload, res)
+	}
+}
+
+// executeJob makes a single attempt of the queued hook job.
+func (h *Hooker) executeJob(job *HookJob) (res HookExecution, retry bool, err error) {
+	res = HookExecution{
+		Type:     job.Type,
+		Name:     job.Name,
+		Command:  job.Command,
+		Async:    true,
+		ExitCode: -1,
+		JobID:    job.ID,
+	}
+
+	hook := h.resolveHook(job)
+	if hook == nil {
+		err = fmt.Errorf("hook of the job is not configured anymore")
+		res.Error = err.Error()
+		return res, false, err
+	}
+
+	var attempt hookAttempt
+	if hook.Webhook != nil {
+		res.URL = hook.Webhook.URL
+		attempt = h.webhookAttempt(hook.Webhook, job.Type, job.Payload)
+	} else {
+		var payload WebhookPayload
+		err = json.Unmarshal(job.Payload, &payload)
+		if err != nil || payload.Recovery == nil {
+			err = fmt.Errorf("invalid payload of the hook job: %v", err)
+			res.Error = err.Error()
+			return res, false, err
+		}
+		env := appendEnv(applyEnvironmentVariables(payload.Recovery), hook.Env)
+		attempt = h.processAttempt(job.Command, env, job.Payload)
+	}
+
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = h.timeoutAsync
+	}
+
+	start := time.Now()
+	res.StartTimestamp = util.TimestampMs()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	retry, err = attempt(ctx, &res)
+	cancel()
+	res.Duration = time.Since(start).Milliseconds()
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return res, retry, err
+}
+
+// resolveHook returns the configured hook of the job or nil if there is no such hook.
+func (h *Hooker) resolveHook(job *HookJob) *Hook {
+	hooks := h.hooks[job.Type]
+	for i := range hooks {
+		if hooks[i].ref() == job.HookRef {
+			return &hooks[i]
+		}
+	}
+
+	return nil
+}
+
+// ref returns the reference persisted in the queued jobs of the hook instead of its secrets.
+// The reference does not depend on the secrets, so they might be changed while the jobs are queued.
+func (hook *Hook) ref() string {
+	target := hook.Command
+	if hook.Webhook != nil {
+		target = hook.Webhook.URL
+	}
+	sum := sha256.Sum256([]byte(hook.Name + "\x00" + target))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// newHookJob returns the job of the async hook to be queued.
+func newHookJob(t HookType, hook *Hook, command string, payload []byte) HookJob {
+	job := HookJob{
+		Type:         t,
+		Name:         hook.Name,
+		Command:      command,
+		HookRef:      hook.ref(),
+		Payload:      payload,
+		Timeout:      hook.Timeout,
+		MaxAttempts:  hook.Retries + 1,
+		RetryBackoff: hook.RetryBackoff,
+	}
+	if hook.Webhook != nil {
+		job.URL = hook.Webhook.URL
+	}
+
+	return job
+}
+
+// executeProcess runs the shell command with the payload on stdin
+// and captures its bounded output and exit code.
+func (h *Hooker) executeProcess(ctx context.Context, command string, env []string, payload []byte, res *HookExecution) error {
+	stdout := newLimitedBuffer(hookOutputLimit)
+	stderr := newLimitedBuffer(hookOutputLimit)
+
+	cmd := exec.CommandContext(ctx, h.processesShellCommand, "-c", command) //nolint:gosec
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	res.Stdout = stdout.String()
+	res.Stderr = stderr.String()
+	res.Truncated = stdout.truncated || stderr.truncated
+	res.ExitCode = -1
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	return err
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func newLimitedBuffer(limit int) *limitedBuffer {
+	return &limitedBuffer{limit: limit}
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if free := b.limit - b.buf.Len(); n > free {
+		p = p[:free]
+		b.truncated = true
+	}
+	b.buf.Write(p)
+
+	// Pretend the whole data was written to not break the process.
+	return n, nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// prepareHookCommand returns the command of the shell hook ready to be executed.
+func prepareHookCommand(hook *Hook, t HookType, recv *Recovery) (command string, async bool, err error) {
+	if !hook.Template {
+		command, async = prepareCommand(hook.Command, recv)
+		return command, async, nil
+	}
+	if hook.tmplErr != nil {
+		return "", false, hook.tmplErr
+	}
+
+	command, err = executeHookTemplate(hook.tmpl, t, recv)
+	return command, false, err
+}
+
 // prepareCommand replaces agreed-upon placeholders with recovery data.
 func prepareCommand(command string, recv *Recovery) (result string, async bool) {
 	command = strings.TrimSpace(command)
@@ -181,3 +632,24 @@ func applyEnvironmentVariables(recv *Recovery) []string {
 
 	return env
 }
+
+// appendEnv returns a copy of env with additional variables.
+func appendEnv(env []string, vars map[string]string) []string {
+	if len(vars) == 0 {
+		return env
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	dst := make([]string, len(env), len(env)+len(vars))
+	copy(dst, env)
+	for _, k := range keys {
+		dst = append(dst, fmt.Sprintf("%s=%s", k, vars[k]))
+	}
+
+	return dst
+}
@@ -2,7 +2,10 @@ package orchestrator
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"strconv"
@@ -72,7 +75,7 @@ func (s *hookerTestSuite) Test_ExecuteProcesses() {
 
 	hooker.AddHook(HookPostSuccessfulFailover, fmt.Sprintf("rm -f %s", filename))
 
-	err := hooker.ExecuteProcesses(HookPreFailover, s.recv, true)
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
 	require.Nil(t, err)
 
 	f, err := os.Open(filename)
@@ -92,7 +95,7 @@ func (s *hookerTestSuite) Test_ExecuteProcesses() {
 
 	assert.Equal(t, env, foundEnv)
 
-	err = hooker.ExecuteProcesses(HookPostSuccessfulFailover, s.recv, false)
+	err = hooker.ExecuteProcesses(context.Background(), HookPostSuccessfulFailover, s.recv, false)
 	assert.Nil(t, err)
 }
 
@@ -103,7 +106,7 @@ func (s *hookerTestSuite) Test_ExecuteProcesses_Async() {
 
 	start := time.Now()
 	hooker.AddHook(HookPreFailover, "&sleep 3")
-	err := hooker.ExecuteProcesses(HookPreFailover, s.recv, true)
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
 	end := time.Now()
 	assert.Nil(t, err)
 	assert.WithinDuration(t, start, end, 1*time.Second)
@@ -161,7 +164,7 @@ func (s *hookerTestSuite) Test_ExecuteProcesses_CheckArguments() {
 	}
 	hooker.AddHook(HookPostSuccessfulFailover, fmt.Sprintf("rm -f %s", filename))
 
-	err := hooker.ExecuteProcesses(HookPreFailover, s.recv, true)
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
 	require.Nil(t, err)
 
 	f, err := os.Open(filename)
@@ -181,10 +184,229 @@ func (s *hookerTestSuite) Test_ExecuteProcesses_CheckArguments() {
 
 	assert.Equal(t, expectedArgs, foundArgs)
 
-	err = hooker.ExecuteProcesses(HookPostSuccessfulFailover, s.recv, false)
+	err = hooker.ExecuteProcesses(context.Background(), HookPostSuccessfulFailover, s.recv, false)
 	assert.Nil(t, err)
 }
 
+func (s *hookerTestSuite) Test_ExecuteProcesses_Structured() {
+	t := s.T()
+
+	hooker := NewBashHooker(s.logger)
+
+	filename := genUniqueFilename(os.TempDir(), "qumomf-hook-test")
+	require.NotEmpty(t, filename)
+	defer func() {
+		_ = os.Remove(filename)
+	}()
+
+	hooker.AddHooks(HookPreFailover,
+		Hook{
+			Name:    "env",
+			Command: fmt.Sprintf("echo \"$QUM_TEAM\" >> %s", filename),
+			Env:     map[string]string{"QUM_TEAM": "storage"},
+		},
+		Hook{
+			Name:    "matched",
+			Command: fmt.Sprintf("echo matched >> %s", filename),
+			Filter: HookFilter{
+				FailureTypes: []string{s.recv.Type},
+				Clusters:     []string{"sandbox", "production"},
+				ReplicaSets:  []vshard.ReplicaSetUUID{s.recv.SetUUID},
+			},
+		},
+		Hook{
+			Name:    "other_cluster",
+			Command: fmt.Sprintf("echo other_cluster >> %s", filename),
+			Filter: HookFilter{
+				Clusters: []string{"production"},
+			},
+		},
+		Hook{
+			Name:    "other_failure",
+			Command: "exit 1",
+			Filter: HookFilter{
+				FailureTypes: []string{"unknown"},
+			},
+		},
+	)
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	require.Nil(t, err)
+
+	data, err := ioutil.ReadFile(filename)
+	require.Nil(t, err)
+	assert.Equal(t, "storage\nmatched\n", string(data))
+}
+
+func (s *hookerTestSuite) Test_ExecuteProcesses_Retries() {
+	t := s.T()
+
+	hooker := NewBashHooker(s.logger)
+
+	filename := genUniqueFilename(os.TempDir(), "qumomf-hook-test")
+	require.NotEmpty(t, filename)
+	defer func() {
+		_ = os.Remove(filename)
+	}()
+
+	// The command fails until it is run the third time.
+	hooker.AddHooks(HookPreFailover, Hook{
+		Command:      fmt.Sprintf("echo 1 >> %s; test $(wc -l < %s) -ge 3", filename, filename),
+		Retries:      2,
+		RetryBackoff: 10 * time.Millisecond,
+	})
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	assert.Nil(t, err)
+
+	hooker = NewBashHooker(s.logger)
+	hooker.AddHooks(HookPreFailover, Hook{
+		Command:      "exit 1",
+		Retries:      1,
+		RetryBackoff: 10 * time.Millisecond,
+	})
+
+	err = hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	assert.NotNil(t, err)
+
+	// The retries are aborted when the context is done during the backoff.
+	hooker = NewBashHooker(s.logger)
+	hooker.AddHooks(HookPreFailover, Hook{
+		Command:      "exit 1",
+		Retries:      5,
+		RetryBackoff: 1 * time.Second,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = hooker.ExecuteProcesses(ctx, HookPreFailover, s.recv, true)
+	assert.NotNil(t, err)
+	assert.WithinDuration(t, start, time.Now(), 1*time.Second)
+}
+
+func (s *hookerTestSuite) Test_ExecuteProcesses_AsyncFlag() {
+	t := s.T()
+
+	hooker := NewBashHooker(s.logger)
+
+	start := time.Now()
+	hooker.AddHooks(HookPreFailover, Hook{
+		Command: "sleep 3",
+		Async:   true,
+	})
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, start, time.Now(), 1*time.Second)
+}
+
+func (s *hookerTestSuite) Test_ExecuteProcesses_CaptureOutput() {
+	t := s.T()
+
+	hooker := NewBashHooker(s.logger)
+	hooker.AddHooks(HookPreFailover, Hook{
+		Name:    "ok",
+		Command: "echo out; echo err >&2",
+	}, Hook{
+		Name:    "long",
+		Command: fmt.Sprintf("head -c %d /dev/zero", hookOutputLimit+1),
+	}, Hook{
+		Name:    "fail",
+		Command: "echo failed >&2; exit 3",
+	}, Hook{
+		Name:    "async",
+		Command: "true",
+		Async:   true,
+	})
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, false)
+	require.NotNil(t, err)
+	require.Len(t, s.recv.Hooks, 4)
+
+	ok := s.recv.Hooks[0]
+	assert.Equal(t, HookPreFailover, ok.Type)
+	assert.Equal(t, "ok", ok.Name)
+	assert.Equal(t, "echo out; echo err >&2", ok.Command)
+	assert.Equal(t, 1, ok.Attempts)
+	assert.Equal(t, 0, ok.ExitCode)
+	assert.Equal(t, "out\n", ok.Stdout)
+	assert.Equal(t, "err\n", ok.Stderr)
+	assert.False(t, ok.Truncated)
+	assert.Empty(t, ok.Error)
+	assert.NotZero(t, ok.StartTimestamp)
+
+	long := s.recv.Hooks[1]
+	assert.Len(t, long.Stdout, hookOutputLimit)
+	assert.True(t, long.Truncated)
+
+	fail := s.recv.Hooks[2]
+	assert.Equal(t, 3, fail.ExitCode)
+	assert.Equal(t, "failed\n", fail.Stderr)
+	assert.NotEmpty(t, fail.Error)
+
+	async := s.recv.Hooks[3]
+	assert.True(t, async.Async)
+	assert.Zero(t, async.Attempts)
+}
+
+func (s *hookerTestSuite) Test_ExecuteProcesses_Template() {
+	t := s.T()
+
+	s.recv.AnalysisEntry.DeadFollowers = []string{"follower_1", "follower_2"}
+	s.recv.Successor = vshard.InstanceIdent{UUID: "successor", URI: "it's"}
+
+	hooker := NewBashHooker(s.logger)
+	hooker.AddHooks(HookPostSuccessfulFailover, Hook{
+		Command:  `echo {{.Event}} {{.ClusterName}} {{join .AnalysisEntry.DeadFollowers ","}} {{quote .Successor.URI}} {{lower .Type}}`,
+		Template: true,
+	}, Hook{
+		Name:     "invalid",
+		Command:  "echo {{.Unknown}",
+		Template: true,
+	}, Hook{
+		Name:     "missing",
+		Command:  "echo {{.Unknown}}",
+		Template: true,
+	})
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPostSuccessfulFailover, s.recv, false)
+	require.NotNil(t, err)
+	require.Len(t, s.recv.Hooks, 3)
+
+	res := s.recv.Hooks[0]
+	assert.Empty(t, res.Error)
+	assert.Equal(t, "PostSuccessfulFailover sandbox follower_1,follower_2 it's deadmaster\n", res.Stdout)
+
+	// Invalid templates fail the hooks without retries.
+	assert.NotEmpty(t, s.recv.Hooks[1].Error)
+	assert.Equal(t, 1, s.recv.Hooks[1].Attempts)
+	assert.NotEmpty(t, s.recv.Hooks[2].Error)
+}
+
+func (s *hookerTestSuite) Test_ExecuteProcesses_Stdin() {
+	t := s.T()
+
+	hooker := NewBashHooker(s.logger)
+	hooker.AddHooks(HookPreFailover, Hook{
+		Command: "cat",
+	}, Hook{
+		// The hook might ignore the payload.
+		Command: "true",
+	})
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	require.Nil(t, err)
+	require.Len(t, s.recv.Hooks, 2)
+
+	var payload WebhookPayload
+	err = json.Unmarshal([]byte(s.recv.Hooks[0].Stdout), &payload)
+	require.Nil(t, err)
+	assert.Equal(t, HookPreFailover, payload.Event)
+	assert.Equal(t, s.recv.ClusterName, payload.Recovery.ClusterName)
+	assert.Equal(t, s.recv.SetUUID, payload.Recovery.SetUUID)
+}
+
 func genUniqueFilename(dir, prefix string) string {
 	name := ""
 	rand := uint32(0)
@@ -0,0 +1,254 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/shmel1k/qumomf/internal/util"
+)
+
+type HookJobStatus string
+
+const (
+	HookJobPending HookJobStatus = "pending"
+	// HookJobRunning marks the jobs claimed by one of the qumomf nodes sharing the storage.
+	HookJobRunning HookJobStatus = "running"
+	HookJobDone    HookJobStatus = "done"
+	// HookJobDead marks the jobs which have failed all attempts.
+	HookJobDead HookJobStatus = "dead"
+)
+
+// hookQueuePollInterval is a period of checking the pending jobs.
+const hookQueuePollInterval = time.Second
+
+// hookJobClaimMargin extends the claim of the running job over its timeout,
+// so the result of the attempt has time to be saved before the job might be claimed again.
+const hookJobClaimMargin = time.Minute
+
+var (
+	ErrHookJobNotFound = errors.New("hook job not found")
+	ErrHookJobNotDead  = errors.New("only dead hook jobs might be re-run")
+)
+
+// HookJob is an async hook persisted in the queue to survive
+// qumomf restarts and hook failures.
+type HookJob struct {
+	ID          int64    `json:"id"`
+	ClusterName string   `json:"cluster_name"`
+	Type        HookType `json:"type"`
+	Name        string   `json:"name,omitempty"`
+	// Command is the shell command with replaced placeholders.
+	Command string `json:"command,omitempty"`
+	// URL is the webhook endpoint.
+	URL string `json:"url,omitempty"`
+	// HookRef identifies the configured hook of the job. The webhook secret, headers
+	// and environment variables of the hook are not persisted, they are taken
+	// from the configuration when the job runs.
+	HookRef string `json:"hook_ref"`
+	// Payload is the JSON document passed to the hook.
+	Payload json.RawMessage `json:"payload"`
+	// Timeout is a deadline of a single attempt.
+	// Zero value means the async timeout of the hooker is used.
+	Timeout      time.Duration `json:"timeout"`
+	MaxAttempts  int           `json:"max_attempts"`
+	RetryBackoff time.Duration `json:"retry_backoff"`
+	Status       HookJobStatus `json:"status"`
+	Attempts     int           `json:"attempts"`
+	// NextAttemptAt is a unix time in milliseconds of the next attempt.
+	// The claim of the running job expires at this time.
+	NextAttemptAt int64 `json:"next_attempt_at"`
+	// LastResult is a result of the last attempt.
+	LastResult *HookExecution `json:"last_result,omitempty"`
+	CreatedAt  int64          `json:"created_at"`
+	UpdatedAt  int64          `json:"updated_at"`
+}
+
+// HookJobStorage persists the hook jobs.
+type HookJobStorage interface {
+	// SaveHookJob inserts the job without ID or updates the existing one.
+	// It returns the ID of the job.
+	SaveHookJob(context.Context, HookJob) (int64, error)
+	// GetHookJob returns ErrHookJobNotFound if there is no job with such ID.
+	GetHookJob(context.Context, int64) (HookJob, error)
+	// GetHookJobs returns the jobs of the cluster with the given status.
+	// Empty status means jobs with any status.
+	GetHookJobs(context.Context, string, HookJobStatus) ([]HookJob, error)
+	// ClaimHookJobs atomically claims the jobs of the cluster which are ready
+	// to be run at the given unix time in milliseconds and returns them.
+	// Each job is claimed only once even if the storage is shared by several qumomf nodes.
+	// On error the jobs claimed before it are returned as well,
+	// the returned jobs are exactly the ones claimed in the storage.
+	ClaimHookJobs(context.Context, string, int64) ([]HookJob, error)
+}
+
+// Claimable reports whether the job might be claimed at the given unix time in milliseconds:
+// the job is pending and its next attempt is due or the claim of the running job has expired,
+// e.g. the node running the job was stopped.
+func (j *HookJob) Claimable(now int64) bool {
+	return (j.Status == HookJobPending || j.Status == HookJobRunning) && j.NextAttemptAt <= now
+}
+
+// Claim marks the job as running until the attempt started
+// at the given unix time in milliseconds must have finished.
+func (j *HookJob) Claim(now int64) {
+	j.Status = HookJobRunning
+	j.NextAttemptAt = now + (j.Timeout + hookJobClaimMargin).Milliseconds()
+}
+
+// HookQueue executes the persisted async hooks of the cluster.
+// Failed jobs are retried with the backoff until the attempts are exhausted,
+// after that they are marked as dead and might be re-run manually.
+type HookQueue struct {
+	cluster string
+	store   HookJobStorage
+	hooker  *Hooker
+
+	wake   chan struct{}
+	stop   chan struct{}
+	logger zerolog.Logger
+}
+
+func NewHookQueue(cluster string, store HookJobStorage, hooker *Hooker, logger zerolog.Logger) *HookQueue {
+	return &HookQueue{
+		cluster: cluster,
+		store:   store,
+		hooker:  hooker,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}, 1),
+		logger:  logger,
+	}
+}
+
+// Serve starts executing the pending jobs including
+// the ones left from the previous qumomf run.
+func (q *HookQueue) Serve() {
+	go func() {
+		tick := time.NewTicker(hookQueuePollInterval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-q.stop:
+				return
+			case <-tick.C:
+				q.runPending()
+			case <-q.wake:
+				q.runPending()
+			}
+		}
+	}()
+}
+
+func (q *HookQueue) Shutdown() {
+	q.stop <- struct{}{}
+}
+
+// Enqueue persists the job to be executed as soon as possible.
+func (q *HookQueue) Enqueue(ctx context.Context, job HookJob) (HookJob, error) {
+	if job.Timeout <= 0 {
+		job.Timeout = q.hooker.timeoutAsync
+	}
+	job.ClusterName = q.cluster
+	job.Status = HookJobPending
+	job.Attempts = 0
+	job.NextAttemptAt = util.TimestampMs()
+	job.CreatedAt = util.Timestamp()
+	job.UpdatedAt = job.CreatedAt
+
+	id, err := q.store.SaveHookJob(ctx, job)
+	if err != nil {
+		return HookJob{}, err
+	}
+	job.ID = id
+	q.notify()
+
+	return job, nil
+}
+
+// Retry re-runs the dead job with a fresh number of attempts.
+func (q *HookQueue) Retry(ctx context.Context, id int64) (HookJob, error) {
+	job, err := q.store.GetHookJob(ctx, id)
+	if err != nil {
+		return HookJob{}, err
+	}
+	if job.ClusterName != q.cluster {
+		return HookJob{}, ErrHookJobNotFound
+	}
+	if job.Status != HookJobDead {
+		return HookJob{}, ErrHookJobNotDead
+	}
+
+	job.Status = HookJobPending
+	job.Attempts = 0
+	job.NextAttemptAt = util.TimestampMs()
+	job.UpdatedAt = util.Timestamp()
+	_, err = q.store.SaveHookJob(ctx, job)
+	if err != nil {
+		return HookJob{}, err
+	}
+	q.notify()
+
+	return job, nil
+}
+
+func (q *HookQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runPending claims the jobs ready to be run, so the other
+// qumomf nodes sharing the storage do not run them as well.
+func (q *HookQueue) runPending() {
+	jobs, err := q.store.ClaimHookJobs(context.Background(), q.cluster, util.TimestampMs())
+	if err != nil {
+		// Some jobs might have been claimed before the error.
+		q.logger.Err(err).Msg("Failed to claim pending hook jobs")
+	}
+
+	for i := range jobs {
+		go q.execute(jobs[i])
+	}
+}
+
+// execute makes a single attempt of the job and saves its result.
+func (q *HookQueue) execute(job HookJob) {
+	res, retry, err := q.hooker.executeJob(&job)
+	job.Attempts++
+	res.Attempts = job.Attempts
+	job.LastResult = &res
+	job.UpdatedAt = util.Timestamp()
+
+	logger := q.logger.With().
+		Int64("job_id", job.ID).
+		Str("hook_type", string(job.Type)).
+		Str("hook_name", job.Name).
+		Logger()
+	switch {
+	case err == nil:
+		job.Status = HookJobDone
+		logger.Info().Msgf("Hook job completed in %d ms", res.Duration)
+	case !retry || job.Attempts >= job.MaxAttempts:
+		job.Status = HookJobDead
+		logger.Error().Msgf("Hook job is dead after %d attempts: %v", job.Attempts, err)
+	default:
+		backoff := job.RetryBackoff
+		if backoff <= 0 {
+			backoff = defaultHookRetryBackoff
+		}
+		backoff <<= uint(job.Attempts - 1)
+		job.Status = HookJobPending
+		job.NextAttemptAt = util.TimestampMs() + backoff.Milliseconds()
+		logger.Warn().Msgf("Hook job attempt %d of %d failed, retrying in %v: %v", job.Attempts, job.MaxAttempts, backoff, err)
+	}
+
+	_, err = q.store.SaveHookJob(context.Background(), job)
+	if err != nil {
+		logger.Err(err).Msg("Failed to save hook job")
+	}
+}
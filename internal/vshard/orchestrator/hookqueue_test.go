@@ -0,0 +1,220 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+type memoryHookJobStorage struct {
+	mu   sync.Mutex
+	jobs []HookJob
+}
+
+func (m *memoryHookJobStorage) SaveHookJob(_ context.Context, job HookJob) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.ID == 0 {
+		job.ID = int64(len(m.jobs) + 1)
+		m.jobs = append(m.jobs, job)
+		return job.ID, nil
+	}
+	if job.ID > int64(len(m.jobs)) {
+		return 0, ErrHookJobNotFound
+	}
+	m.jobs[job.ID-1] = job
+
+	return job.ID, nil
+}
+
+func (m *memoryHookJobStorage) GetHookJob(_ context.Context, id int64) (HookJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id <= 0 || id > int64(len(m.jobs)) {
+		return HookJob{}, ErrHookJobNotFound
+	}
+
+	return m.jobs[id-1], nil
+}
+
+func (m *memoryHookJobStorage) GetHookJobs(_ context.Context, clusterName string, status HookJobStatus) ([]HookJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []HookJob
+	for _, job := range m.jobs {
+		if job.ClusterName == clusterName && (status == "" || job.Status == status) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
+
+func (m *memoryHookJobStorage) ClaimHookJobs(_ context.Context, clusterName string, now int64) ([]HookJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []HookJob
+	for i := range m.jobs {
+		job := &m.jobs[i]
+		if job.ClusterName == clusterName && job.Claimable(now) {
+			job.Claim(now)
+			jobs = append(jobs, *job)
+		}
+	}
+
+	return jobs, nil
+}
+
+func waitHookJobStatus(t *testing.T, store HookJobStorage, id int64, status HookJobStatus) HookJob {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := store.GetHookJob(context.Background(), id)
+		require.NoError(t, err)
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			require.FailNowf(t, "hook job status mismatch", "job %d has status %s, expected %s", id, job.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHookQueue(t *testing.T) {
+	logger := zerolog.Nop()
+	store := &memoryHookJobStorage{}
+	hooker := NewBashHooker(logger)
+	queue := NewHookQueue("sandbox", store, hooker, logger)
+	hooker.SetQueue(queue)
+	queue.Serve()
+	defer queue.Shutdown()
+
+	failed := vshard.InstanceIdent{UUID: mockAnalysis.Set.MasterUUID}
+	recv := NewRecovery(RecoveryScopeSet, failed, *mockAnalysis)
+	recv.ClusterName = "sandbox"
+	hooker.AddHooks(HookPostSuccessfulFailover, Hook{
+		Name:    "ok",
+		Command: "cat",
+		Async:   true,
+	}, Hook{
+		Name:         "fail",
+		Command:      "echo failed >&2; exit 1",
+		Async:        true,
+		Retries:      1,
+		RetryBackoff: 10 * time.Millisecond,
+	})
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPostSuccessfulFailover, recv, false)
+	require.Nil(t, err)
+	require.Len(t, recv.Hooks, 2)
+	assert.Equal(t, int64(1), recv.Hooks[0].JobID)
+	assert.Equal(t, int64(2), recv.Hooks[1].JobID)
+
+	done := waitHookJobStatus(t, store, 1, HookJobDone)
+	assert.Equal(t, 1, done.Attempts)
+	require.NotNil(t, done.LastResult)
+	assert.Equal(t, string(done.Payload), done.LastResult.Stdout)
+
+	dead := waitHookJobStatus(t, store, 2, HookJobDead)
+	assert.Equal(t, 2, dead.Attempts)
+	require.NotNil(t, dead.LastResult)
+	assert.Equal(t, 1, dead.LastResult.ExitCode)
+	assert.Equal(t, "failed\n", dead.LastResult.Stderr)
+
+	_, err = queue.Retry(context.Background(), done.ID)
+	assert.Equal(t, ErrHookJobNotDead, err)
+	_, err = queue.Retry(context.Background(), 100)
+	assert.Equal(t, ErrHookJobNotFound, err)
+
+	retried, err := queue.Retry(context.Background(), dead.ID)
+	require.NoError(t, err)
+	assert.Equal(t, HookJobPending, retried.Status)
+	assert.Zero(t, retried.Attempts)
+
+	dead = waitHookJobStatus(t, store, 2, HookJobDead)
+	assert.Equal(t, 2, dead.Attempts)
+}
+
+func TestHookJob_Claim(t *testing.T) {
+	now := int64(1000)
+	job := HookJob{
+		Status:        HookJobPending,
+		Timeout:       time.Second,
+		NextAttemptAt: now + 1,
+	}
+	assert.False(t, job.Claimable(now))
+	assert.True(t, job.Claimable(now+1))
+
+	job.Claim(now + 1)
+	assert.Equal(t, HookJobRunning, job.Status)
+	assert.False(t, job.Claimable(now+1))
+
+	// The claim expires when the attempt must have finished.
+	expired := now + 1 + (time.Second + hookJobClaimMargin).Milliseconds()
+	assert.False(t, job.Claimable(expired-1))
+	assert.True(t, job.Claimable(expired))
+
+	job.Status = HookJobDead
+	assert.False(t, job.Claimable(expired))
+}
+
+func TestHookQueue_ResolveHook(t *testing.T) {
+	logger := zerolog.Nop()
+	store := &memoryHookJobStorage{}
+	hooker := NewBashHooker(logger)
+	queue := NewHookQueue("sandbox", store, hooker, logger)
+	hooker.SetQueue(queue)
+	queue.Serve()
+	defer queue.Shutdown()
+
+	failed := vshard.InstanceIdent{UUID: mockAnalysis.Set.MasterUUID}
+	recv := NewRecovery(RecoveryScopeSet, failed, *mockAnalysis)
+	recv.ClusterName = "sandbox"
+	hooker.AddHooks(HookPostSuccessfulFailover, Hook{
+		Name:    "secret",
+		Command: `printf "$QUM_SECRET"`,
+		Async:   true,
+		Env:     map[string]string{"QUM_SECRET": "s3cret"},
+	})
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPostSuccessfulFailover, recv, false)
+	require.Nil(t, err)
+
+	// The secrets are taken from the configured hook when the job runs.
+	done := waitHookJobStatus(t, store, 1, HookJobDone)
+	require.NotNil(t, done.LastResult)
+	assert.Equal(t, "s3cret", done.LastResult.Stdout)
+
+	done.LastResult = nil
+	data, err := json.Marshal(done)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+
+	// The job of the hook removed from the configuration fails.
+	_, err = queue.Enqueue(context.Background(), HookJob{
+		Type:        HookPostSuccessfulFailover,
+		Name:        "removed",
+		Command:     "true",
+		HookRef:     "unknown",
+		Payload:     done.Payload,
+		MaxAttempts: 3,
+	})
+	require.NoError(t, err)
+
+	dead := waitHookJobStatus(t, store, 2, HookJobDead)
+	assert.Equal(t, 1, dead.Attempts)
+	require.NotNil(t, dead.LastResult)
+	assert.NotEmpty(t, dead.LastResult.Error)
+}
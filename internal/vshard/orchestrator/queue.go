@@ -0,0 +1,81 @@
+package orchestrator
+
+import (
+	"sync"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+// recoveryQueue keeps the analyses of the replica sets waiting
+// for the cluster to have a free recovery slot.
+//
+// Only the latest analysis of each replica set is kept,
+// replica sets are dequeued in order of their first appearance.
+type recoveryQueue struct {
+	order    []vshard.ReplicaSetUUID
+	analyses map[vshard.ReplicaSetUUID]*ReplicationAnalysis
+	mu       sync.Mutex
+}
+
+func newRecoveryQueue() *recoveryQueue {
+	return &recoveryQueue{
+		order:    make([]vshard.ReplicaSetUUID, 0),
+		analyses: make(map[vshard.ReplicaSetUUID]*ReplicationAnalysis),
+	}
+}
+
+// push adds the analysis to the queue or replaces
+// the previous analysis of the same replica set.
+func (q *recoveryQueue) push(analysis *ReplicationAnalysis) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	uuid := analysis.Set.UUID
+	if _, ok := q.analyses[uuid]; !ok {
+		q.order = append(q.order, uuid)
+	}
+	q.analyses[uuid] = analysis
+}
+
+// pop removes and returns the first analysis in the queue.
+// Returns nil if the queue is empty.
+func (q *recoveryQueue) pop() *ReplicationAnalysis {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.order) == 0 {
+		return nil
+	}
+
+	uuid := q.order[0]
+	q.order = q.order[1:]
+	analysis := q.analyses[uuid]
+	delete(q.analyses, uuid)
+
+	return analysis
+}
+
+// remove drops the analysis of the replica set from the queue.
+func (q *recoveryQueue) remove(uuid vshard.ReplicaSetUUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.analyses[uuid]; !ok {
+		return
+	}
+
+	delete(q.analyses, uuid)
+	for i := range q.order {
+		if q.order[i] == uuid {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (q *recoveryQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.order)
+}
@@ -0,0 +1,62 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+func Test_recoveryQueue(t *testing.T) {
+	newAnalysis := func(uuid vshard.ReplicaSetUUID, state ReplicaSetState) *ReplicationAnalysis {
+		return &ReplicationAnalysis{
+			Set:   vshard.ReplicaSet{UUID: uuid},
+			State: state,
+		}
+	}
+
+	q := newRecoveryQueue()
+	assert.Nil(t, q.pop())
+
+	q.push(newAnalysis("set_1", DeadMaster))
+	q.push(newAnalysis("set_2", DeadMaster))
+	q.push(newAnalysis("set_3", DeadMaster))
+	q.push(newAnalysis("set_1", DeadMasterAndSomeFollowers))
+	require.Equal(t, 3, q.len())
+
+	q.remove("set_2")
+	q.remove("set_4")
+	require.Equal(t, 2, q.len())
+
+	got := q.pop()
+	require.NotNil(t, got)
+	assert.Equal(t, vshard.ReplicaSetUUID("set_1"), got.Set.UUID)
+	assert.Equal(t, DeadMasterAndSomeFollowers, got.State)
+
+	got = q.pop()
+	require.NotNil(t, got)
+	assert.Equal(t, vshard.ReplicaSetUUID("set_3"), got.Set.UUID)
+
+	assert.Nil(t, q.pop())
+	assert.Equal(t, 0, q.len())
+}
+
+func Test_failover_runQueued(t *testing.T) {
+	cluster := vshard.MockCluster()
+	cluster.SetReadOnly(false)
+
+	f := NewDefaultFailover(cluster, FailoverConfig{MaxConcurrentRecoveries: 1}, zerolog.Nop()).(*failover)
+
+	// The replica sets are not in the cluster snapshot anymore,
+	// so the stale analyses must be skipped without starting any recovery.
+	f.queue.push(&ReplicationAnalysis{Set: vshard.ReplicaSet{UUID: "set_1"}, State: DeadMaster})
+	f.queue.push(&ReplicationAnalysis{Set: vshard.ReplicaSet{UUID: "set_2"}, State: DeadMaster})
+
+	f.runQueued(context.Background())
+	assert.Equal(t, 0, f.queue.len())
+	assert.Equal(t, 0, cluster.ActiveRecoveries())
+}
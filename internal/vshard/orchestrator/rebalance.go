@@ -0,0 +1,338 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+// negativePriorityPenalty is a placement cost of the master with negative
+// priority or on the drained host. It is high enough to move such masters first.
+const negativePriorityPenalty = 1000
+
+// PlannedSwitchover is a single master move proposed by the planner.
+type PlannedSwitchover struct {
+	SetUUID  vshard.ReplicaSetUUID `json:"set_uuid"`
+	From     vshard.InstanceUUID   `json:"from"`
+	FromHost string                `json:"from_host"`
+	FromZone string                `json:"from_zone"`
+	To       vshard.InstanceUUID   `json:"to"`
+	ToHost   string                `json:"to_host"`
+	ToZone   string                `json:"to_zone"`
+}
+
+// Placement is a number of masters per host and zone.
+type Placement struct {
+	Hosts map[string]int `json:"hosts"`
+	Zones map[string]int `json:"zones"`
+}
+
+// RebalancePlan contains the switchovers needed
+// to reach the target master placement.
+type RebalancePlan struct {
+	Current     Placement           `json:"current"`
+	Target      Placement           `json:"target"`
+	Switchovers []PlannedSwitchover `json:"switchovers"`
+}
+
+// SwitchoverResult is a result of the executed planned switchover.
+type SwitchoverResult struct {
+	Switchover PlannedSwitchover `json:"switchover"`
+	Recovery   *Recovery         `json:"recovery,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// RebalanceReport contains the executed plan and the results of its switchovers.
+type RebalanceReport struct {
+	Plan    RebalancePlan      `json:"plan"`
+	Results []SwitchoverResult `json:"results"`
+	// InProgress indicates whether the switchovers are still being executed.
+	InProgress bool `json:"in_progress"`
+	// Completed indicates whether all the planned switchovers succeeded.
+	Completed bool `json:"completed"`
+}
+
+// PlanRebalance computes the target master placement evenly distributed
+// across hosts and zones and returns the switchovers needed to reach it.
+//
+// Only replica sets with alive masters are rebalanced. A new master is chosen
+// among the alive followers with the same vshard configuration and
+// non-negative priority, followers with higher priority are preferred.
+// The master role is never moved to a follower with lower priority than the master's
+// just to improve the placement, except masters on the drained hosts.
+// Masters with negative priority or on the drained hosts are moved away whenever possible.
+func PlanRebalance(sets []vshard.ReplicaSet) RebalancePlan {
+	p := newPlacement(sets)
+	current := p.placement()
+
+	masters := make(map[vshard.ReplicaSetUUID]vshard.Instance)
+	for i := range sets {
+		master, err := sets[i].Master()
+		if err != nil || !master.LastCheckValid {
+			continue
+		}
+		masters[sets[i].UUID] = master
+	}
+
+	sorted := make([]vshard.ReplicaSet, len(sets))
+	copy(sorted, sets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].UUID < sorted[j].UUID
+	})
+
+	moved := make(map[vshard.ReplicaSetUUID]bool)
+	switchovers := make([]PlannedSwitchover, 0)
+	for {
+		var (
+			bestSet   *vshard.ReplicaSet
+			bestInst  vshard.Instance
+			bestDelta int
+		)
+
+		for i := range sorted {
+			set := &sorted[i]
+			master, ok := masters[set.UUID]
+			if !ok || moved[set.UUID] {
+				continue
+			}
+
+			for _, candidate := range rebalanceCandidates(*set, master) { //nolint:gocritic
+				if candidate.Priority < master.Priority && !master.Drained {
+					continue
+				}
+
+				delta := p.moveCost(master, candidate)
+				better := delta < bestDelta
+				if delta == bestDelta && bestSet != nil && delta < 0 {
+					better = candidate.Priority > bestInst.Priority
+				}
+				if better {
+					bestSet, bestInst, bestDelta = set, candidate, delta
+				}
+			}
+		}
+
+		if bestSet == nil {
+			break
+		}
+
+		master := masters[bestSet.UUID]
+		p.move(master, bestInst)
+		moved[bestSet.UUID] = true
+		switchovers = append(switchovers, PlannedSwitchover{
+			SetUUID:  bestSet.UUID,
+			From:     master.UUID,
+			FromHost: master.Host(),
+			FromZone: master.Zone,
+			To:       bestInst.UUID,
+			ToHost:   bestInst.Host(),
+			ToZone:   bestInst.Zone,
+		})
+	}
+
+	return RebalancePlan{
+		Current:     current,
+		Target:      p.placement(),
+		Switchovers: switchovers,
+	}
+}
+
+// rebalanceCandidates returns the followers which might
+// take the master role in the planned switchover.
+func rebalanceCandidates(set vshard.ReplicaSet, master vshard.Instance) []vshard.Instance {
+	followers := set.AliveFollowers()
+	candidates := make([]vshard.Instance, 0, len(followers))
+	for i := range followers {
+		inst := &followers[i]
+		if inst.Priority < 0 || inst.Drained || inst.VShardFingerprint != master.VShardFingerprint {
+			continue
+		}
+		if inst.Upstream == nil || inst.Upstream.Status != vshard.UpstreamFollow {
+			continue
+		}
+		candidates = append(candidates, *inst)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].UUID < candidates[j].UUID
+	})
+
+	return candidates
+}
+
+// mastersPlacement tracks the number of masters per host and zone.
+type mastersPlacement struct {
+	hosts map[string]int
+	zones map[string]int
+}
+
+func newPlacement(sets []vshard.ReplicaSet) *mastersPlacement {
+	p := &mastersPlacement{
+		hosts: make(map[string]int),
+		zones: make(map[string]int),
+	}
+
+	for i := range sets {
+		set := &sets[i]
+		for j := range set.Instances {
+			inst := &set.Instances[j]
+			if !inst.LastCheckValid {
+				continue
+			}
+
+			isMaster := 0
+			if inst.UUID == set.MasterUUID {
+				isMaster = 1
+			}
+
+			if host := inst.Host(); host != "" {
+				p.hosts[host] += isMaster
+			}
+			if inst.Zone != "" {
+				p.zones[inst.Zone] += isMaster
+			}
+		}
+	}
+
+	return p
+}
+
+// moveCost returns the change of the placement cost if the master role
+// is moved from the master to the candidate. Negative value means improvement.
+//
+// The cost is a sum of squared numbers of masters per host and zone,
+// so it is minimal when masters are evenly distributed.
+func (p *mastersPlacement) moveCost(master, candidate vshard.Instance) int {
+	delta := 0
+
+	// Unknown hosts are neither loaded nor unloaded by the move.
+	from, to := master.Host(), candidate.Host()
+	if from != to {
+		if from != "" {
+			delta += 1 - 2*p.hosts[from]
+		}
+		if to != "" {
+			delta += 2*p.hosts[to] + 1
+		}
+	}
+
+	from, to = master.Zone, candidate.Zone
+	if from != to {
+		if from != "" {
+			delta += 1 - 2*p.zones[from]
+		}
+		if to != "" {
+			delta += 2*p.zones[to] + 1
+		}
+	}
+
+	if master.Priority < 0 || master.Drained {
+		delta -= negativePriorityPenalty
+	}
+
+	return delta
+}
+
+func (p *mastersPlacement) move(master, candidate vshard.Instance) {
+	if host := master.Host(); host != "" {
+		p.hosts[host]--
+	}
+	if host := candidate.Host(); host != "" {
+		p.hosts[host]++
+	}
+
+	if master.Zone != "" {
+		p.zones[master.Zone]--
+	}
+	if candidate.Zone != "" {
+		p.zones[candidate.Zone]++
+	}
+}
+
+func (p *mastersPlacement) placement() Placement {
+	dst := Placement{
+		Hosts: make(map[string]int, len(p.hosts)),
+		Zones: make(map[string]int, len(p.zones)),
+	}
+	for k, v := range p.hosts {
+		dst.Hosts[k] = v
+	}
+	for k, v := range p.zones {
+		dst.Zones[k] = v
+	}
+
+	return dst
+}
+
+// ExecuteSwitchovers runs the planned switchovers one at a time.
+//
+// After each switchover the replica set must be healthy: the candidate
+// must become a master and no problem must be found in the replica set.
+// The execution is stopped on the first failure.
+// The progress function, if set, is called with the result of each switchover.
+func ExecuteSwitchovers(ctx context.Context, cluster *vshard.Cluster, f Failover, switchovers []PlannedSwitchover,
+	progress func(SwitchoverResult), logger zerolog.Logger) (results []SwitchoverResult, completed bool) {
+	results = make([]SwitchoverResult, 0, len(switchovers))
+
+	for _, sw := range switchovers {
+		result := SwitchoverResult{
+			Switchover: sw,
+		}
+
+		recv, err := executeSwitchover(ctx, cluster, f, sw, logger)
+		result.Recovery = recv
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+		if progress != nil {
+			progress(result)
+		}
+		if err != nil {
+			logger.Err(err).
+				Str("replica_set", string(sw.SetUUID)).
+				Msg("Planned switchover failed: the remaining switchovers are cancelled")
+
+			return results, false
+		}
+	}
+
+	return results, true
+}
+
+func executeSwitchover(ctx context.Context, cluster *vshard.Cluster, f Failover, sw PlannedSwitchover, logger zerolog.Logger) (*Recovery, error) {
+	set, err := cluster.ReplicaSet(sw.SetUUID)
+	if err != nil {
+		return nil, err
+	}
+	if set.MasterUUID != sw.From {
+		return nil, fmt.Errorf("master of the replica set has changed to %s", set.MasterUUID)
+	}
+
+	recv, err := f.Switchover(ctx, sw.SetUUID, sw.To)
+	if err != nil {
+		return nil, err
+	}
+	if !recv.IsSuccessful {
+		return recv, fmt.Errorf("switchover was not applied")
+	}
+
+	// Health check: the replica set must have no problem with the new master.
+	set, err = cluster.ReplicaSet(sw.SetUUID)
+	if err != nil {
+		return recv, err
+	}
+	if set.MasterUUID != sw.To {
+		return recv, fmt.Errorf("replica set master is %s instead of %s", set.MasterUUID, sw.To)
+	}
+	analysis := analyze(set, logger)
+	if analysis.State != NoProblem {
+		return recv, fmt.Errorf("replica set is unhealthy after switchover: %s", analysis.State)
+	}
+
+	return recv, nil
+}
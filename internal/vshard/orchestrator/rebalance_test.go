@@ -0,0 +1,193 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+func tRebalanceInstance(uuid, host, zone string, priority int) vshard.Instance {
+	return vshard.Instance{
+		UUID:              vshard.InstanceUUID(uuid),
+		URI:               uuid + ":3301",
+		HostLabel:         host,
+		Zone:              zone,
+		Priority:          priority,
+		LastCheckValid:    true,
+		VShardFingerprint: 100,
+		Upstream: &vshard.Upstream{
+			Status: vshard.UpstreamFollow,
+		},
+	}
+}
+
+func tRebalanceSet(uuid, master string, instances ...vshard.Instance) vshard.ReplicaSet {
+	return vshard.ReplicaSet{
+		UUID:       vshard.ReplicaSetUUID(uuid),
+		MasterUUID: vshard.InstanceUUID(master),
+		Instances:  instances,
+	}
+}
+
+func TestPlanRebalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		sets     []vshard.ReplicaSet
+		expected []PlannedSwitchover
+		target   map[string]int
+	}{
+		{
+			name: "Balanced",
+			sets: []vshard.ReplicaSet{
+				tRebalanceSet("rs1", "a1",
+					tRebalanceInstance("a1", "h1", "", 0),
+					tRebalanceInstance("a2", "h2", "", 0),
+				),
+				tRebalanceSet("rs2", "b2",
+					tRebalanceInstance("b1", "h1", "", 0),
+					tRebalanceInstance("b2", "h2", "", 0),
+				),
+			},
+			expected: []PlannedSwitchover{},
+			target:   map[string]int{"h1": 1, "h2": 1},
+		},
+		{
+			name: "MastersOnSameHost",
+			sets: []vshard.ReplicaSet{
+				tRebalanceSet("rs1", "a1",
+					tRebalanceInstance("a1", "h1", "z1", 0),
+					tRebalanceInstance("a2", "h2", "z2", 0),
+				),
+				tRebalanceSet("rs2", "b1",
+					tRebalanceInstance("b1", "h1", "z1", 0),
+					tRebalanceInstance("b2", "h2", "z2", 0),
+				),
+			},
+			expected: []PlannedSwitchover{
+				{
+					SetUUID: "rs1", From: "a1", FromHost: "h1", FromZone: "z1",
+					To: "a2", ToHost: "h2", ToZone: "z2",
+				},
+			},
+			target: map[string]int{"h1": 1, "h2": 1},
+		},
+		{
+			name: "HigherPriorityPreferred",
+			sets: []vshard.ReplicaSet{
+				tRebalanceSet("rs1", "a1",
+					tRebalanceInstance("a1", "h1", "", 0),
+					tRebalanceInstance("a2", "h2", "", 1),
+					tRebalanceInstance("a3", "h3", "", 5),
+				),
+				tRebalanceSet("rs2", "b1",
+					tRebalanceInstance("b1", "h1", "", 0),
+				),
+			},
+			expected: []PlannedSwitchover{
+				{SetUUID: "rs1", From: "a1", FromHost: "h1", To: "a3", ToHost: "h3"},
+			},
+			target: map[string]int{"h1": 1, "h2": 0, "h3": 1},
+		},
+		{
+			name: "HigherPriorityMasterKept",
+			sets: []vshard.ReplicaSet{
+				tRebalanceSet("rs1", "a1",
+					tRebalanceInstance("a1", "h1", "", 5),
+					tRebalanceInstance("a2", "h2", "", 0),
+				),
+				tRebalanceSet("rs2", "b1",
+					tRebalanceInstance("b1", "h1", "", 0),
+				),
+			},
+			expected: []PlannedSwitchover{},
+			target:   map[string]int{"h1": 2, "h2": 0},
+		},
+		{
+			name: "NegativePriorityMaster",
+			sets: []vshard.ReplicaSet{
+				tRebalanceSet("rs1", "a1",
+					tRebalanceInstance("a1", "h1", "", -1),
+					tRebalanceInstance("a2", "h1", "", 0),
+				),
+			},
+			expected: []PlannedSwitchover{
+				{SetUUID: "rs1", From: "a1", FromHost: "h1", To: "a2", ToHost: "h1"},
+			},
+			target: map[string]int{"h1": 1},
+		},
+		{
+			name: "IneligibleCandidates",
+			sets: []vshard.ReplicaSet{
+				tRebalanceSet("rs1", "a1",
+					tRebalanceInstance("a1", "h1", "", 0),
+					tRebalanceInstance("a2", "h2", "", -1),
+					func() vshard.Instance {
+						inst := tRebalanceInstance("a3", "h3", "", 0)
+						inst.VShardFingerprint = 200
+						return inst
+					}(),
+				),
+				tRebalanceSet("rs2", "b1",
+					tRebalanceInstance("b1", "h1", "", 0),
+					func() vshard.Instance {
+						inst := tRebalanceInstance("b2", "h2", "", 0)
+						inst.Upstream.Status = vshard.UpstreamStopped
+						return inst
+					}(),
+				),
+			},
+			expected: []PlannedSwitchover{},
+			target:   map[string]int{"h1": 2, "h2": 0, "h3": 0},
+		},
+		{
+			name: "DeadMasterSkipped",
+			sets: []vshard.ReplicaSet{
+				tRebalanceSet("rs1", "a1",
+					func() vshard.Instance {
+						inst := tRebalanceInstance("a1", "h1", "", -1)
+						inst.LastCheckValid = false
+						return inst
+					}(),
+					tRebalanceInstance("a2", "h2", "", 0),
+				),
+			},
+			expected: []PlannedSwitchover{},
+			target:   map[string]int{"h2": 0},
+		},
+		{
+			name: "UnknownHostsNotColocated",
+			sets: []vshard.ReplicaSet{
+				tRebalanceSet("rs1", "a1",
+					func() vshard.Instance {
+						inst := tRebalanceInstance("a1", "", "", 0)
+						inst.URI = ":3301"
+						return inst
+					}(),
+					tRebalanceInstance("a2", "h2", "", 0),
+				),
+				tRebalanceSet("rs2", "b1",
+					func() vshard.Instance {
+						inst := tRebalanceInstance("b1", "", "", 0)
+						inst.URI = ":3302"
+						return inst
+					}(),
+					tRebalanceInstance("b2", "h2", "", 0),
+				),
+			},
+			expected: []PlannedSwitchover{},
+			target:   map[string]int{"h2": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			plan := PlanRebalance(tt.sets)
+			require.Equal(t, tt.expected, plan.Switchovers)
+			assert.Equal(t, tt.target, plan.Target.Hosts)
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/shmel1k/qumomf/internal/quorum"
 	"github.com/shmel1k/qumomf/internal/util"
 	"github.com/shmel1k/qumomf/internal/vshard"
 )
@@ -24,6 +25,34 @@ const (
 	RecoveryScopeSet      RecoveryScope = "replica set"
 )
 
+type RecoveryStepName string
+
+const (
+	StepHooksStarted           RecoveryStepName = "HooksStarted"
+	StepHooksFinished          RecoveryStepName = "HooksFinished"
+	StepMasterElected          RecoveryStepName = "MasterElected"
+	StepCandidateRejected      RecoveryStepName = "CandidateRejected"
+	StepCandidateChecked       RecoveryStepName = "CandidateChecked"
+	StepCandidateConfigApplied RecoveryStepName = "CandidateConfigApplied"
+	StepRoutersUpdated         RecoveryStepName = "RoutersUpdated"
+	StepNodesUpdated           RecoveryStepName = "NodesUpdated"
+	StepNodeConfigApplied      RecoveryStepName = "NodeConfigApplied"
+	StepForcedDiscovery        RecoveryStepName = "ForcedDiscovery"
+	StepTimedOut               RecoveryStepName = "TimedOut"
+	StepMasterDemoted          RecoveryStepName = "MasterDemoted"
+	StepCandidateCaughtUp      RecoveryStepName = "CandidateCaughtUp"
+	StepMasterRestored         RecoveryStepName = "MasterRestored"
+)
+
+// RecoveryStep is a single step of the recovery process.
+type RecoveryStep struct {
+	Name RecoveryStepName
+	// Timestamp is a unix time in milliseconds when the step was completed.
+	Timestamp int64
+	// Details contains the step result or error description.
+	Details string
+}
+
 // Recovery describes the applied recovery to a cluster, replica set or instance.
 type Recovery struct {
 	Type           string
@@ -37,6 +66,16 @@ type Recovery struct {
 	StartTimestamp int64
 	EndTimestamp   int64
 	Expiration     int64
+	// Steps is a timeline of the recovery process.
+	Steps []RecoveryStep
+	// TimedOut indicates whether the recovery was abandoned
+	// because of the recovery deadline.
+	TimedOut bool
+	// Election contains the master election decision
+	// with all the considered followers.
+	Election *quorum.Decision
+	// Hooks contains the results of the executed hooks.
+	Hooks []HookExecution
 }
 
 func NewRecovery(scope RecoveryScope, failed vshard.InstanceIdent, analysis ReplicationAnalysis) *Recovery {
@@ -50,6 +89,29 @@ func NewRecovery(scope RecoveryScope, failed vshard.InstanceIdent, analysis Repl
 	}
 }
 
+// AddStep appends the step to the recovery timeline.
+func (r *Recovery) AddStep(name RecoveryStepName, details string) {
+	r.Steps = append(r.Steps, RecoveryStep{
+		Name:      name,
+		Timestamp: util.TimestampMs(),
+		Details:   details,
+	})
+}
+
+// CheckDeadline marks the recovery as timed out if the context deadline is exceeded.
+// Returns true if the recovery must be abandoned.
+func (r *Recovery) CheckDeadline(ctx context.Context) bool {
+	err := ctx.Err()
+	if err == nil {
+		return false
+	}
+
+	r.TimedOut = true
+	r.AddStep(StepTimedOut, err.Error())
+
+	return true
+}
+
 func (r *Recovery) ExpireAfter(ttl time.Duration) {
 	exp := time.Now().Add(ttl).Unix()
 	r.Expiration = exp
@@ -91,6 +153,9 @@ func (r *Recovery) String() string {
 	}
 	sb.WriteString(", success: ")
 	sb.WriteString(strconv.FormatBool(r.IsSuccessful))
+	if r.TimedOut {
+		sb.WriteString(", timed out: true")
+	}
 	sb.WriteString(", period: ")
 	sb.WriteString(start)
 	sb.WriteString(" - ")
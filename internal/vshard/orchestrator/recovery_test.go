@@ -1,10 +1,12 @@
 package orchestrator
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/shmel1k/qumomf/internal/util"
 	"github.com/shmel1k/qumomf/internal/vshard"
@@ -52,3 +54,36 @@ func TestRecovery_Expired(t *testing.T) {
 	time.Sleep(2 * ttl)
 	assert.True(t, r.Expired())
 }
+
+func TestRecovery_AddStep(t *testing.T) {
+	r := NewRecovery(RecoveryScopeSet, vshard.InstanceIdent{}, *mockAnalysis)
+	r.AddStep(StepHooksStarted, string(HookPreFailover))
+	r.AddStep(StepMasterElected, "replica_2")
+
+	require.Len(t, r.Steps, 2)
+	assert.Equal(t, StepHooksStarted, r.Steps[0].Name)
+	assert.Equal(t, string(HookPreFailover), r.Steps[0].Details)
+	assert.Equal(t, StepMasterElected, r.Steps[1].Name)
+	assert.Equal(t, "replica_2", r.Steps[1].Details)
+	assert.InDelta(t, util.TimestampMs(), r.Steps[1].Timestamp, 1000)
+	assert.LessOrEqual(t, r.Steps[0].Timestamp, r.Steps[1].Timestamp)
+}
+
+func TestRecovery_CheckDeadline(t *testing.T) {
+	r := NewRecovery(RecoveryScopeSet, vshard.InstanceIdent{}, *mockAnalysis)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	assert.False(t, r.CheckDeadline(ctx))
+	assert.False(t, r.TimedOut)
+	assert.Empty(t, r.Steps)
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	assert.True(t, r.CheckDeadline(ctx))
+	assert.True(t, r.TimedOut)
+	require.Len(t, r.Steps, 1)
+	assert.Equal(t, StepTimedOut, r.Steps[0].Name)
+}
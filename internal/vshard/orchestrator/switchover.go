@@ -0,0 +1,203 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/viciious/go-tarantool"
+
+	"github.com/shmel1k/qumomf/internal/util"
+	"github.com/shmel1k/qumomf/internal/vshard"
+)
+
+// RecoveryTypeSwitchover is a type of recoveries made by the planned switchover.
+const RecoveryTypeSwitchover = "Switchover"
+
+const (
+	// setReadOnlyLua is a template of Lua script changing the readonly mode of the instance.
+	setReadOnlyLua = `box.cfg({read_only = {read_only}})`
+
+	// catchUpPollInterval is a period of checking whether
+	// the candidate has caught up with the demoted master.
+	catchUpPollInterval = 100 * time.Millisecond
+	// catchUpTimeout is a max time of waiting for the candidate
+	// to catch up with the demoted master.
+	catchUpTimeout = 30 * time.Second
+)
+
+var (
+	ErrClusterReadOnly      = errors.New("cluster is in readonly mode")
+	ErrReplicaSetBusy       = errors.New("replica set has an active recovery or the limit of concurrent recoveries is reached")
+	ErrMasterNotAlive       = errors.New("master of the replica set is not alive")
+	ErrCandidateNotEligible = errors.New("candidate is not an alive follower of the replica set")
+)
+
+// Switchover gracefully moves the master role of the replica set to the candidate.
+//
+// The current master is demoted first, then qumomf waits until the candidate
+// has received all the data of the demoted master and only after that
+// the new configuration is applied. If the candidate does not catch up
+// in time, the demoted master is restored.
+//
+// Switchover is refused in readonly mode and counts towards
+// the limit of concurrent recoveries like a failover does.
+//
+// Failure of PreSwitchover hooks aborts the switchover.
+func (f *failover) Switchover(ctx context.Context, setUUID vshard.ReplicaSetUUID, candidateUUID vshard.InstanceUUID) (*Recovery, error) {
+	if f.cluster.ReadOnly() {
+		return nil, ErrClusterReadOnly
+	}
+
+	set, err := f.cluster.ReplicaSet(setUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	master, err := set.Master()
+	if err != nil || !master.LastCheckValid {
+		return nil, ErrMasterNotAlive
+	}
+
+	candidate, ok := aliveFollower(set, candidateUUID)
+	if !ok {
+		return nil, ErrCandidateNotEligible
+	}
+
+	if !f.cluster.StartRecovery(setUUID, f.maxConcurrentRecoveries) {
+		return nil, ErrReplicaSetBusy
+	}
+	defer f.cluster.StopRecovery(setUUID)
+
+	logger := f.logger.With().
+		Str("replica_set", string(setUUID)).
+		Str("master", string(master.UUID)).
+		Str("candidate", string(candidateUUID)).
+		Logger()
+	logger.Info().Msg("Start planned switchover")
+
+	recv := NewRecovery(RecoveryScopeSet, master.Ident(), ReplicationAnalysis{
+		Set:   set,
+		State: NoProblem,
+	})
+	recv.Type = RecoveryTypeSwitchover
+	recv.ClusterName = f.cluster.Name
+	recv.Successor = candidate.Ident()
+
+	err = f.executeHooks(ctx, HookPreSwitchover, recv, true)
+	if err == nil {
+		recvCtx, cancel := f.withRecoveryDeadline(ctx)
+		f.switchover(recvCtx, recv, master, candidate, logger)
+		cancel()
+
+		if !recv.TimedOut {
+			f.discoverySync.Lock()
+			f.cluster.Discover()
+			f.discoverySync.Unlock()
+			recv.AddStep(StepForcedDiscovery, "")
+		}
+	}
+	recv.EndTimestamp = util.Timestamp()
+	_ = f.executeHooks(ctx, HookPostSwitchover, recv, false)
+
+	if f.onClusterRecoveredCB != nil {
+		go f.onClusterRecoveredCB(*recv)
+	}
+	logger.Info().Msgf("Finished planned switchover: %s", recv)
+
+	return recv, nil
+}
+
+func (f *failover) switchover(ctx context.Context, recv *Recovery, master, candidate vshard.Instance, logger zerolog.Logger) {
+	f.configSync.Lock()
+	defer f.configSync.Unlock()
+
+	err := f.setReadOnly(ctx, master, true)
+	if err != nil {
+		recv.AddStep(StepMasterDemoted, err.Error())
+		recv.CheckDeadline(ctx)
+		logger.Err(err).Msg("Failed to demote the master")
+		return
+	}
+	recv.AddStep(StepMasterDemoted, string(master.UUID))
+
+	err = f.waitCatchUp(ctx, master, candidate)
+	if err == nil {
+		recv.AddStep(StepCandidateCaughtUp, string(candidate.UUID))
+		if f.applyMasterConfig(ctx, recv, recv.SetUUID, candidate, logger) {
+			recv.IsSuccessful = true
+			return
+		}
+	} else {
+		recv.AddStep(StepCandidateCaughtUp, err.Error())
+		recv.CheckDeadline(ctx)
+		logger.Err(err).Msg("Candidate has not caught up with the demoted master")
+	}
+
+	// The candidate is not a master, so give the master role back.
+	// Do not use the recovery context because its deadline might be exceeded.
+	err = f.setReadOnly(context.Background(), master, false)
+	if err != nil {
+		recv.AddStep(StepMasterRestored, err.Error())
+		logger.Err(err).Msg("Failed to restore the demoted master")
+		return
+	}
+	recv.AddStep(StepMasterRestored, string(master.UUID))
+}
+
+func (f *failover) setReadOnly(ctx context.Context, inst vshard.Instance, readOnly bool) error {
+	query := &tarantool.Eval{
+		Expression: strings.ReplaceAll(setReadOnlyLua, "{read_only}", strconv.FormatBool(readOnly)),
+	}
+
+	conn := f.cluster.Connector(inst.URI)
+	resp := conn.Exec(ctx, query)
+
+	return resp.Error
+}
+
+// waitCatchUp waits until the candidate has received
+// all the data written on the demoted master.
+func (f *failover) waitCatchUp(ctx context.Context, master, candidate vshard.Instance) error {
+	ctx, cancel := context.WithTimeout(ctx, catchUpTimeout)
+	defer cancel()
+
+	masterState, err := f.cluster.InstanceState(ctx, master)
+	if err != nil {
+		return fmt.Errorf("failed to read the master state: %w", err)
+	}
+	masterLSN := masterState.VClock[master.ID]
+
+	ticker := time.NewTicker(catchUpPollInterval)
+	defer ticker.Stop()
+
+	for {
+		state, err := f.cluster.InstanceState(ctx, candidate)
+		if err == nil && state.VClock[master.ID] >= masterLSN {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("failed to read the candidate state: %w", err)
+			}
+			return fmt.Errorf("candidate LSN %d is behind the master LSN %d", state.VClock[master.ID], masterLSN)
+		case <-ticker.C:
+		}
+	}
+}
+
+func aliveFollower(set vshard.ReplicaSet, uuid vshard.InstanceUUID) (vshard.Instance, bool) {
+	for _, inst := range set.AliveFollowers() { //nolint:gocritic
+		if inst.UUID == uuid {
+			return inst, true
+		}
+	}
+
+	return vshard.Instance{}, false
+}
@@ -0,0 +1,60 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// HookTemplateData is a data the hook templates are executed over.
+// All fields of the recovery are available at the top level, e.g. {{.ClusterName}}.
+type HookTemplateData struct {
+	// Event is the type of the executed hook.
+	Event HookType
+	*Recovery
+}
+
+// hookTemplateFuncs are the helper functions available in the hook templates.
+var hookTemplateFuncs = template.FuncMap{
+	// json marshals the value to JSON, e.g. {{json .Election}}.
+	"json": templateJSON,
+	// join concatenates the strings, e.g. {{join .AnalysisEntry.DeadFollowers ","}}.
+	"join": strings.Join,
+	// quote makes the value safe to be passed as a single shell argument.
+	"quote": shellQuote,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// ParseHookTemplate parses the command of the templated hook.
+func ParseHookTemplate(command string) (*template.Template, error) {
+	return template.New("hook").Funcs(hookTemplateFuncs).Parse(strings.TrimSpace(command))
+}
+
+func executeHookTemplate(tmpl *template.Template, t HookType, recv *Recovery) (string, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, HookTemplateData{
+		Event:    t,
+		Recovery: recv,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func templateJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func shellQuote(v interface{}) string {
+	return "'" + strings.Replace(fmt.Sprint(v), "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,105 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	// HeaderWebhookEvent contains the hook type which triggered the webhook.
+	HeaderWebhookEvent = "X-Qumomf-Event"
+	// HeaderWebhookSignature contains the HMAC-SHA256 signature
+	// of the request body in format "sha256=<hex digest>".
+	HeaderWebhookSignature = "X-Qumomf-Signature"
+)
+
+// webhookResponseLimit is a max number of bytes read from the webhook response.
+const webhookResponseLimit = 4096
+
+// Webhook is a hook sending the recovery data to the HTTP endpoint.
+type Webhook struct {
+	// URL is the endpoint the JSON document is posted to.
+	URL string
+	// Headers are added to each request.
+	Headers map[string]string
+	// Secret is a key used to sign the request body with HMAC-SHA256.
+	// Requests are not signed if the secret is empty.
+	Secret string
+}
+
+// WebhookPayload is a JSON document posted by the webhooks
+// and written to the stdin of the shell hooks.
+type WebhookPayload struct {
+	Event    HookType  `json:"event"`
+	Recovery *Recovery `json:"recovery"`
+}
+
+// sendWebhook makes a single request to the webhook and captures the response.
+// It reports whether the failed request might be retried.
+func (h *Hooker) sendWebhook(ctx context.Context, hook *Webhook, t HookType, body []byte, res *HookExecution) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range hook.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(HeaderWebhookEvent, string(t))
+	if hook.Secret != "" {
+		req.Header.Set(HeaderWebhookSignature, SignWebhookBody(hook.Secret, body))
+	}
+
+	res.StatusCode = 0
+	res.Stdout = ""
+	res.Truncated = false
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	// Read a bit of the response to reuse the connection and report the error.
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit+1))
+	if len(data) > webhookResponseLimit {
+		data = data[:webhookResponseLimit]
+		res.Truncated = true
+	}
+	res.StatusCode = resp.StatusCode
+	res.Stdout = string(data)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("unexpected response status %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+
+	return retry, err
+}
+
+// SignWebhookBody returns the value of the signature header for the body.
+func SignWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newHookPayload(t HookType, recv *Recovery) ([]byte, error) {
+	return json.Marshal(WebhookPayload{
+		Event:    t,
+		Recovery: recv,
+	})
+}
@@ -0,0 +1,145 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *hookerTestSuite) Test_ExecuteWebhooks() {
+	t := s.T()
+
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	hooker := NewBashHooker(s.logger)
+	hooker.AddHooks(HookPreFailover, Hook{
+		Webhook: &Webhook{
+			URL:     srv.URL,
+			Headers: map[string]string{"Authorization": "Bearer token"},
+			Secret:  "secret",
+		},
+	})
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	require.Nil(t, err)
+
+	r := <-requests
+	body := <-bodies
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+	assert.Equal(t, string(HookPreFailover), r.Header.Get(HeaderWebhookEvent))
+	assert.Equal(t, SignWebhookBody("secret", body), r.Header.Get(HeaderWebhookSignature))
+
+	var payload WebhookPayload
+	err = json.Unmarshal(body, &payload)
+	require.Nil(t, err)
+	assert.Equal(t, HookPreFailover, payload.Event)
+	require.NotNil(t, payload.Recovery)
+	assert.Equal(t, s.recv.ClusterName, payload.Recovery.ClusterName)
+	assert.Equal(t, s.recv.Failed, payload.Recovery.Failed)
+	assert.Equal(t, s.analysis.CountReplicas, payload.Recovery.AnalysisEntry.CountReplicas)
+}
+
+func (s *hookerTestSuite) Test_ExecuteWebhooks_Retries() {
+	t := s.T()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("accepted"))
+	}))
+	defer srv.Close()
+
+	hooker := NewBashHooker(s.logger)
+	hooker.AddHooks(HookPreFailover, Hook{
+		Webhook:      &Webhook{URL: srv.URL},
+		Retries:      2,
+		RetryBackoff: 10 * time.Millisecond,
+	})
+
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	require.Len(t, s.recv.Hooks, 1)
+	res := s.recv.Hooks[0]
+	assert.Equal(t, srv.URL, res.URL)
+	assert.Equal(t, 3, res.Attempts)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "accepted", res.Stdout)
+	assert.Empty(t, res.Error)
+}
+
+func (s *hookerTestSuite) Test_ExecuteWebhooks_ClientError() {
+	t := s.T()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	hooker := NewBashHooker(s.logger)
+	hooker.AddHooks(HookPreFailover, Hook{
+		Webhook:      &Webhook{URL: srv.URL},
+		Retries:      2,
+		RetryBackoff: 10 * time.Millisecond,
+	})
+	hooker.AddHooks(HookPreFailover, Hook{
+		Webhook: &Webhook{URL: srv.URL},
+	})
+
+	// Client errors are not retried and fail the recovery.
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// All webhooks are executed if the errors are ignored.
+	err = hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, false)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func (s *hookerTestSuite) Test_ExecuteWebhooks_Async() {
+	t := s.T()
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+		w.WriteHeader(http.StatusInternalServerError)
+		close(done)
+	}))
+	defer srv.Close()
+
+	hooker := NewBashHooker(s.logger)
+	hooker.AddHooks(HookPreFailover, Hook{
+		Webhook: &Webhook{URL: srv.URL},
+		Async:   true,
+	})
+
+	start := time.Now()
+	err := hooker.ExecuteProcesses(context.Background(), HookPreFailover, s.recv, true)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, start, time.Now(), 500*time.Millisecond)
+
+	<-done
+}
@@ -254,10 +254,14 @@ func ParseInstanceInfo(data [][]interface{}) (InstanceInfo, error) {
 		return InstanceInfo{}, err
 	}
 
+	// Zone is optional in the vshard configuration.
+	zone, _ := dt["zone"].(string)
+
 	return InstanceInfo{
 		Readonly:          readonly,
 		VShardFingerprint: fingerprint,
 		StorageInfo:       storageInfo,
+		Zone:              zone,
 	}, nil
 }
 
@@ -388,6 +392,109 @@ func parseAlerts(dt container) ([]Alert, error) {
 	return alerts, nil
 }
 
+func ParseInstanceState(data [][]interface{}) (InstanceState, error) {
+	if len(data) == 0 {
+		return InstanceState{}, ErrEmptyResponse
+	}
+
+	tuple := data[0]
+	if len(tuple) == 0 {
+		return InstanceState{}, ErrNoInstanceInfo
+	}
+
+	dt, err := castToContainer(tuple[0])
+	if err != nil {
+		return InstanceState{}, err
+	}
+
+	status, err := dt.getString("status")
+	if err != nil {
+		return InstanceState{}, err
+	}
+
+	readonly, err := dt.getBool("read_only")
+	if err != nil {
+		return InstanceState{}, err
+	}
+
+	vclock := make(map[uint64]int64)
+	err = parsePairs(dt, "vclock", func(id uint64, v interface{}) error {
+		lsn, err := toInt64(v)
+		if err != nil {
+			return err
+		}
+		vclock[id] = lsn
+		return nil
+	})
+	if err != nil {
+		return InstanceState{}, err
+	}
+
+	upstreams := make(map[uint64]UpstreamStatus)
+	err = parsePairs(dt, "upstreams", func(id uint64, v interface{}) error {
+		status, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected to find upstream status string, got %T", v)
+		}
+		upstreams[id] = UpstreamStatus(status)
+		return nil
+	})
+	if err != nil {
+		return InstanceState{}, err
+	}
+
+	return InstanceState{
+		Status:    status,
+		ReadOnly:  readonly,
+		VClock:    vclock,
+		Upstreams: upstreams,
+	}, nil
+}
+
+// parsePairs parses an array of {id, value} pairs.
+// Lua tables are empty arrays and maps at the same time,
+// so an empty map is treated as an empty array as well.
+func parsePairs(dt container, key string, fn func(id uint64, v interface{}) error) error {
+	if m, ok := dt[key].(map[string]interface{}); ok && len(m) == 0 {
+		return nil
+	}
+
+	arr, err := dt.getArray(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range arr {
+		pair, ok := p.([]interface{})
+		if !ok || len(pair) != 2 {
+			return fmt.Errorf("expected to find pair in '%s' array, got %v", key, p)
+		}
+
+		id, err := toInt64(pair[0])
+		if err != nil {
+			return err
+		}
+
+		err = fn(uint64(id), pair[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch t := v.(type) {
+	case int64:
+		return t, nil
+	case uint64:
+		return int64(t), nil
+	default:
+		return 0, fmt.Errorf("expected to find integer, got %T: %v", v, v)
+	}
+}
+
 func ParseReplication(data [][]interface{}) ([]Instance, error) {
 	if len(data) == 0 {
 		return nil, ErrEmptyResponse
@@ -144,6 +144,7 @@ func TestParseInstanceInfo(t *testing.T) {
 
 	assert.True(t, data.Readonly)
 	assert.Equal(t, uint64(251215738), data.VShardFingerprint)
+	assert.Empty(t, data.Zone)
 
 	storage := &data.StorageInfo
 	assert.Equal(t, HealthCodeGreen, storage.Status)
@@ -243,3 +244,44 @@ func TestParseReplication_TableTests(t *testing.T) {
 		})
 	}
 }
+
+func TestParseInstanceState(t *testing.T) {
+	data := [][]interface{}{
+		{
+			map[string]interface{}{
+				"status":    "running",
+				"read_only": true,
+				"vclock": []interface{}{
+					[]interface{}{uint64(1), uint64(120)},
+					[]interface{}{uint64(2), int64(5)},
+				},
+				"upstreams": []interface{}{
+					[]interface{}{uint64(1), "disconnected"},
+				},
+			},
+		},
+	}
+
+	state, err := ParseInstanceState(data)
+	require.Nil(t, err)
+
+	assert.Equal(t, InstanceState{
+		Status:   "running",
+		ReadOnly: true,
+		VClock: map[uint64]int64{
+			1: 120,
+			2: 5,
+		},
+		Upstreams: map[uint64]UpstreamStatus{
+			1: UpstreamDisconnected,
+		},
+	}, state)
+
+	data[0][0].(map[string]interface{})["upstreams"] = map[string]interface{}{}
+	state, err = ParseInstanceState(data)
+	require.Nil(t, err)
+	assert.Empty(t, state.Upstreams)
+
+	_, err = ParseInstanceState([][]interface{}{})
+	assert.Equal(t, ErrEmptyResponse, err)
+}
@@ -6,6 +6,9 @@ type Snapshot struct {
 	Routers     []Router     `json:"routers"`
 	ReplicaSets []ReplicaSet `json:"replica_sets"`
 	priorities  map[string]int
+	zones       map[string]string
+	hosts       map[string]string
+	drained     map[string]bool
 }
 
 func (s *Snapshot) ClusterHealthLevel() HealthLevel {
@@ -41,12 +44,27 @@ func (s *Snapshot) Copy() Snapshot {
 		Routers:     make([]Router, len(s.Routers)),
 		ReplicaSets: make([]ReplicaSet, 0, len(s.ReplicaSets)),
 		priorities:  make(map[string]int),
+		zones:       make(map[string]string),
+		hosts:       make(map[string]string),
+		drained:     make(map[string]bool),
 	}
 
 	for key, value := range s.priorities {
 		dst.priorities[key] = value
 	}
 
+	for key, value := range s.zones {
+		dst.zones[key] = value
+	}
+
+	for key, value := range s.hosts {
+		dst.hosts[key] = value
+	}
+
+	for key, value := range s.drained {
+		dst.drained[key] = value
+	}
+
 	for _, set := range s.ReplicaSets {
 		dst.ReplicaSets = append(dst.ReplicaSets, set.Copy())
 	}
@@ -76,6 +94,50 @@ func (s *Snapshot) ReplicaSet(uuid ReplicaSetUUID) (ReplicaSet, error) {
 	return ReplicaSet{}, ErrReplicaSetNotFound
 }
 
+// SameAs reports whether both snapshots have the same routers
+// and the replica sets with the same topology.
+// Unlike ReplicaSet.SameAs, it does not reorder the instances.
+func (s *Snapshot) SameAs(another *Snapshot) bool {
+	if len(s.Routers) != len(another.Routers) || len(s.ReplicaSets) != len(another.ReplicaSets) {
+		return false
+	}
+
+	routers := make(map[string]bool, len(s.Routers))
+	for i := range s.Routers {
+		routers[s.Routers[i].URI] = true
+	}
+	for i := range another.Routers {
+		if !routers[another.Routers[i].URI] {
+			return false
+		}
+	}
+
+	for i := range s.ReplicaSets {
+		set, err := another.ReplicaSet(s.ReplicaSets[i].UUID)
+		if err != nil {
+			return false
+		}
+		set = set.Copy()
+		if !s.ReplicaSets[i].Copy().SameAs(&set) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SameStateAs reports whether the snapshots have the same topology
+// and none of the changes reported by DiffSnapshots, e.g. alerts or LSN lag.
+// It is used to deduplicate the history, so the diffs between historical
+// snapshots do not miss any change.
+func (s *Snapshot) SameStateAs(another *Snapshot) bool {
+	if !s.SameAs(another) {
+		return false
+	}
+
+	return len(DiffSnapshots(s, another).ReplicaSets) == 0
+}
+
 func (s *Snapshot) UpdatePriorities(priorities map[string]int) {
 	s.priorities = priorities
 
@@ -91,3 +153,48 @@ func (s *Snapshot) UpdatePriorities(priorities map[string]int) {
 		}
 	}
 }
+
+// UpdateZones sets the zones of the instances defined in qumomf configuration.
+// Other instances keep the zone read from the vshard configuration.
+func (s *Snapshot) UpdateZones(zones map[string]string) {
+	s.zones = zones
+
+	for i := range s.ReplicaSets {
+		set := &s.ReplicaSets[i]
+		for j := range set.Instances {
+			inst := &set.Instances[j]
+			if zone, ok := s.zones[string(inst.UUID)]; ok {
+				inst.Zone = zone
+			}
+		}
+	}
+}
+
+// UpdateHosts sets the host labels of the instances defined in qumomf configuration.
+func (s *Snapshot) UpdateHosts(hosts map[string]string) {
+	s.hosts = hosts
+
+	for i := range s.ReplicaSets {
+		set := &s.ReplicaSets[i]
+		for j := range set.Instances {
+			inst := &set.Instances[j]
+			if host, ok := s.hosts[string(inst.UUID)]; ok {
+				inst.HostLabel = host
+			}
+		}
+	}
+}
+
+// UpdateDrainedHosts marks the instances located on the drained hosts.
+// Host labels must be updated before.
+func (s *Snapshot) UpdateDrainedHosts(drained map[string]bool) {
+	s.drained = drained
+
+	for i := range s.ReplicaSets {
+		set := &s.ReplicaSets[i]
+		for j := range set.Instances {
+			inst := &set.Instances[j]
+			inst.Drained = s.drained[inst.Host()]
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package vshard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot_SameAs(t *testing.T) {
+	newSnapshot := func(master InstanceUUID, readonly bool) Snapshot {
+		return Snapshot{
+			Created: 100,
+			Routers: []Router{{URI: "router_1"}},
+			ReplicaSets: []ReplicaSet{{
+				UUID:       "set_1",
+				MasterUUID: master,
+				Instances: []Instance{
+					{UUID: "instance_1", URI: "localhost:3301"},
+					{UUID: "instance_2", URI: "localhost:3302", Readonly: readonly},
+				},
+			}},
+		}
+	}
+
+	base := newSnapshot("instance_1", true)
+
+	same := newSnapshot("instance_1", true)
+	same.Created = 200
+	same.Routers[0].Info.LastSeen = 200
+	assert.True(t, base.SameAs(&same))
+
+	switched := newSnapshot("instance_2", false)
+	assert.False(t, base.SameAs(&switched))
+
+	flipped := newSnapshot("instance_1", false)
+	assert.False(t, base.SameAs(&flipped))
+
+	moved := newSnapshot("instance_1", true)
+	moved.Routers[0].URI = "router_2"
+	assert.False(t, base.SameAs(&moved))
+
+	grown := newSnapshot("instance_1", true)
+	grown.ReplicaSets = append(grown.ReplicaSets, ReplicaSet{UUID: "set_2"})
+	assert.False(t, base.SameAs(&grown))
+}
+
+func TestSnapshot_SameStateAs(t *testing.T) {
+	newSnapshot := func() Snapshot {
+		return Snapshot{
+			Created: 100,
+			Routers: []Router{{URI: "router_1"}},
+			ReplicaSets: []ReplicaSet{{
+				UUID:       "set_1",
+				MasterUUID: "instance_1",
+				Instances: []Instance{
+					{UUID: "instance_1", URI: "localhost:3301", VShardFingerprint: 1},
+					{UUID: "instance_2", URI: "localhost:3302", Readonly: true, VShardFingerprint: 1},
+				},
+			}},
+		}
+	}
+
+	base := newSnapshot()
+
+	same := newSnapshot()
+	same.Created = 200
+	assert.True(t, base.SameStateAs(&same))
+
+	switched := newSnapshot()
+	switched.ReplicaSets[0].MasterUUID = "instance_2"
+	assert.False(t, base.SameStateAs(&switched))
+
+	reconfigured := newSnapshot()
+	reconfigured.ReplicaSets[0].Instances[1].VShardFingerprint = 2
+	assert.False(t, base.SameStateAs(&reconfigured))
+
+	alerted := newSnapshot()
+	alerted.ReplicaSets[0].Instances[1].StorageInfo.Alerts = []Alert{
+		{Type: AlertUnreachableMaster, Description: "Master is unreachable"},
+	}
+	assert.True(t, base.SameAs(&alerted))
+	assert.False(t, base.SameStateAs(&alerted))
+
+	lagging := newSnapshot()
+	lagging.ReplicaSets[0].Instances[1].LSNBehindMaster = 10
+	assert.True(t, base.SameAs(&lagging))
+	assert.False(t, base.SameStateAs(&lagging))
+}